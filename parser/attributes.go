@@ -0,0 +1,235 @@
+package osuParser
+
+import "math"
+
+// HitWindows are the timing windows of the judgements in milliseconds,
+// measured from the hit object in either direction. Judgements a game mode
+// does not have are 0.
+type HitWindows struct {
+	Perfect float64 // mania 320 / MAX
+	Great   float64 // 300
+	Good    float64 // mania 200
+	Ok      float64 // 100
+	Meh     float64 // 50
+	Miss    float64
+}
+
+// DifficultyAttributes are the difficulty settings of a beatmap after mods
+// are applied. Times are in real time, so rate changing mods are already
+// accounted for, and ApproachRate and OverallDifficulty are the values that
+// would give the same times without rate changes.
+type DifficultyAttributes struct {
+	Mode              GameMode
+	Mods              Mods
+	ClockRate         float64
+	HPDrainRate       float64
+	CircleSize        float64
+	OverallDifficulty float64
+	ApproachRate      float64
+	Preempt           float64
+	FadeIn            float64
+	HitWindows        HitWindows
+	Radius            float64
+	KeyCount          int
+}
+
+// difficultyRange maps a difficulty value from 0 to 10 onto min, mid and max
+// the way osu! stable does.
+func difficultyRange(difficulty, min, mid, max float64) float64 {
+	if difficulty > 5 {
+		return mid + (max-mid)*(difficulty-5)/5
+	}
+	if difficulty < 5 {
+		return mid - (mid-min)*(5-difficulty)/5
+	}
+	return mid
+}
+
+// inverseDifficultyRange is the inverse of difficultyRange.
+func inverseDifficultyRange(value, min, mid, max float64) float64 {
+	if (value-mid)*(max-mid) > 0 {
+		return 5 + (value-mid)/(max-mid)*5
+	}
+	if value != mid {
+		return 5 - (mid-value)/(mid-min)*5
+	}
+	return 5
+}
+
+// Attributes applies mods to the difficulty settings for a game mode. Mania
+// key counts are taken from the circle size, see OsuFile.DifficultyAttributes
+// for converted beatmaps.
+func (d Difficulty) Attributes(mode GameMode, mods Mods) DifficultyAttributes {
+	attributes := DifficultyAttributes{
+		Mode:              mode,
+		Mods:              mods,
+		ClockRate:         mods.ClockRate(),
+		HPDrainRate:       d.HPDrainRate,
+		CircleSize:        d.CircleSize,
+		OverallDifficulty: d.OverallDifficulty,
+		ApproachRate:      d.ApproachRate,
+	}
+
+	if mode == ModeMania {
+		attributes.KeyCount = maniaKeyCount(d.CircleSize, mods)
+	}
+
+	// mania uses the circle size as key count and applies EZ and HR to the
+	// hit windows instead of the overall difficulty
+	switch {
+	case mods.Has(ModHardRock):
+		if mode != ModeMania {
+			attributes.CircleSize = math.Min(attributes.CircleSize*1.3, 10)
+			attributes.OverallDifficulty = math.Min(attributes.OverallDifficulty*1.4, 10)
+		}
+		attributes.ApproachRate = math.Min(attributes.ApproachRate*1.4, 10)
+		attributes.HPDrainRate = math.Min(attributes.HPDrainRate*1.4, 10)
+	case mods.Has(ModEasy):
+		if mode != ModeMania {
+			attributes.CircleSize *= 0.5
+			attributes.OverallDifficulty *= 0.5
+		}
+		attributes.ApproachRate *= 0.5
+		attributes.HPDrainRate *= 0.5
+	}
+
+	rate := attributes.ClockRate
+	ar := attributes.ApproachRate
+	od := attributes.OverallDifficulty
+
+	attributes.Preempt = difficultyRange(ar, 1800, 1200, 450) / rate
+	attributes.FadeIn = difficultyRange(ar, 1200, 800, 300) / rate
+	attributes.ApproachRate = inverseDifficultyRange(attributes.Preempt, 1800, 1200, 450)
+
+	switch mode {
+	case ModeStandard:
+		attributes.HitWindows = HitWindows{
+			Great: difficultyRange(od, 80, 50, 20) / rate,
+			Ok:    difficultyRange(od, 140, 100, 60) / rate,
+			Meh:   difficultyRange(od, 200, 150, 100) / rate,
+			Miss:  400 / rate,
+		}
+		attributes.OverallDifficulty = inverseDifficultyRange(attributes.HitWindows.Great, 80, 50, 20)
+	case ModeTaiko:
+		attributes.HitWindows = HitWindows{
+			Great: difficultyRange(od, 50, 35, 20) / rate,
+			Ok:    difficultyRange(od, 120, 80, 50) / rate,
+			Miss:  difficultyRange(od, 135, 95, 70) / rate,
+		}
+		attributes.OverallDifficulty = inverseDifficultyRange(attributes.HitWindows.Great, 50, 35, 20)
+	case ModeMania:
+		scale := 1.0
+		switch {
+		case mods.Has(ModHardRock):
+			scale = 1 / 1.4
+		case mods.Has(ModEasy):
+			scale = 1.4
+		}
+		attributes.HitWindows = HitWindows{
+			Perfect: 16 * scale / rate,
+			Great:   (64 - 3*od) * scale / rate,
+			Good:    (97 - 3*od) * scale / rate,
+			Ok:      (127 - 3*od) * scale / rate,
+			Meh:     (151 - 3*od) * scale / rate,
+			Miss:    (188 - 3*od) * scale / rate,
+		}
+	}
+
+	if mode == ModeStandard || mode == ModeCatch {
+		attributes.Radius = circleRadius(attributes.CircleSize)
+	}
+
+	return attributes
+}
+
+// circleRadius returns the radius of a hit circle in osu!pixels.
+func circleRadius(cs float64) float64 {
+	return 64 * (1 - 0.7*(cs-5)/5) / 2
+}
+
+func maniaKeyCount(cs float64, mods Mods) int {
+	if keys := mods.KeyCount(); keys > 0 {
+		return keys
+	}
+	return max(1, int(math.RoundToEven(cs)))
+}
+
+// maniaConvertKeyCount returns the key count osu! uses when converting a
+// beatmap of another mode to mania.
+func maniaConvertKeyCount(cs, od float64, objects, durationObjects int, mods Mods) int {
+	if keys := mods.KeyCount(); keys > 0 {
+		return keys
+	}
+
+	roundedCS := int(math.RoundToEven(cs))
+	roundedOD := int(math.RoundToEven(od))
+
+	percentDuration := 0.0
+	if objects > 0 {
+		percentDuration = float64(durationObjects) / float64(objects)
+	}
+
+	switch {
+	case percentDuration < 0.2:
+		return 7
+	case percentDuration < 0.3 || roundedCS >= 5:
+		if roundedOD > 5 {
+			return 7
+		}
+		return 6
+	case percentDuration > 0.6:
+		if roundedOD > 4 {
+			return 5
+		}
+		return 4
+	default:
+		return max(4, min(roundedOD+1, 7))
+	}
+}
+
+// DifficultyAttributes applies mods to the difficulty settings of the beatmap
+// when played in mode.
+func (f *OsuFile) DifficultyAttributes(mode GameMode, mods Mods) DifficultyAttributes {
+	attributes := f.Difficulty.Attributes(mode, mods)
+
+	// key mods only apply to converted beatmaps
+	if mode == ModeMania && f.Mode == ModeMania {
+		attributes.KeyCount = maniaKeyCount(f.CircleSize, ModNone)
+	}
+
+	if mode == ModeMania && f.Mode != ModeMania {
+		durationObjects := 0
+		for _, h := range f.HitObjects {
+			if h.Type.IsSlider() || h.Type.IsSpinner() {
+				durationObjects++
+			}
+		}
+		attributes.KeyCount = maniaConvertKeyCount(f.CircleSize, f.OverallDifficulty, len(f.HitObjects), durationObjects, mods)
+	}
+	return attributes
+}
+
+// DifficultyAttributes applies mods to the difficulty settings stored in the
+// osu!.db entry when played in mode.
+func (b *Beatmap) DifficultyAttributes(mode GameMode, mods Mods) DifficultyAttributes {
+	difficulty := Difficulty{
+		HPDrainRate:       float64(b.HPDrain),
+		CircleSize:        float64(b.CircleSize),
+		OverallDifficulty: float64(b.OverallDifficulty),
+		ApproachRate:      float64(b.ApproachRate),
+		SliderMultiplier:  b.SliderVelocity,
+	}
+	attributes := difficulty.Attributes(mode, mods)
+
+	// key mods only apply to converted beatmaps
+	if mode == ModeMania && b.GameplayMode == ModeMania {
+		attributes.KeyCount = maniaKeyCount(difficulty.CircleSize, ModNone)
+	}
+
+	if mode == ModeMania && b.GameplayMode != ModeMania {
+		objects := int(b.NumberOfHitCircles) + int(b.NumberOfSliders) + int(b.NumberOfSpinners)
+		durationObjects := int(b.NumberOfSliders) + int(b.NumberOfSpinners)
+		attributes.KeyCount = maniaConvertKeyCount(difficulty.CircleSize, difficulty.OverallDifficulty, objects, durationObjects, mods)
+	}
+	return attributes
+}
@@ -0,0 +1,52 @@
+package osuParser
+
+import "testing"
+
+func TestDifficultyAttributes(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         GameMode
+		mods         Mods
+		difficulty   float64 // AR, OD and CS
+		approachRate float64
+		preempt      float64
+		fadeIn       float64
+		hitWindows   HitWindows
+		radius       float64
+	}{
+		{"standard 0", ModeStandard, ModNone, 0, 0, 1800, 1200, HitWindows{Great: 80, Ok: 140, Meh: 200, Miss: 400}, 54.4},
+		{"standard 5", ModeStandard, ModNone, 5, 5, 1200, 800, HitWindows{Great: 50, Ok: 100, Meh: 150, Miss: 400}, 32},
+		{"standard 10", ModeStandard, ModNone, 10, 10, 450, 300, HitWindows{Great: 20, Ok: 60, Meh: 100, Miss: 400}, 9.6},
+		{"standard 5 HR", ModeStandard, ModHardRock, 5, 7, 900, 600, HitWindows{Great: 38, Ok: 84, Meh: 130, Miss: 400}, 25.28},
+		{"standard 10 EZ", ModeStandard, ModEasy, 10, 5, 1200, 800, HitWindows{Great: 50, Ok: 100, Meh: 150, Miss: 400}, 32},
+		{"standard 5 DT", ModeStandard, ModDoubleTime, 5, 23.0 / 3, 800, 1600.0 / 3, HitWindows{Great: 100.0 / 3, Ok: 200.0 / 3, Meh: 100, Miss: 800.0 / 3}, 32},
+		{"standard 5 HT", ModeStandard, ModHalfTime, 5, 5.0 / 3, 1600, 3200.0 / 3, HitWindows{Great: 200.0 / 3, Ok: 400.0 / 3, Meh: 200, Miss: 1600.0 / 3}, 32},
+		{"taiko 0", ModeTaiko, ModNone, 0, 0, 1800, 1200, HitWindows{Great: 50, Ok: 120, Miss: 135}, 0},
+		{"taiko 5", ModeTaiko, ModNone, 5, 5, 1200, 800, HitWindows{Great: 35, Ok: 80, Miss: 95}, 0},
+		{"taiko 10", ModeTaiko, ModNone, 10, 10, 450, 300, HitWindows{Great: 20, Ok: 50, Miss: 70}, 0},
+		{"catch 5", ModeCatch, ModNone, 5, 5, 1200, 800, HitWindows{}, 32},
+		{"mania 0", ModeMania, ModNone, 0, 0, 1800, 1200, HitWindows{Perfect: 16, Great: 64, Good: 97, Ok: 127, Meh: 151, Miss: 188}, 0},
+		{"mania 5", ModeMania, ModNone, 5, 5, 1200, 800, HitWindows{Perfect: 16, Great: 49, Good: 82, Ok: 112, Meh: 136, Miss: 173}, 0},
+		{"mania 10", ModeMania, ModNone, 10, 10, 450, 300, HitWindows{Perfect: 16, Great: 34, Good: 67, Ok: 97, Meh: 121, Miss: 158}, 0},
+		{"mania 5 HR", ModeMania, ModHardRock, 5, 7, 900, 600, HitWindows{Perfect: 16 / 1.4, Great: 35, Good: 82 / 1.4, Ok: 80, Meh: 136 / 1.4, Miss: 173 / 1.4}, 0},
+		{"mania 5 EZ", ModeMania, ModEasy, 5, 2.5, 1500, 1000, HitWindows{Perfect: 22.4, Great: 68.6, Good: 114.8, Ok: 156.8, Meh: 190.4, Miss: 242.2}, 0},
+	}
+
+	for _, test := range tests {
+		d := Difficulty{ApproachRate: test.difficulty, OverallDifficulty: test.difficulty, CircleSize: test.difficulty}
+		a := d.Attributes(test.mode, test.mods)
+
+		if !approxEqual(a.ApproachRate, test.approachRate) || !approxEqual(a.Preempt, test.preempt) || !approxEqual(a.FadeIn, test.fadeIn) {
+			t.Errorf("%s: AR %v with preempt %v and fade in %v, want %v with %v and %v",
+				test.name, a.ApproachRate, a.Preempt, a.FadeIn, test.approachRate, test.preempt, test.fadeIn)
+		}
+		w, want := a.HitWindows, test.hitWindows
+		if !approxEqual(w.Perfect, want.Perfect) || !approxEqual(w.Great, want.Great) || !approxEqual(w.Good, want.Good) ||
+			!approxEqual(w.Ok, want.Ok) || !approxEqual(w.Meh, want.Meh) || !approxEqual(w.Miss, want.Miss) {
+			t.Errorf("%s: hit windows %+v, want %+v", test.name, w, want)
+		}
+		if !approxEqual(a.Radius, test.radius) {
+			t.Errorf("%s: radius %v, want %v", test.name, a.Radius, test.radius)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package osuParser
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// BulkOptions configures the parsing of many .osu files at once.
+type BulkOptions struct {
+	// Workers is the number of files parsed concurrently. It defaults to
+	// runtime.GOMAXPROCS(0).
+	Workers int
+	// Progress is called after every parsed file with the number of files
+	// done so far and the total. It is never called concurrently.
+	Progress func(done, total int)
+	// Options are used to parse every file.
+	Options ParseOptions
+}
+
+// BulkResult is the outcome of parsing one of many .osu files. Either File or
+// Err is set.
+type BulkResult struct {
+	Path string
+	File *OsuFile
+	Err  error
+}
+
+// ParseOsuFiles parses the .osu files paths with a pool of workers. The
+// results are in the order of paths and hold the error of each file that
+// couldn't be parsed. If ctx is cancelled the files not parsed yet get its
+// error, which is also returned.
+func ParseOsuFiles(ctx context.Context, paths []string, options BulkOptions) ([]BulkResult, error) {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	results := make([]BulkResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+	}
+
+	var mu sync.Mutex
+	done := 0
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				result := &results[i]
+				result.File, result.Err = ParseOsuFileWithOptions(result.Path, options.Options)
+
+				if options.Progress != nil {
+					mu.Lock()
+					done++
+					options.Progress(done, len(paths))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			if results[i].File == nil && results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+// ParseOsuDBFiles parses the .osu file of every beatmap of db, found under the
+// Songs directory songsDir, like ParseOsuFiles. The results are in the order
+// of db.Beatmaps.
+func ParseOsuDBFiles(ctx context.Context, db *OsuDB, songsDir string, options BulkOptions) ([]BulkResult, error) {
+	paths := make([]string, len(db.Beatmaps))
+	for i, beatmap := range db.Beatmaps {
+		paths[i] = filepath.Join(songsDir, beatmap.FolderName, beatmap.FileName)
+	}
+	return ParseOsuFiles(ctx, paths, options)
+}
+
+// ParseSongsDir parses every .osu file below the Songs directory songsDir like
+// ParseOsuFiles. The results are in lexical order of the paths.
+func ParseSongsDir(ctx context.Context, songsDir string, options BulkOptions) ([]BulkResult, error) {
+	var paths []string
+	err := filepath.WalkDir(songsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(path), ".osu") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseOsuFiles(ctx, paths, options)
+}
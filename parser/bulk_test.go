@@ -0,0 +1,178 @@
+package osuParser
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// songsDir copies the fixtures into a Songs directory of beatmap sets.
+func songsDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"1 Set/standard.osu": "testdata/standard.osu",
+		"1 Set/taiko.osu":    "testdata/taiko.osu",
+		"2 Set/catch.osu":    "testdata/catch.osu",
+		"2 Set/MANIA.OSU":    "testdata/mania.osu",
+		"2 Set/notes.txt":    "",
+		"3 Set/broken.osu":   "",
+	}
+	for name, fixture := range files {
+		data := []byte("osu file format v14\r\n\r\n[HitObjects]\r\n256,192\r\n")
+		if fixture != "" {
+			var err error
+			if data, err = os.ReadFile(fixture); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseOsuFiles(t *testing.T) {
+	dir := songsDir(t)
+
+	var paths []string
+	var modes []GameMode
+	for i := range 24 {
+		name := []string{"1 Set/standard.osu", "1 Set/taiko.osu", "2 Set/catch.osu", "2 Set/MANIA.OSU"}[i%4]
+		paths = append(paths, filepath.Join(dir, name))
+		modes = append(modes, GameMode(i%4))
+	}
+	paths = append(paths, filepath.Join(dir, "3 Set/broken.osu"), filepath.Join(dir, "3 Set/missing.osu"))
+
+	var progress []int
+	results, err := ParseOsuFiles(context.Background(), paths, BulkOptions{
+		Workers: 4,
+		Progress: func(done, total int) {
+			if total != len(paths) {
+				t.Errorf("progress of %d files, want %d", total, len(paths))
+			}
+			progress = append(progress, done)
+		},
+		Options: ParseOptions{Strict: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(paths) {
+		t.Fatalf("got %d results, want %d", len(results), len(paths))
+	}
+	for i, mode := range modes {
+		if results[i].Path != paths[i] || results[i].Err != nil || results[i].File.Mode != mode {
+			t.Errorf("result %d: %s in mode %v (%v), want %s in mode %v", i, results[i].Path, results[i].File, results[i].Err, paths[i], mode)
+		}
+	}
+
+	var parseErr *ParseError
+	if broken := results[len(modes)]; broken.File != nil || !errors.As(broken.Err, &parseErr) || parseErr.Line != 4 {
+		t.Errorf("broken file: %v, want a ParseError of line 4", broken.Err)
+	}
+	if missing := results[len(modes)+1]; missing.File != nil || !errors.Is(missing.Err, fs.ErrNotExist) {
+		t.Errorf("missing file: %v, want fs.ErrNotExist", missing.Err)
+	}
+
+	want := make([]int, len(paths))
+	for i := range want {
+		want[i] = i + 1
+	}
+	if !slices.Equal(progress, want) {
+		t.Errorf("progress %v, want %v", progress, want)
+	}
+}
+
+func TestParseOsuFilesCancel(t *testing.T) {
+	dir := songsDir(t)
+	paths := make([]string, 10)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, "1 Set/standard.osu")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	results, err := ParseOsuFiles(ctx, paths, BulkOptions{
+		Workers: 1,
+		Progress: func(done, total int) {
+			calls++
+			cancel()
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	if calls != 1 || results[0].File == nil {
+		t.Errorf("%d files parsed after cancelling, first %v", calls, results[0].Err)
+	}
+	for i, result := range results[1:] {
+		if result.File != nil || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d: %v, want context.Canceled", i+1, result.Err)
+		}
+	}
+
+	if _, err := ParseSongsDir(ctx, dir, BulkOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("songs directory walked with a cancelled context: %v", err)
+	}
+}
+
+func TestParseSongsDir(t *testing.T) {
+	dir := songsDir(t)
+
+	results, err := ParseSongsDir(context.Background(), dir, BulkOptions{Workers: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1 Set/standard.osu", "1 Set/taiko.osu", "2 Set/MANIA.OSU", "2 Set/catch.osu", "3 Set/broken.osu"}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Path != filepath.Join(dir, want[i]) || result.Err != nil {
+			t.Errorf("result %d: %s (%v), want %s", i, result.Path, result.Err, want[i])
+		}
+	}
+
+	// without Strict the malformed object is a warning
+	if broken := results[4].File; broken == nil || len(broken.Warnings) != 1 {
+		t.Errorf("broken file parsed as %+v", broken)
+	}
+}
+
+func TestParseOsuDBFiles(t *testing.T) {
+	dir := songsDir(t)
+	db := &OsuDB{Beatmaps: []*Beatmap{
+		{FolderName: "2 Set", FileName: "catch.osu"},
+		{FolderName: "4 Set", FileName: "deleted.osu"},
+		{FolderName: "1 Set", FileName: "standard.osu"},
+	}}
+
+	results, err := ParseOsuDBFiles(context.Background(), db, dir, BulkOptions{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 || results[0].File == nil || results[0].File.Mode != ModeCatch || results[2].File == nil || results[2].File.Mode != ModeStandard {
+		t.Fatalf("results %+v, want catch.osu, deleted.osu and standard.osu", results)
+	}
+	if results[1].Path != filepath.Join(dir, "4 Set", "deleted.osu") || !errors.Is(results[1].Err, fs.ErrNotExist) {
+		t.Errorf("deleted beatmap: %s (%v), want fs.ErrNotExist", results[1].Path, results[1].Err)
+	}
+}
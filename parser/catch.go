@@ -0,0 +1,507 @@
+package osuParser
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// CatchDifficulty are the difficulty attributes of a beatmap in osu!catch,
+// as calculated by the osu! difficulty algorithm.
+type CatchDifficulty struct {
+	Mods         Mods
+	StarRating   float64
+	ApproachRate float64
+	MaxCombo     int
+}
+
+const (
+	catchDifficultyMultiplier = 4.59
+
+	catchPlayfieldWidth = 512
+	catcherBaseSize     = 106.75
+
+	// catcherAllowedCatchRange is the part of the catcher fruits can be
+	// caught with.
+	catcherAllowedCatchRange = 0.8
+	catcherBaseDashSpeed     = 1.0
+
+	catchNormalizedHitObjectRadius   = 41.0
+	catchAbsolutePlayerPositionError = 16.0
+	catchDirectionChangeBonus        = 21.0
+
+	// catchRandomSeed seeds the offsets of tiny droplets, bananas and hard
+	// rock fruits.
+	catchRandomSeed = 1337
+)
+
+// CatchObjectType is the kind of a CatchObject.
+type CatchObjectType int
+
+const (
+	CatchFruit CatchObjectType = iota
+	CatchDroplet
+	CatchTinyDroplet
+	CatchBanana
+)
+
+// CatchObject is a fruit, droplet, tiny droplet or banana of an osu!catch
+// beatmap. XOffset is the random offset osu! moves the object by.
+type CatchObject struct {
+	Type      CatchObjectType
+	StartTime float64
+	X         float64
+	XOffset   float64
+
+	// HyperDash is set when the next object can only be reached with a
+	// hyper dash, otherwise DistanceToHyperDash is how far the catcher
+	// could move further before it would need one.
+	HyperDash           bool
+	DistanceToHyperDash float64
+}
+
+// EffectiveX returns the position of the object with its offset applied.
+func (o *CatchObject) EffectiveX() float64 {
+	return o.X + o.XOffset
+}
+
+// catchHitObject is a hit object turned into a fruit, juice stream or banana
+// shower. Juice streams and banana showers hold their objects in nested.
+type catchHitObject struct {
+	fruit  *CatchObject
+	nested []*CatchObject
+
+	isJuiceStream bool
+	startTime     float64
+	lastPathX     float64
+}
+
+// catchCatcherWidth returns the width of the catcher for a circle size.
+func catchCatcherWidth(cs float64) float64 {
+	return catcherBaseSize * math.Abs(1-0.7*(cs-5)/5) * catcherAllowedCatchRange
+}
+
+// catchHitObjects turns the hit objects into fruits, juice streams and
+// banana showers, with the position offsets and hyper dashes osu! applies.
+func (f *OsuFile) catchHitObjects(mods Mods) []catchHitObject {
+	timeline := NewTimeline(f.TimingPointsFile)
+
+	hitObjects := make([]catchHitObject, 0, len(f.HitObjects))
+	for i := range f.HitObjects {
+		h := &f.HitObjects[i]
+		c := catchHitObject{startTime: h.Time}
+
+		switch params := h.ObjectParams.(type) {
+		case *Slider:
+			c.isJuiceStream = true
+			c.lastPathX = h.X
+			if len(params.Points) > 0 {
+				c.lastPathX = params.Points[len(params.Points)-1].X
+			}
+			c.nested = juiceStreamObjects(f, timeline.sliderTiming(f, h, params), timeline.DifficultyAt(h.Time))
+		case Spinner:
+			c.nested = bananaShowerObjects(h.Time, params.EndTime)
+		default:
+			c.fruit = &CatchObject{Type: CatchFruit, StartTime: h.Time, X: h.X}
+		}
+
+		hitObjects = append(hitObjects, c)
+	}
+
+	applyCatchPositionOffsets(hitObjects, mods.Has(ModHardRock))
+	initialiseHyperDash(hitObjects, f.Difficulty.Attributes(ModeCatch, mods).CircleSize)
+
+	return hitObjects
+}
+
+// juiceStreamObjects generates the fruits, droplets and tiny droplets of a
+// juice stream.
+func juiceStreamObjects(f *OsuFile, timing *SliderTiming, velocityMultiplier float64) []*CatchObject {
+	// juice streams don't use the tick distance of old beatmap versions
+	if f.SliderTickRate > 0 {
+		timing.TickDistance = sliderBaseScoringDistance * f.SliderMultiplier * velocityMultiplier / f.SliderTickRate
+	}
+
+	xAt := func(progress float64) float64 {
+		return clamp(timing.Path.PositionAt(progress).X, 0, catchPlayfieldWidth)
+	}
+
+	var objects []*CatchObject
+	var last *SliderEvent
+	for _, e := range timing.NestedObjects() {
+		// tiny droplets since the last event, including the legacy last
+		// tick like osu! does
+		if last != nil {
+			sinceLastTick := float64(int(e.Time) - int(last.Time))
+			if sinceLastTick > 80 {
+				timeBetweenTiny := sinceLastTick
+				for timeBetweenTiny > 100 {
+					timeBetweenTiny /= 2
+				}
+
+				for t := timeBetweenTiny; t < sinceLastTick; t += timeBetweenTiny {
+					objects = append(objects, &CatchObject{
+						Type:      CatchTinyDroplet,
+						StartTime: t + last.Time,
+						X:         xAt(last.PathProgress + t/sinceLastTick*(e.PathProgress-last.PathProgress)),
+					})
+				}
+			}
+		}
+		last = &e
+
+		switch e.Type {
+		case SliderTick:
+			objects = append(objects, &CatchObject{Type: CatchDroplet, StartTime: e.Time, X: xAt(e.PathProgress)})
+		case SliderHead, SliderRepeat, SliderTail:
+			objects = append(objects, &CatchObject{Type: CatchFruit, StartTime: e.Time, X: xAt(e.PathProgress)})
+		}
+	}
+	return objects
+}
+
+// bananaShowerObjects places the bananas of a banana shower at most 100ms
+// apart.
+func bananaShowerObjects(startTime, endTime float64) []*CatchObject {
+	spacing := endTime - startTime
+	for spacing > 100 {
+		spacing /= 2
+	}
+	if spacing <= 0 {
+		return nil
+	}
+
+	var objects []*CatchObject
+	for time := startTime; time <= endTime; time += spacing {
+		objects = append(objects, &CatchObject{Type: CatchBanana, StartTime: time})
+	}
+	return objects
+}
+
+// applyCatchPositionOffsets moves tiny droplets, bananas and with hard rock
+// fruits randomly, seeded the same way as osu!.
+func applyCatchPositionOffsets(hitObjects []catchHitObject, hardRock bool) {
+	rng := newLegacyRandom(catchRandomSeed)
+
+	lastPosition := 0.0
+	lastStartTime := 0.0
+
+	for _, c := range hitObjects {
+		switch {
+		case c.fruit != nil:
+			if hardRock {
+				applyHardRockOffset(c.fruit, &lastPosition, &lastStartTime, rng)
+			}
+
+		case c.isJuiceStream:
+			// osu! uses the last control point instead of the end of the path
+			// and the start time instead of the end time here
+			lastPosition = c.lastPathX
+			lastStartTime = c.startTime
+
+			for _, o := range c.nested {
+				switch o.Type {
+				case CatchTinyDroplet:
+					o.XOffset = clamp(float64(rng.nextRange(-20, 20)), -o.X, catchPlayfieldWidth-o.X)
+				case CatchDroplet:
+					// osu! retrieved a random droplet rotation
+					rng.next()
+				}
+			}
+
+		default:
+			for _, o := range c.nested {
+				o.XOffset = rng.nextDouble() * catchPlayfieldWidth
+				// osu! retrieved a random banana type, rotation and colour
+				rng.next()
+				rng.next()
+				rng.next()
+			}
+		}
+	}
+}
+
+func applyHardRockOffset(o *CatchObject, lastPosition, lastStartTime *float64, rng *legacyRandom) {
+	offsetPosition := o.X
+	startTime := o.StartTime
+
+	// a last position of 0 is treated as no last position, like osu! does
+	if *lastPosition == 0 {
+		*lastPosition = offsetPosition
+		*lastStartTime = startTime
+		return
+	}
+
+	positionDiff := offsetPosition - *lastPosition
+	// osu! calculated the time difference as integer
+	timeDiff := int(startTime - *lastStartTime)
+
+	if timeDiff > 1000 {
+		*lastPosition = offsetPosition
+		*lastStartTime = startTime
+		return
+	}
+
+	if positionDiff == 0 {
+		right := rng.nextBool()
+		random := math.Min(20, float64(rng.nextRange(0, math.Max(0, float64(timeDiff)/4))))
+		if right {
+			if offsetPosition+random <= catchPlayfieldWidth {
+				offsetPosition += random
+			} else {
+				offsetPosition -= random
+			}
+		} else {
+			if offsetPosition-random >= 0 {
+				offsetPosition -= random
+			} else {
+				offsetPosition += random
+			}
+		}
+
+		o.XOffset = offsetPosition - o.X
+		return
+	}
+
+	if math.Abs(positionDiff) < float64(timeDiff/3) {
+		if positionDiff > 0 {
+			if offsetPosition+positionDiff < catchPlayfieldWidth {
+				offsetPosition += positionDiff
+			}
+		} else {
+			if offsetPosition+positionDiff > 0 {
+				offsetPosition += positionDiff
+			}
+		}
+	}
+
+	o.XOffset = offsetPosition - o.X
+	*lastPosition = offsetPosition
+	*lastStartTime = startTime
+}
+
+// initialiseHyperDash marks the fruits and droplets that need a hyper dash
+// to reach the next one.
+func initialiseHyperDash(hitObjects []catchHitObject, cs float64) {
+	var palpable []*CatchObject
+	for _, c := range hitObjects {
+		if c.fruit != nil {
+			palpable = append(palpable, c.fruit)
+		}
+		if c.isJuiceStream {
+			for _, o := range c.nested {
+				if o.Type != CatchTinyDroplet {
+					palpable = append(palpable, o)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(palpable, func(a, b *CatchObject) int {
+		return cmp.Compare(a.StartTime, b.StartTime)
+	})
+
+	// osu! uses the full catcher size here instead of the catchable range
+	halfCatcherWidth := catchCatcherWidth(cs) / 2 / catcherAllowedCatchRange
+
+	lastDirection := 0
+	lastExcess := halfCatcherWidth
+
+	for i := 0; i < len(palpable)-1; i++ {
+		current := palpable[i]
+		next := palpable[i+1]
+
+		current.HyperDash = false
+		current.DistanceToHyperDash = 0
+
+		direction := -1
+		if next.EffectiveX() > current.EffectiveX() {
+			direction = 1
+		}
+
+		// times are truncated to match osu!, with a quarter frame of grace
+		timeToNext := float64(int(next.StartTime)-int(current.StartTime)) - 1000.0/60/4
+		distanceToNext := math.Abs(next.EffectiveX() - current.EffectiveX())
+		if lastDirection == direction {
+			distanceToNext -= lastExcess
+		} else {
+			distanceToNext -= halfCatcherWidth
+		}
+		distanceToHyper := timeToNext*catcherBaseDashSpeed - distanceToNext
+
+		if distanceToHyper < 0 {
+			current.HyperDash = true
+			lastExcess = halfCatcherWidth
+		} else {
+			current.DistanceToHyperDash = distanceToHyper
+			lastExcess = clamp(distanceToHyper, 0, halfCatcherWidth)
+		}
+
+		lastDirection = direction
+	}
+}
+
+// catchDifficultyObject is a fruit or droplet with its relation to the one
+// before it.
+type catchDifficultyObject struct {
+	index     int
+	startTime float64
+	deltaTime float64
+	// strainTime is capped to the equivalent of 375 BPM streaming
+	strainTime float64
+
+	normalizedPosition     float64
+	lastNormalizedPosition float64
+	last                   *CatchObject
+}
+
+// movementSkill is the strain of moving the catcher between objects.
+type movementSkill struct {
+	strainPeaks
+	currentStrain float64
+
+	// clock rate changes the catcher speed as well
+	catcherSpeedMultiplier float64
+
+	hasLastPlayerPosition bool
+	lastPlayerPosition    float64
+	lastDistanceMoved     float64
+	lastStrainTime        float64
+}
+
+func (s *movementSkill) process(d *catchDifficultyObject, previous *catchDifficultyObject) {
+	const strainDecayBase = 0.2
+
+	s.sectionLength = 750
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += s.strainValueOf(d)
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-previous.startTime)
+	})
+}
+
+func (s *movementSkill) strainValueOf(d *catchDifficultyObject) float64 {
+	if !s.hasLastPlayerPosition {
+		s.lastPlayerPosition = d.lastNormalizedPosition
+		s.hasLastPlayerPosition = true
+	}
+
+	playerPosition := clamp(
+		s.lastPlayerPosition,
+		d.normalizedPosition-(catchNormalizedHitObjectRadius-catchAbsolutePlayerPositionError),
+		d.normalizedPosition+(catchNormalizedHitObjectRadius-catchAbsolutePlayerPositionError),
+	)
+
+	distanceMoved := playerPosition - s.lastPlayerPosition
+
+	weightedStrainTime := d.strainTime + 13 + 3/s.catcherSpeedMultiplier
+
+	distanceAddition := math.Pow(math.Abs(distanceMoved), 1.3) / 510
+	sqrtStrain := math.Sqrt(weightedStrainTime)
+
+	edgeDashBonus := 0.0
+
+	if math.Abs(distanceMoved) > 0.1 {
+		// direction change bonus
+		if math.Abs(s.lastDistanceMoved) > 0.1 && math.Signbit(distanceMoved) != math.Signbit(s.lastDistanceMoved) {
+			bonusFactor := math.Min(50, math.Abs(distanceMoved)) / 50
+			antiflowFactor := math.Max(math.Min(70, math.Abs(s.lastDistanceMoved))/70, 0.38)
+
+			distanceAddition += catchDirectionChangeBonus / math.Sqrt(s.lastStrainTime+16) * bonusFactor * antiflowFactor * math.Max(1-math.Pow(weightedStrainTime/1000, 3), 0)
+		}
+
+		// base bonus for every movement, giving some weight to streams
+		distanceAddition += 12.5 * math.Min(math.Abs(distanceMoved), catchNormalizedHitObjectRadius*2) / (catchNormalizedHitObjectRadius * 6) / sqrtStrain
+	}
+
+	// bonus for edge dashes
+	if d.last.DistanceToHyperDash <= 20 {
+		if !d.last.HyperDash {
+			edgeDashBonus += 5.7
+		} else {
+			// after a hyper dash the catcher is always in the right position
+			playerPosition = d.normalizedPosition
+		}
+
+		// edge dashes are easier at lower ms values
+		distanceAddition *= 1 + edgeDashBonus*((20-d.last.DistanceToHyperDash)/20)*math.Pow(math.Min(d.strainTime*s.catcherSpeedMultiplier, 265)/265, 1.5)
+	}
+
+	s.lastPlayerPosition = playerPosition
+	s.lastDistanceMoved = distanceMoved
+	s.lastStrainTime = d.strainTime
+
+	return distanceAddition / weightedStrainTime
+}
+
+// CatchDifficulty calculates the osu!catch difficulty attributes of the
+// beatmap with mods applied.
+func (f *OsuFile) CatchDifficulty(mods Mods) *CatchDifficulty {
+	attributes := f.DifficultyAttributes(ModeCatch, mods)
+	clockRate := mods.ClockRate()
+
+	difficulty := &CatchDifficulty{
+		Mods:         mods,
+		ApproachRate: attributes.ApproachRate,
+	}
+
+	hitObjects := f.catchHitObjects(mods)
+
+	var palpable []*CatchObject
+	for _, c := range hitObjects {
+		if c.fruit != nil {
+			palpable = append(palpable, c.fruit)
+			difficulty.MaxCombo++
+		}
+		if c.isJuiceStream {
+			for _, o := range c.nested {
+				palpable = append(palpable, o)
+				if o.Type != CatchTinyDroplet {
+					difficulty.MaxCombo++
+				}
+			}
+		}
+	}
+
+	halfCatcherWidth := catchCatcherWidth(attributes.CircleSize) / 2
+	// the catcher is made smaller for high circle sizes to simulate imperfect
+	// gameplay
+	halfCatcherWidth *= 1 - math.Max(0, attributes.CircleSize-5.5)*0.0625
+
+	// positions are scaled so every circle size is the same
+	scalingFactor := catchNormalizedHitObjectRadius / halfCatcherWidth
+
+	movement := &movementSkill{catcherSpeedMultiplier: clockRate}
+
+	var last *CatchObject
+	var previous *catchDifficultyObject
+	index := 0
+	for _, o := range palpable {
+		// only objects that give combo are considered
+		if o.Type == CatchTinyDroplet {
+			continue
+		}
+
+		if last != nil {
+			d := &catchDifficultyObject{
+				index:                  index,
+				startTime:              o.StartTime / clockRate,
+				deltaTime:              (o.StartTime - last.StartTime) / clockRate,
+				normalizedPosition:     o.EffectiveX() * scalingFactor,
+				lastNormalizedPosition: last.EffectiveX() * scalingFactor,
+				last:                   last,
+			}
+			d.strainTime = math.Max(40, d.deltaTime)
+
+			movement.process(d, previous)
+			previous = d
+			index++
+		}
+		last = o
+	}
+
+	difficulty.StarRating = math.Sqrt(weightedSum(movement.all(), 0.94)) * catchDifficultyMultiplier
+	return difficulty
+}
@@ -0,0 +1,170 @@
+package osuParser
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// taikoVelocityMultiplier is the factor osu! stable speeds up sliders by in
+// osu!taiko.
+const taikoVelocityMultiplier = 1.4
+
+// TaikoObjects returns the hits, drum rolls and swells of the beatmap in
+// osu!taiko, converting osu!standard beatmaps the way osu! does.
+func (f *OsuFile) TaikoObjects() []TaikoObject {
+	native := f.Mode == ModeTaiko
+	timeline := NewTimeline(f.TimingPointsFile)
+
+	objects := make([]TaikoObject, 0, len(f.HitObjects))
+	for i := range f.HitObjects {
+		h := &f.HitObjects[i]
+
+		switch params := h.ObjectParams.(type) {
+		case *Slider:
+			spans := max(params.Slides, 1)
+			distance := params.Path(h.Position()).Distance() * float64(spans) * taikoVelocityMultiplier
+
+			beatLength := timeline.BeatLengthAt(h.Time) * timeline.bpmMultiplierAt(h.Time)
+			taikoVelocity := sliderBaseScoringDistance * f.SliderMultiplier * taikoVelocityMultiplier
+			taikoDuration := float64(int(distance / taikoVelocity * beatLength))
+
+			if !native {
+				osuVelocity := taikoVelocity * 1000 / beatLength
+				// newer beatmaps don't scale the tick spacing by the
+				// slider velocity
+				if f.Version >= 8 {
+					beatLength = timeline.BeatLengthAt(h.Time)
+				}
+				tickSpacing := math.Min(beatLength/f.SliderTickRate, taikoDuration/float64(spans))
+
+				// short sliders become a stream of hits
+				if tickSpacing > 0 && distance/osuVelocity*1000 < 2*beatLength {
+					node := 0
+					for t := h.Time; t <= h.Time+taikoDuration+tickSpacing/8; t += tickSpacing {
+						objects = append(objects, taikoHitObject(t, nodeSound(h, params, node)))
+						node = (node + 1) % (spans + 1)
+
+						if math.Abs(tickSpacing) < 1e-7 {
+							break
+						}
+					}
+					continue
+				}
+			}
+
+			objects = append(objects, TaikoObject{
+				Type:      TaikoDrumRoll,
+				StartTime: h.Time,
+				EndTime:   h.Time + taikoDuration,
+				Strong:    h.HitSound&HitSoundFinish != 0,
+			})
+		case Spinner:
+			objects = append(objects, TaikoObject{
+				Type:      TaikoSwell,
+				StartTime: h.Time,
+				EndTime:   params.EndTime,
+			})
+		default:
+			objects = append(objects, taikoHitObject(h.Time, h.HitSound))
+		}
+	}
+
+	slices.SortStableFunc(objects, func(a, b TaikoObject) int {
+		return cmp.Compare(a.StartTime, b.StartTime)
+	})
+
+	// objects at the same time are merged into a strong hit
+	merged := objects[:0]
+	for i := 0; i < len(objects); {
+		j := i + 1
+		for j < len(objects) && objects[j].StartTime == objects[i].StartTime {
+			j++
+		}
+		o := objects[i]
+		if j-i > 1 && o.Type != TaikoSwell {
+			o.Strong = true
+		}
+		merged = append(merged, o)
+		i = j
+	}
+	return merged
+}
+
+func taikoHitObject(time float64, hitSound int) TaikoObject {
+	return TaikoObject{
+		Type:      TaikoHit,
+		StartTime: time,
+		EndTime:   time,
+		Rim:       hitSound&(HitSoundWhistle|HitSoundClap) != 0,
+		Strong:    hitSound&HitSoundFinish != 0,
+	}
+}
+
+// nodeSound returns the hitsound of the head, repeats or tail of a slider.
+// Nodes without an edge sound use the hitsound of the slider.
+func nodeSound(h *HitObject, slider *Slider, node int) int {
+	if node >= 0 && node < len(slider.EdgeSounds) {
+		return slider.EdgeSounds[node]
+	}
+	return h.HitSound
+}
+
+// CatchObjects returns the fruits, droplets, tiny droplets and bananas of the
+// beatmap in osu!catch with mods applied, in the order osu! creates them.
+func (f *OsuFile) CatchObjects(mods Mods) []CatchObject {
+	var objects []CatchObject
+	for _, c := range f.catchHitObjects(mods) {
+		if c.fruit != nil {
+			objects = append(objects, *c.fruit)
+		}
+		for _, o := range c.nested {
+			objects = append(objects, *o)
+		}
+	}
+	return objects
+}
+
+// ManiaObjects returns the notes and hold notes of the beatmap in osu!mania
+// with mods applied. Other modes are converted the way osu! does, with key
+// mods changing the number of columns.
+func (f *OsuFile) ManiaObjects(mods Mods) []ManiaObject {
+	keyCount := f.DifficultyAttributes(ModeMania, mods).KeyCount
+
+	var objects []ManiaObject
+	if f.Mode == ModeMania {
+		timeline := NewTimeline(f.TimingPointsFile)
+		objects = make([]ManiaObject, 0, len(f.HitObjects))
+		for i := range f.HitObjects {
+			h := &f.HitObjects[i]
+			objects = append(objects, ManiaObject{
+				Column:    maniaColumn(h.X, keyCount),
+				StartTime: h.Time,
+				EndTime:   timeline.HitObjectEndTime(f, h),
+			})
+		}
+	} else {
+		objects = newManiaConverter(f, keyCount).convert()
+	}
+
+	slices.SortStableFunc(objects, func(a, b ManiaObject) int {
+		return cmp.Compare(a.StartTime, b.StartTime)
+	})
+	return objects
+}
+
+// maniaColumn returns the column an x position falls into. osu! does this in
+// single precision.
+func maniaColumn(x float64, keyCount int) int {
+	column := int(math.Floor(float64(float32(x) / (float32(512) / float32(keyCount)))))
+	return max(0, min(column, keyCount-1))
+}
+
+// totalBreakTime returns the summed duration of the breaks of the beatmap.
+func (f *OsuFile) totalBreakTime() float64 {
+	total := 0.0
+	for _, b := range f.Breaks() {
+		total += b.End - b.Start
+	}
+	return total
+}
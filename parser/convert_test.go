@@ -0,0 +1,141 @@
+package osuParser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTaikoObjectsConverted(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+	objects := f.TaikoObjects()
+
+	counts := map[TaikoObjectType]int{}
+	rims, strong := 0, 0
+	for _, o := range objects {
+		counts[o.Type]++
+		if o.Rim {
+			rims++
+		}
+		if o.Strong {
+			strong++
+		}
+	}
+
+	if len(objects) != 59 || counts[TaikoHit] != 54 || counts[TaikoDrumRoll] != 2 || counts[TaikoSwell] != 3 {
+		t.Errorf("got %d objects %v, want 59 with 54 hits, 2 drum rolls and 3 swells", len(objects), counts)
+	}
+	if rims != 32 || strong != 3 {
+		t.Errorf("got %d rims and %d strong objects, want 32 and 3", rims, strong)
+	}
+
+	want := []TaikoObject{
+		{TaikoHit, 500, 500, false, false},
+		{TaikoHit, 666, 666, false, true},
+		{TaikoHit, 832, 832, true, false},
+	}
+	if !slices.Equal(objects[:3], want) {
+		t.Errorf("first objects %v, want %v", objects[:3], want)
+	}
+}
+
+func TestCatchObjectsConverted(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+	objects := f.CatchObjects(ModNone)
+
+	counts := map[CatchObjectType]int{}
+	var bananas, droplets []float64
+	for _, o := range objects {
+		counts[o.Type]++
+		switch {
+		case o.Type == CatchBanana && len(bananas) < 4:
+			bananas = append(bananas, o.XOffset)
+		case o.Type == CatchTinyDroplet && len(droplets) < 4:
+			droplets = append(droplets, o.XOffset)
+		}
+	}
+
+	want := map[CatchObjectType]int{CatchFruit: 61, CatchDroplet: 7, CatchTinyDroplet: 61, CatchBanana: 51}
+	if len(objects) != 180 || len(counts) != len(want) {
+		t.Errorf("got %d objects %v, want 180 %v", len(objects), counts, want)
+	}
+	for kind, n := range want {
+		if counts[kind] != n {
+			t.Errorf("got %d objects of type %d, want %d", counts[kind], kind, n)
+		}
+	}
+
+	// the offsets come from the random generator osu! seeds with 1337
+	wantBananas := []float64{315.216690, 145.717009, 159.172369, 310.309871}
+	wantDroplets := []float64{-14, -10, -2, 15}
+	for i := range wantBananas {
+		if !approxEqual(bananas[i], wantBananas[i]) || droplets[i] != wantDroplets[i] {
+			t.Errorf("banana offsets %v and tiny droplet offsets %v, want %v and %v", bananas, droplets, wantBananas, wantDroplets)
+			break
+		}
+	}
+
+	// hard rock moves fruits that follow each other closely
+	hardRock := f.CatchObjects(ModHardRock)
+	if len(hardRock) != len(objects) {
+		t.Fatalf("got %d objects with hard rock, want %d", len(hardRock), len(objects))
+	}
+	moved := 0
+	for i := range objects {
+		if objects[i].EffectiveX() != hardRock[i].EffectiveX() {
+			moved++
+		}
+	}
+	wantX := map[int][2]float64{20: {121, 147}, 27: {266, 416}, 84: {164, 92}, 104: {146, 130}}
+	for i, x := range wantX {
+		if objects[i].EffectiveX() != x[0] || hardRock[i].EffectiveX() != x[1] {
+			t.Errorf("object %d at %v and %v with hard rock, want %v", i, objects[i].EffectiveX(), hardRock[i].EffectiveX(), x)
+		}
+	}
+	if moved != 55 {
+		t.Errorf("hard rock moved %d objects, want 55", moved)
+	}
+}
+
+func TestManiaObjectsConverted(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+
+	tests := []struct {
+		mods    string
+		objects int
+		holds   int
+		columns []int
+		first   []ManiaObject
+	}{
+		{"NM", 79, 16, []int{10, 15, 9, 9, 14, 7, 15}, []ManiaObject{{1, 500, 500}, {2, 666, 666}, {4, 666, 666}, {0, 832, 832}}},
+		{"4K", 75, 16, []int{19, 21, 20, 15}, []ManiaObject{{0, 500, 500}, {1, 666, 666}, {2, 666, 666}, {0, 832, 832}}},
+	}
+
+	for _, test := range tests {
+		objects := f.ManiaObjects(mustParseMods(t, test.mods))
+
+		columns := make([]int, len(test.columns))
+		holds := 0
+		for _, o := range objects {
+			if o.Column < 0 || o.Column >= len(columns) {
+				t.Fatalf("%s: object in column %d", test.mods, o.Column)
+			}
+			columns[o.Column]++
+			if o.EndTime > o.StartTime {
+				holds++
+			}
+		}
+
+		if len(objects) != test.objects || holds != test.holds || !slices.Equal(columns, test.columns) {
+			t.Errorf("%s: got %d objects with %d holds in columns %v, want %d with %d in %v",
+				test.mods, len(objects), holds, columns, test.objects, test.holds, test.columns)
+		}
+		if !slices.Equal(objects[:len(test.first)], test.first) {
+			t.Errorf("%s: first objects %v, want %v", test.mods, objects[:len(test.first)], test.first)
+		}
+
+		// the generator is seeded from the difficulty settings
+		if again := f.ManiaObjects(mustParseMods(t, test.mods)); !slices.Equal(again, objects) {
+			t.Errorf("%s: conversion is not deterministic", test.mods)
+		}
+	}
+}
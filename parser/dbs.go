@@ -1,10 +1,12 @@
 package osuParser
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/binary"
 	"io"
-	"os"
+	"io/fs"
+	"math"
+	"slices"
 	"time"
 )
 
@@ -16,7 +18,9 @@ type OsuDB struct {
 	PlayerName       string
 	NumberOfBeatmaps int32
 	Beatmaps         []*Beatmap
-	UserPermissions  int32
+	UserPermissions  Permissions
+
+	nulls nullStrings
 }
 
 type Beatmap struct {
@@ -30,7 +34,7 @@ type Beatmap struct {
 	AudioFileName         string
 	MD5Hash               string
 	FileName              string
-	RankedStatus          byte
+	RankedStatus          RankedStatus
 	NumberOfHitCircles    uint16
 	NumberOfSliders       uint16
 	NumberOfSpinners      uint16
@@ -40,10 +44,10 @@ type Beatmap struct {
 	HPDrain               float32
 	OverallDifficulty     float32
 	SliderVelocity        float64
-	StarRatingsStandard   map[int]int64
-	StarRatingsTaiko      map[int]int64
-	StarRatingsCTB        map[int]int64
-	StarRatingsMania      map[int]int64
+	StarRatingsStandard   map[Mods]float64
+	StarRatingsTaiko      map[Mods]float64
+	StarRatingsCTB        map[Mods]float64
+	StarRatingsMania      map[Mods]float64
 	DrainTime             int32
 	TotalTime             int32
 	AudioPreviewStartTime int32
@@ -51,13 +55,13 @@ type Beatmap struct {
 	DifficultyID          int32
 	BeatmapID             int32
 	ThreadID              int32
-	GradeStandard         byte
-	GradeTaiko            byte
-	GradeCTB              byte
-	GradeMania            byte
+	GradeStandard         Grade
+	GradeTaiko            Grade
+	GradeCTB              Grade
+	GradeMania            Grade
 	LocalBeatmapOffset    uint16
 	StackLeniency         float32
-	GameplayMode          byte
+	GameplayMode          GameMode
 	SongSource            string
 	SongTags              string
 	OnlineOffset          int16
@@ -75,6 +79,11 @@ type Beatmap struct {
 	UnknownShort          *uint16
 	LastModificationTime2 int32
 	ManiaScrollSpeed      byte
+
+	nulls nullStrings
+	// starRatingKeys is the order of the star ratings of each mode in the
+	// file, if it isn't the one writeStarRatings writes
+	starRatingKeys [4][]Mods
 }
 
 type TimingPoint struct {
@@ -93,6 +102,8 @@ type Collection struct {
 	Name             string
 	NumberOfBeatmaps int32
 	Beatmaps         []*string
+
+	nulls nullStrings
 }
 
 type Scores struct {
@@ -105,10 +116,12 @@ type BeatmapScores struct {
 	BeatmapMD5Hash string
 	NumberOfScores int32
 	Scores         []*Score
+
+	nulls nullStrings
 }
 
 type Score struct {
-	Gamemode          byte
+	Gamemode          GameMode
 	Version           int32
 	BeatmapMD5Hash    string
 	PlayerName        string
@@ -122,36 +135,47 @@ type Score struct {
 	ReplayScore       int32
 	MaxCombo          uint16
 	PerfectCombo      bool
-	Mods              int32
+	Mods              Mods
 	Timestamp         time.Time
+	UnknownInt        *int32
 	OnlineScoreId     int64
 	AdditionalModInfo float64
+
+	nulls nullStrings
 }
 
 func ParseCollectionsDB(filename string) (*Collections, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+	return decodeFile(filename, decodeCollectionsDB)
+}
+
+// DecodeCollectionsDB reads a collection.db from r.
+func DecodeCollectionsDB(r io.Reader) (*Collections, error) {
+	return decodeCollectionsDB(r, "")
+}
+
+// ParseCollectionsDBFS reads the collection.db name of fsys.
+func ParseCollectionsDBFS(fsys fs.FS, name string) (*Collections, error) {
+	return decodeFS(fsys, name, decodeCollectionsDB)
+}
 
-	reader := bufio.NewReaderSize(file, 128*1024)
+func decodeCollectionsDB(r io.Reader, filename string) (*Collections, error) {
+	d := newDecoder(r)
 
-	version, err := readInt(reader)
+	version, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
 
-	collectionCount, err := readInt(reader)
+	collectionCount, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
 
-	collections := make([]*Collection, 0, collectionCount)
+	collections := make([]*Collection, 0, capacityHint(collectionCount))
 	for i := 0; i < int(collectionCount); i++ {
-		collection, err := readCollection(reader)
+		collection, err := readCollection(d)
 		if err != nil {
-			return nil, err
+			return nil, dbError(filename, d, "collection", i, err)
 		}
 		collections = append(collections, collection)
 	}
@@ -164,24 +188,30 @@ func ParseCollectionsDB(filename string) (*Collections, error) {
 
 }
 
-func readCollection(r io.Reader) (*Collection, error) {
+func readCollection(d *decoder) (*Collection, error) {
+	d.takeNulls()
 
-	name, err := readString(r)
+	name, err := d.readString()
 	if err != nil {
 		return nil, err
 	}
+	nulls := d.takeNulls()
 
-	beatmapCount, err := readInt(r)
+	beatmapCount, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	beatmaps := make([]*string, 0, beatmapCount)
+	beatmaps := make([]*string, 0, capacityHint(beatmapCount))
 	for i := 0; i < int(beatmapCount); i++ {
-		beatmap, err := readString(r)
+		beatmap, err := d.readString()
 		if err != nil {
 			return nil, err
 		}
+		if d.takeNulls() != 0 {
+			beatmaps = append(beatmaps, nil)
+			continue
+		}
 		beatmaps = append(beatmaps, &beatmap)
 	}
 
@@ -189,34 +219,43 @@ func readCollection(r io.Reader) (*Collection, error) {
 		Name:             name,
 		NumberOfBeatmaps: beatmapCount,
 		Beatmaps:         beatmaps,
+		nulls:            nulls,
 	}, nil
 
 }
 
 func ParseScoresDB(filename string) (*Scores, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+	return decodeFile(filename, decodeScoresDB)
+}
+
+// DecodeScoresDB reads a scores.db from r.
+func DecodeScoresDB(r io.Reader) (*Scores, error) {
+	return decodeScoresDB(r, "")
+}
+
+// ParseScoresDBFS reads the scores.db name of fsys.
+func ParseScoresDBFS(fsys fs.FS, name string) (*Scores, error) {
+	return decodeFS(fsys, name, decodeScoresDB)
+}
 
-	reader := bufio.NewReaderSize(file, 128*1024)
+func decodeScoresDB(r io.Reader, filename string) (*Scores, error) {
+	d := newDecoder(r)
 
-	version, err := readInt(reader)
+	version, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
 
-	scoreCount, err := readInt(reader)
+	scoreCount, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
 
-	beatmaps := make([]*BeatmapScores, 0, scoreCount)
+	beatmaps := make([]*BeatmapScores, 0, capacityHint(scoreCount))
 	for i := 0; i < int(scoreCount); i++ {
-		beatmap, err := readBeatmapScore(reader)
+		beatmap, err := readBeatmapScore(d, filename)
 		if err != nil {
-			return nil, err
+			return nil, dbError(filename, d, "beatmap", i, err)
 		}
 		beatmaps = append(beatmaps, beatmap)
 	}
@@ -229,22 +268,27 @@ func ParseScoresDB(filename string) (*Scores, error) {
 
 }
 
-func readBeatmapScore(r io.Reader) (*BeatmapScores, error) {
-	hash, err := readString(r)
+// readBeatmapScore reads the scores of a beatmap. Errors in a score are
+// returned as a ParseError of the score, filename is the file for them.
+func readBeatmapScore(d *decoder, filename string) (*BeatmapScores, error) {
+	d.takeNulls()
+
+	hash, err := d.readString()
 	if err != nil {
 		return nil, err
 	}
+	nulls := d.takeNulls()
 
-	scoreCount, err := readInt(r)
+	scoreCount, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	scores := make([]*Score, 0, scoreCount)
+	scores := make([]*Score, 0, capacityHint(scoreCount))
 	for i := 0; i < int(scoreCount); i++ {
-		score, err := readScore(r)
+		score, err := readScore(d)
 		if err != nil {
-			return nil, err
+			return nil, dbError(filename, d, "score", i, err)
 		}
 		scores = append(scores, score)
 	}
@@ -253,109 +297,114 @@ func readBeatmapScore(r io.Reader) (*BeatmapScores, error) {
 		BeatmapMD5Hash: hash,
 		NumberOfScores: scoreCount,
 		Scores:         scores,
+		nulls:          nulls,
 	}, nil
 
 }
 
-func readScore(r io.Reader) (*Score, error) {
+func readScore(d *decoder) (*Score, error) {
+	d.takeNulls()
 
-	gamemode, err := readByte(r)
+	gamemode, err := d.readByte()
 	if err != nil {
 		return nil, err
 	}
 
-	version, err := readInt(r)
+	version, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	beatmapMD5Hash, err := readString(r)
+	beatmapMD5Hash, err := d.readString()
 	if err != nil {
 		return nil, err
 	}
 
-	playername, err := readString(r)
+	playername, err := d.readInternedString()
 	if err != nil {
 		return nil, err
 	}
 
-	replayMD5Hash, err := readString(r)
+	replayMD5Hash, err := d.readString()
 	if err != nil {
 		return nil, err
 	}
 
-	count300, err := readShort(r)
+	count300, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	count100, err := readShort(r)
+	count100, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	count50, err := readShort(r)
+	count50, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	gekis, err := readShort(r)
+	gekis, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	katus, err := readShort(r)
+	katus, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	countMiss, err := readShort(r)
+	countMiss, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	replayScore, err := readInt(r)
+	replayScore, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
-	maxcombo, err := readShort(r)
+	maxcombo, err := d.readShort()
 	if err != nil {
 		return nil, err
 	}
 
-	perfectCombo, err := readBoolean(r)
+	perfectCombo, err := d.readBoolean()
 	if err != nil {
 		return nil, err
 	}
 
-	mods, err := readInt(r)
+	mods, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
 	//EmptyString
-	_, _ = readString(r)
+	if err := d.skipStrings(1); err != nil {
+		return nil, err
+	}
 
-	ticks, err := readLong(r)
+	ticks, err := d.readLong()
 	if err != nil {
 		return nil, err
 	}
 	timestamp := readDateTime(ticks)
 
 	//-1
-	_, err = readInt(r)
+	unknownInt, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	onlineScoreId, err := readLong(r)
+	onlineScoreId, err := d.readLong()
 	if err != nil {
 		return nil, err
 	}
 
 	var additionalModInfo float64
-	if mods<<23 == 1 {
-		additionalModInfo, err = readDouble(r)
+	//TargetPractice
+	if Mods(mods).Has(ModTargetPractice) {
+		additionalModInfo, err = d.readDouble()
 		if err != nil {
 			return nil, err
 		}
@@ -363,7 +412,7 @@ func readScore(r io.Reader) (*Score, error) {
 	}
 
 	return &Score{
-		Gamemode:          gamemode,
+		Gamemode:          GameMode(gamemode),
 		Version:           version,
 		BeatmapMD5Hash:    beatmapMD5Hash,
 		PlayerName:        playername,
@@ -377,453 +426,646 @@ func readScore(r io.Reader) (*Score, error) {
 		ReplayScore:       replayScore,
 		MaxCombo:          maxcombo,
 		PerfectCombo:      perfectCombo,
-		Mods:              mods,
+		Mods:              Mods(mods),
 		Timestamp:         timestamp,
+		UnknownInt:        &unknownInt,
 		OnlineScoreId:     onlineScoreId,
 		AdditionalModInfo: additionalModInfo,
+		nulls:             d.takeNulls(),
 	}, nil
 }
 
-func readBeatmap(r io.Reader, version int32) (*Beatmap, error) {
+func readBeatmap(d *decoder, version int32) (*Beatmap, error) {
 	beatmap := &Beatmap{}
+	if err := readBeatmapFields(d, version, BeatmapAllFields, beatmap); err != nil {
+		return nil, err
+	}
+	return beatmap, nil
+}
+
+// readBeatmapFields reads a beatmap into beatmap, only decoding the groups of
+// fields and skipping over the others.
+func readBeatmapFields(d *decoder, version int32, fields BeatmapFields, beatmap *Beatmap) error {
+	d.takeNulls()
 
 	if version < 20191106 {
-		sizeInBytes, err := readInt(r)
+		sizeInBytes, err := d.readInt()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.SizeInBytes = &sizeInBytes
 	}
 
-	artist, err := readString(r)
+	var err error
+	if fields&BeatmapMetadata != 0 {
+		err = readBeatmapMetadata(d, beatmap)
+	} else {
+		err = skipBeatmapMetadata(d, nil)
+	}
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if fields&BeatmapStats != 0 {
+		err = readBeatmapStats(d, beatmap, version)
+	} else {
+		err = skipBeatmapStats(d, version)
+	}
+	if err != nil {
+		return err
+	}
+
+	if version >= 20140609 {
+		if fields&BeatmapStarRatings != 0 {
+			err = readBeatmapStarRatings(d, beatmap, version)
+		} else {
+			err = skipStarRatings(d, version)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if fields&BeatmapTiming != 0 {
+		err = readBeatmapTiming(d, beatmap)
+	} else {
+		err = skipBeatmapTiming(d)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fields&BeatmapOnline != 0 {
+		err = readBeatmapOnline(d, beatmap)
+	} else {
+		err = skipBeatmapOnline(d)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fields&BeatmapLocal != 0 {
+		err = readBeatmapLocal(d, beatmap, version)
+	} else {
+		err = skipBeatmapLocal(d, version, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	beatmap.nulls = d.takeNulls()
+	return nil
+}
+
+func readBeatmapMetadata(d *decoder, beatmap *Beatmap) error {
+	artist, err := d.readInternedString()
+	if err != nil {
+		return err
 	}
 	beatmap.Artist = artist
 
-	artistUnicode, err := readString(r)
+	artistUnicode, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.ArtistUnicode = artistUnicode
 
-	songTitle, err := readString(r)
+	songTitle, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.SongTitle = songTitle
 
-	songTitleUnicode, err := readString(r)
+	songTitleUnicode, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.SongTitleUnicode = songTitleUnicode
 
-	creator, err := readString(r)
+	creator, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.Creator = creator
 
-	difficulty, err := readString(r)
+	difficulty, err := d.readString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.Difficulty = difficulty
 
-	audioFileName, err := readString(r)
+	audioFileName, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.AudioFileName = audioFileName
 
-	md5Hash, err := readString(r)
+	md5Hash, err := d.readString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.MD5Hash = md5Hash
 
-	osuFileName, err := readString(r)
+	osuFileName, err := d.readString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.FileName = osuFileName
+	return nil
+}
 
-	var rankedStatus byte
-	if err := binary.Read(r, binary.LittleEndian, &rankedStatus); err != nil {
-		return nil, err
+func readBeatmapStats(d *decoder, beatmap *Beatmap, version int32) error {
+	rankedStatus, err := d.readByte()
+	if err != nil {
+		return err
 	}
-	beatmap.RankedStatus = rankedStatus
+	beatmap.RankedStatus = RankedStatus(rankedStatus)
 
-	numberOfHitCircles, err := readShort(r)
+	numberOfHitCircles, err := d.readShort()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.NumberOfHitCircles = numberOfHitCircles
 
-	numberOfSliders, err := readShort(r)
+	numberOfSliders, err := d.readShort()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.NumberOfSliders = numberOfSliders
 
-	numberOfSpinners, err := readShort(r)
+	numberOfSpinners, err := d.readShort()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.NumberOfSpinners = numberOfSpinners
 
-	lastModificationTicks, err := readLong(r)
+	lastModificationTicks, err := d.readLong()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.LastModificationTime = lastModificationTicks
 
 	if version < 20140609 {
-		arByte, err := readShort(r)
+		arByte, err := d.readShort()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		arFloat := float32(arByte)
 		beatmap.ApproachRate = arFloat
 
-		csByte, err := readShort(r)
+		csByte, err := d.readShort()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		csFloat := float32(csByte)
 		beatmap.CircleSize = csFloat
 
-		hpDrainByte, err := readShort(r)
+		hpDrainByte, err := d.readShort()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		hpDrainFloat := float32(hpDrainByte)
 		beatmap.HPDrain = hpDrainFloat
 
-		odByte, err := readShort(r)
+		odByte, err := d.readShort()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		odFloat := float32(odByte)
 		beatmap.OverallDifficulty = odFloat
 	} else {
-		ar, err := readSingle(r)
+		ar, err := d.readSingle()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.ApproachRate = ar
 
-		cs, err := readSingle(r)
+		cs, err := d.readSingle()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.CircleSize = cs
 
-		hpDrain, err := readSingle(r)
+		hpDrain, err := d.readSingle()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.HPDrain = hpDrain
 
-		od, err := readSingle(r)
+		od, err := d.readSingle()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.OverallDifficulty = od
 	}
 
-	sliderVelocity, err := readDouble(r)
+	sliderVelocity, err := d.readDouble()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.SliderVelocity = sliderVelocity
+	return nil
+}
 
-	if version >= 20140609 {
-		stdStars, err := readIntDoublePairs(r)
-		if err != nil {
-			return nil, err
-		}
-		beatmap.StarRatingsStandard = stdStars
-
-		taikoStars, err := readIntDoublePairs(r)
-		if err != nil {
-			return nil, err
-		}
-		beatmap.StarRatingsTaiko = taikoStars
-
-		ctbStars, err := readIntDoublePairs(r)
-		if err != nil {
-			return nil, err
-		}
-		beatmap.StarRatingsCTB = ctbStars
+func readBeatmapStarRatings(d *decoder, beatmap *Beatmap, version int32) error {
+	modes := []*map[Mods]float64{
+		&beatmap.StarRatingsStandard,
+		&beatmap.StarRatingsTaiko,
+		&beatmap.StarRatingsCTB,
+		&beatmap.StarRatingsMania,
+	}
 
-		maniaStars, err := readIntDoublePairs(r)
+	for i, mode := range modes {
+		stars, keys, err := d.readStarRatings(version >= starRatingFloatVersion)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		beatmap.StarRatingsMania = maniaStars
+		*mode = stars
+		beatmap.starRatingKeys[i] = keys
 	}
 
-	drainTime, err := readInt(r)
+	return nil
+}
+
+func readBeatmapTiming(d *decoder, beatmap *Beatmap) error {
+	drainTime, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.DrainTime = drainTime
 
-	totalTime, err := readInt(r)
+	totalTime, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.TotalTime = totalTime
 
-	audioPreviewStartTime, err := readInt(r)
+	audioPreviewStartTime, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.AudioPreviewStartTime = audioPreviewStartTime
 
-	timingPoints, err := readTimingPoints(r)
+	timingPoints, err := readTimingPoints(d)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.TimingPoints = timingPoints
+	return nil
+}
 
-	difficultyID, err := readInt(r)
+func readBeatmapOnline(d *decoder, beatmap *Beatmap) error {
+	difficultyID, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.DifficultyID = difficultyID
 
-	beatmapID, err := readInt(r)
+	beatmapID, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.BeatmapID = beatmapID
 
-	threadID, err := readInt(r)
+	threadID, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.ThreadID = threadID
 
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.GradeStandard); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.GradeTaiko); err != nil {
-		return nil, err
+	grades := []*Grade{
+		&beatmap.GradeStandard,
+		&beatmap.GradeTaiko,
+		&beatmap.GradeCTB,
+		&beatmap.GradeMania,
 	}
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.GradeCTB); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.GradeMania); err != nil {
-		return nil, err
+	for _, grade := range grades {
+		g, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		*grade = Grade(g)
 	}
 
-	localOffset, err := readShort(r)
+	localOffset, err := d.readShort()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.LocalBeatmapOffset = localOffset
 
-	stackLeniency, err := readSingle(r)
+	stackLeniency, err := d.readSingle()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.StackLeniency = stackLeniency
 
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.GameplayMode); err != nil {
-		return nil, err
+	gameplayMode, err := d.readByte()
+	if err != nil {
+		return err
 	}
+	beatmap.GameplayMode = GameMode(gameplayMode)
 
-	songSource, err := readString(r)
+	songSource, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.SongSource = songSource
 
-	songTags, err := readString(r)
+	songTags, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.SongTags = songTags
 
-	onlineOffset, err := readShortSigned(r)
+	onlineOffset, err := d.readShortSigned()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.OnlineOffset = onlineOffset
 
-	font, err := readString(r)
+	font, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.Font = font
+	return nil
+}
 
-	isUnplayed, err := readBoolean(r)
+func readBeatmapLocal(d *decoder, beatmap *Beatmap, version int32) error {
+	isUnplayed, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.IsUnplayed = isUnplayed
 
-	lastPlayed, err := readLong(r)
+	lastPlayed, err := d.readLong()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.LastPlayed = lastPlayed
 
-	isOsz2, err := readBoolean(r)
+	isOsz2, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.IsOsz2 = isOsz2
 
-	folderName, err := readString(r)
+	folderName, err := d.readInternedString()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.FolderName = folderName
 
-	lastChecked, err := readLong(r)
+	lastChecked, err := d.readLong()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.LastChecked = lastChecked
 
-	ignoreBeatmapSound, err := readBoolean(r)
+	ignoreBeatmapSound, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.IgnoreBeatmapSound = ignoreBeatmapSound
 
-	ignoreBeatmapSkin, err := readBoolean(r)
+	ignoreBeatmapSkin, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.IgnoreBeatmapSkin = ignoreBeatmapSkin
 
-	disableStoryboard, err := readBoolean(r)
+	disableStoryboard, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.DisableStoryboard = disableStoryboard
 
-	disableVideo, err := readBoolean(r)
+	disableVideo, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.DisableVideo = disableVideo
 
-	visualOverride, err := readBoolean(r)
+	visualOverride, err := d.readBoolean()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.VisualOverride = visualOverride
 
 	if version < 20140609 {
-		unknownShort, err := readShort(r)
+		unknownShort, err := d.readShort()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		beatmap.UnknownShort = &unknownShort
 	}
 
-	lastModTime2, err := readInt(r)
+	lastModTime2, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	beatmap.LastModificationTime2 = lastModTime2
 
-	if err := binary.Read(r, binary.LittleEndian, &beatmap.ManiaScrollSpeed); err != nil {
-		return nil, err
+	maniaScrollSpeed, err := d.readByte()
+	if err != nil {
+		return err
 	}
-
-	return beatmap, nil
+	beatmap.ManiaScrollSpeed = maniaScrollSpeed
+	return nil
 }
 
-func readTimingPoints(r io.Reader) ([]TimingPoint, error) {
-	count, err := readInt(r)
+// skipBeatmapMetadata skips the metadata strings, reading the MD5 hash into
+// md5Hash unless it's nil.
+func skipBeatmapMetadata(d *decoder, md5Hash *string) error {
+	if md5Hash == nil {
+		return d.skipStrings(9)
+	}
+
+	// artist, title and their unicode versions, creator, difficulty and
+	// audio file name
+	if err := d.skipStrings(7); err != nil {
+		return err
+	}
+	hash, err := d.readString()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	*md5Hash = hash
+	// .osu file name
+	return d.skipStrings(1)
+}
 
-	timingPoints := make([]TimingPoint, 0, count)
-	for i := 0; i < int(count); i++ {
-		bpm, err := readDouble(r)
+func skipBeatmapStats(d *decoder, version int32) error {
+	if version < 20140609 {
+		// difficulty settings are shorts instead of singles
+		return d.skip(1 + 3*2 + 8 + 4*2 + 8)
+	}
+	return d.skip(1 + 3*2 + 8 + 4*4 + 8)
+}
+
+func skipStarRatings(d *decoder, version int32) error {
+	pairSize := 1 + 4 + 1 + 8
+	if version >= starRatingFloatVersion {
+		pairSize = 1 + 4 + 1 + 4
+	}
+	for range 4 {
+		count, err := d.readInt()
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := d.skip(int(max(count, 0)) * pairSize); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		offset, err := readDouble(r)
+func skipBeatmapTiming(d *decoder) error {
+	// drain time, total time and audio preview time
+	if err := d.skip(3 * 4); err != nil {
+		return err
+	}
+	count, err := d.readInt()
+	if err != nil {
+		return err
+	}
+	return d.skip(int(max(count, 0)) * (8 + 8 + 1))
+}
+
+func skipBeatmapOnline(d *decoder) error {
+	// ids, grades, local offset, stack leniency and gameplay mode
+	if err := d.skip(3*4 + 4 + 2 + 4 + 1); err != nil {
+		return err
+	}
+	// source and tags
+	if err := d.skipStrings(2); err != nil {
+		return err
+	}
+	if err := d.skip(2); err != nil {
+		return err
+	}
+	// font
+	return d.skipStrings(1)
+}
+
+// skipBeatmapLocal skips the local fields, reading the folder name into
+// folderName unless it's nil.
+func skipBeatmapLocal(d *decoder, version int32, folderName *string) error {
+	// unplayed, last played and osz2
+	if err := d.skip(1 + 8 + 1); err != nil {
+		return err
+	}
+	if folderName == nil {
+		if err := d.skipStrings(1); err != nil {
+			return err
+		}
+	} else {
+		name, err := d.readString()
 		if err != nil {
-			return nil, err
+			return err
 		}
+		*folderName = name
+	}
+	// last checked, the five override flags, the unknown short of old
+	// versions, the second modification time and mania scroll speed
+	size := 8 + 5 + 4 + 1
+	if version < 20140609 {
+		size += 2
+	}
+	return d.skip(size)
+}
+
+func readTimingPoints(d *decoder) ([]TimingPoint, error) {
+	count, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
 
-		inherited, err := readBoolean(r)
+	timingPoints := make([]TimingPoint, 0, capacityHint(count))
+	for i := 0; i < int(count); i++ {
+		// bpm, offset, inherited
+		b, err := d.next(8 + 8 + 1)
 		if err != nil {
 			return nil, err
 		}
 
 		timingPoints = append(timingPoints, TimingPoint{
-			BPM:       bpm,
-			Offset:    offset,
-			Inherited: inherited,
+			BPM:       math.Float64frombits(binary.LittleEndian.Uint64(b[0:8])),
+			Offset:    math.Float64frombits(binary.LittleEndian.Uint64(b[8:16])),
+			Inherited: b[16] != 0x00,
 		})
 	}
 	return timingPoints, nil
 }
 
 func ParseOsuDB(filename string) (*OsuDB, error) {
-	file, err := os.Open(filename)
+	return decodeFile(filename, decodeOsuDB)
+}
+
+// DecodeOsuDB reads a osu!.db from r.
+func DecodeOsuDB(r io.Reader) (*OsuDB, error) {
+	return decodeOsuDB(r, "")
+}
+
+// ParseOsuDBFS reads the osu!.db name of fsys.
+func ParseOsuDBFS(fsys fs.FS, name string) (*OsuDB, error) {
+	return decodeFS(fsys, name, decodeOsuDB)
+}
+
+func decodeOsuDB(r io.Reader, filename string) (*OsuDB, error) {
+	d := newDecoder(r)
+
+	db, err := readOsuDBHeader(d)
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
-	defer file.Close()
 
-	reader := bufio.NewReaderSize(file, 128*1024)
+	db.Beatmaps = make([]*Beatmap, 0, capacityHint(db.NumberOfBeatmaps))
+	for i := 0; i < int(db.NumberOfBeatmaps); i++ {
+		beatmap, err := readBeatmap(d, db.Version)
+		if err != nil {
+			return nil, dbError(filename, d, "beatmap", i, err)
+		}
+		db.Beatmaps = append(db.Beatmaps, beatmap)
+	}
 
-	version, err := readInt(reader)
+	userPermissions, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, dbError(filename, d, "", 0, err)
 	}
+	db.UserPermissions = Permissions(userPermissions)
 
-	folderCount, err := readInt(reader)
+	return db, nil
+}
+
+// readOsuDBHeader reads the fields of an osu!.db before the beatmaps.
+func readOsuDBHeader(d *decoder) (*OsuDB, error) {
+	version, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	accountUnlocked, err := readBoolean(reader)
+	folderCount, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
 
-	var unlockDate time.Time
-	ticks, err := readLong(reader)
+	accountUnlocked, err := d.readBoolean()
 	if err != nil {
 		return nil, err
 	}
-	unlockDate = readDateTime(ticks)
 
-	playerName, err := readString(reader)
+	ticks, err := d.readLong()
 	if err != nil {
 		return nil, err
 	}
 
-	numberOfBeatmaps, err := readInt(reader)
+	playerName, err := d.readString()
 	if err != nil {
 		return nil, err
 	}
 
-	beatmaps := make([]*Beatmap, 0, numberOfBeatmaps)
-	for i := 0; i < int(numberOfBeatmaps); i++ {
-		beatmap, err := readBeatmap(reader, version)
-		if err != nil {
-			return nil, err
-		}
-		beatmaps = append(beatmaps, beatmap)
-	}
-
-	userPermissions, err := readInt(reader)
+	numberOfBeatmaps, err := d.readInt()
 	if err != nil {
 		return nil, err
 	}
@@ -832,10 +1074,518 @@ func ParseOsuDB(filename string) (*OsuDB, error) {
 		Version:          version,
 		FolderCount:      folderCount,
 		AccountUnlocked:  accountUnlocked,
-		UnlockDate:       unlockDate,
+		UnlockDate:       readDateTime(ticks),
 		PlayerName:       playerName,
 		NumberOfBeatmaps: numberOfBeatmaps,
-		Beatmaps:         beatmaps,
-		UserPermissions:  userPermissions,
+		nulls:            d.takeNulls(),
 	}, nil
 }
+
+func WriteCollectionsDB(filename string, collections *Collections) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return writeCollections(w, collections)
+	})
+}
+
+func writeCollections(w io.Writer, collections *Collections) error {
+	if err := writeInt(w, collections.Version); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, int32(len(collections.Collections))); err != nil {
+		return err
+	}
+
+	for _, collection := range collections.Collections {
+		if err := writeCollection(w, collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCollection(w io.Writer, collection *Collection) error {
+	if err := writeNullableString(w, collection.Name, collection.nulls.has(0)); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, int32(len(collection.Beatmaps))); err != nil {
+		return err
+	}
+
+	for _, hash := range collection.Beatmaps {
+		var err error
+		if hash == nil {
+			err = writeByte(w, 0x00)
+		} else {
+			err = writeString(w, *hash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteScoresDB(filename string, scores *Scores) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return writeScores(w, scores)
+	})
+}
+
+func writeScores(w io.Writer, scores *Scores) error {
+	if err := writeInt(w, scores.Version); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, int32(len(scores.Beatmaps))); err != nil {
+		return err
+	}
+
+	for _, beatmap := range scores.Beatmaps {
+		if err := writeBeatmapScore(w, beatmap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBeatmapScore(w io.Writer, beatmap *BeatmapScores) error {
+	if err := writeNullableString(w, beatmap.BeatmapMD5Hash, beatmap.nulls.has(0)); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, int32(len(beatmap.Scores))); err != nil {
+		return err
+	}
+
+	for _, score := range beatmap.Scores {
+		if err := writeScore(w, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeScore(w io.Writer, score *Score) error {
+	if err := writeByte(w, byte(score.Gamemode)); err != nil {
+		return err
+	}
+	if err := writeInt(w, score.Version); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, score.BeatmapMD5Hash, score.nulls.has(0)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, score.PlayerName, score.nulls.has(1)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, score.ReplayMD5Hash, score.nulls.has(2)); err != nil {
+		return err
+	}
+
+	counts := []uint16{
+		score.Count300s,
+		score.Count100s,
+		score.Count50,
+		score.Gekis,
+		score.Katus,
+		score.CountMiss,
+	}
+	for _, count := range counts {
+		if err := writeShort(w, count); err != nil {
+			return err
+		}
+	}
+
+	if err := writeInt(w, score.ReplayScore); err != nil {
+		return err
+	}
+	if err := writeShort(w, score.MaxCombo); err != nil {
+		return err
+	}
+	if err := writeBoolean(w, score.PerfectCombo); err != nil {
+		return err
+	}
+	if err := writeInt(w, int32(score.Mods)); err != nil {
+		return err
+	}
+
+	//EmptyString
+	if err := writeNullableString(w, "", score.nulls.has(3)); err != nil {
+		return err
+	}
+
+	if err := writeLong(w, writeDateTime(score.Timestamp)); err != nil {
+		return err
+	}
+
+	unknownInt := int32(-1)
+	if score.UnknownInt != nil {
+		unknownInt = *score.UnknownInt
+	}
+	if err := writeInt(w, unknownInt); err != nil {
+		return err
+	}
+
+	if err := writeLong(w, score.OnlineScoreId); err != nil {
+		return err
+	}
+
+	//TargetPractice
+	if score.Mods.Has(ModTargetPractice) {
+		if err := writeDouble(w, score.AdditionalModInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteOsuDB(filename string, db *OsuDB) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return writeOsuDB(w, db)
+	})
+}
+
+func writeOsuDB(w io.Writer, db *OsuDB) error {
+	if err := writeInt(w, db.Version); err != nil {
+		return err
+	}
+	if err := writeInt(w, db.FolderCount); err != nil {
+		return err
+	}
+	if err := writeBoolean(w, db.AccountUnlocked); err != nil {
+		return err
+	}
+	if err := writeLong(w, writeDateTime(db.UnlockDate)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, db.PlayerName, db.nulls.has(0)); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, int32(len(db.Beatmaps))); err != nil {
+		return err
+	}
+
+	var entry bytes.Buffer
+	for _, beatmap := range db.Beatmaps {
+		if db.Version >= 20191106 {
+			if err := writeBeatmap(w, beatmap, db.Version); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// older versions prefix every entry with its size in bytes
+		entry.Reset()
+		if err := writeBeatmap(&entry, beatmap, db.Version); err != nil {
+			return err
+		}
+		if err := writeInt(w, int32(entry.Len())); err != nil {
+			return err
+		}
+		if _, err := entry.WriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	return writeInt(w, int32(db.UserPermissions))
+}
+
+// writeBeatmap writes a beatmap entry without the leading SizeInBytes.
+func writeBeatmap(w io.Writer, beatmap *Beatmap, version int32) error {
+	strs := []string{
+		beatmap.Artist,
+		beatmap.ArtistUnicode,
+		beatmap.SongTitle,
+		beatmap.SongTitleUnicode,
+		beatmap.Creator,
+		beatmap.Difficulty,
+		beatmap.AudioFileName,
+		beatmap.MD5Hash,
+		beatmap.FileName,
+	}
+	for i, str := range strs {
+		if err := writeNullableString(w, str, beatmap.nulls.has(i)); err != nil {
+			return err
+		}
+	}
+
+	if err := writeByte(w, byte(beatmap.RankedStatus)); err != nil {
+		return err
+	}
+	if err := writeShort(w, beatmap.NumberOfHitCircles); err != nil {
+		return err
+	}
+	if err := writeShort(w, beatmap.NumberOfSliders); err != nil {
+		return err
+	}
+	if err := writeShort(w, beatmap.NumberOfSpinners); err != nil {
+		return err
+	}
+	if err := writeLong(w, beatmap.LastModificationTime); err != nil {
+		return err
+	}
+
+	difficulty := []float32{
+		beatmap.ApproachRate,
+		beatmap.CircleSize,
+		beatmap.HPDrain,
+		beatmap.OverallDifficulty,
+	}
+	for _, value := range difficulty {
+		var err error
+		if version < 20140609 {
+			err = writeShort(w, uint16(value))
+		} else {
+			err = writeSingle(w, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeDouble(w, beatmap.SliderVelocity); err != nil {
+		return err
+	}
+
+	if version >= 20140609 {
+		starRatings := []map[Mods]float64{
+			beatmap.StarRatingsStandard,
+			beatmap.StarRatingsTaiko,
+			beatmap.StarRatingsCTB,
+			beatmap.StarRatingsMania,
+		}
+		for i, stars := range starRatings {
+			if err := writeStarRatings(w, stars, beatmap.starRatingKeys[i], version); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeInt(w, beatmap.DrainTime); err != nil {
+		return err
+	}
+	if err := writeInt(w, beatmap.TotalTime); err != nil {
+		return err
+	}
+	if err := writeInt(w, beatmap.AudioPreviewStartTime); err != nil {
+		return err
+	}
+
+	if err := writeTimingPoints(w, beatmap.TimingPoints); err != nil {
+		return err
+	}
+
+	if err := writeInt(w, beatmap.DifficultyID); err != nil {
+		return err
+	}
+	if err := writeInt(w, beatmap.BeatmapID); err != nil {
+		return err
+	}
+	if err := writeInt(w, beatmap.ThreadID); err != nil {
+		return err
+	}
+
+	grades := []Grade{
+		beatmap.GradeStandard,
+		beatmap.GradeTaiko,
+		beatmap.GradeCTB,
+		beatmap.GradeMania,
+	}
+	for _, grade := range grades {
+		if err := writeByte(w, byte(grade)); err != nil {
+			return err
+		}
+	}
+
+	if err := writeShort(w, beatmap.LocalBeatmapOffset); err != nil {
+		return err
+	}
+	if err := writeSingle(w, beatmap.StackLeniency); err != nil {
+		return err
+	}
+	if err := writeByte(w, byte(beatmap.GameplayMode)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, beatmap.SongSource, beatmap.nulls.has(9)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, beatmap.SongTags, beatmap.nulls.has(10)); err != nil {
+		return err
+	}
+	if err := writeShortSigned(w, beatmap.OnlineOffset); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, beatmap.Font, beatmap.nulls.has(11)); err != nil {
+		return err
+	}
+	if err := writeBoolean(w, beatmap.IsUnplayed); err != nil {
+		return err
+	}
+	if err := writeLong(w, beatmap.LastPlayed); err != nil {
+		return err
+	}
+	if err := writeBoolean(w, beatmap.IsOsz2); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, beatmap.FolderName, beatmap.nulls.has(12)); err != nil {
+		return err
+	}
+	if err := writeLong(w, beatmap.LastChecked); err != nil {
+		return err
+	}
+
+	flags := []bool{
+		beatmap.IgnoreBeatmapSound,
+		beatmap.IgnoreBeatmapSkin,
+		beatmap.DisableStoryboard,
+		beatmap.DisableVideo,
+		beatmap.VisualOverride,
+	}
+	for _, flag := range flags {
+		if err := writeBoolean(w, flag); err != nil {
+			return err
+		}
+	}
+
+	if version < 20140609 {
+		var unknownShort uint16
+		if beatmap.UnknownShort != nil {
+			unknownShort = *beatmap.UnknownShort
+		}
+		if err := writeShort(w, unknownShort); err != nil {
+			return err
+		}
+	}
+
+	if err := writeInt(w, beatmap.LastModificationTime2); err != nil {
+		return err
+	}
+
+	return writeByte(w, beatmap.ManiaScrollSpeed)
+}
+
+func writeTimingPoints(w io.Writer, timingPoints []TimingPoint) error {
+	if err := writeInt(w, int32(len(timingPoints))); err != nil {
+		return err
+	}
+
+	for _, timingPoint := range timingPoints {
+		if err := writeDouble(w, timingPoint.BPM); err != nil {
+			return err
+		}
+		if err := writeDouble(w, timingPoint.Offset); err != nil {
+			return err
+		}
+		if err := writeBoolean(w, timingPoint.Inherited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// starRatingFloatVersion is the first osu!.db version that stores star
+// ratings as Int-Float pairs instead of Int-Double pairs.
+const starRatingFloatVersion = 20250107
+
+// starRatingOrder is the order in which osu! writes the cached mod
+// combinations: None, DT, HT, HR, HRDT, HRHT, EZ, EZDT, EZHT.
+var starRatingOrder = []Mods{
+	0,
+	ModDoubleTime,
+	ModHalfTime,
+	ModHardRock,
+	ModHardRock | ModDoubleTime,
+	ModHardRock | ModHalfTime,
+	ModEasy,
+	ModEasy | ModDoubleTime,
+	ModEasy | ModHalfTime,
+}
+
+// writeStarRatings writes the star ratings of a mode in the order of keys if
+// they are the keys of stars, or else in starRatingOrder followed by other mod
+// combinations in ascending order.
+func writeStarRatings(w io.Writer, stars map[Mods]float64, keys []Mods, version int32) error {
+	if !sameKeys(keys, stars) {
+		keys = starRatingKeys(stars)
+	}
+
+	if version >= starRatingFloatVersion {
+		return writeIntFloatPairs(w, keys, stars)
+	}
+	return writeIntDoublePairs(w, keys, stars)
+}
+
+// starRatingKeys returns the mod combinations of stars in the order osu!
+// writes them.
+func starRatingKeys(stars map[Mods]float64) []Mods {
+	keys := make([]Mods, 0, len(stars))
+	for _, mods := range starRatingOrder {
+		if _, ok := stars[mods]; ok {
+			keys = append(keys, mods)
+		}
+	}
+	rest := make([]Mods, 0)
+	for mods := range stars {
+		if !slices.Contains(starRatingOrder, mods) {
+			rest = append(rest, mods)
+		}
+	}
+	slices.Sort(rest)
+	return append(keys, rest...)
+}
+
+// inStarRatingOrder reports whether keys are in the order starRatingKeys
+// returns them.
+func inStarRatingOrder(keys []Mods) bool {
+	next, rest := 0, false
+	var last Mods
+	for _, mods := range keys {
+		if i := slices.Index(starRatingOrder, mods); i >= 0 {
+			if rest || i < next {
+				return false
+			}
+			next = i + 1
+			continue
+		}
+		if rest && mods <= last {
+			return false
+		}
+		rest, last = true, mods
+	}
+	return true
+}
+
+// sameKeys reports whether keys are the keys of stars, each once.
+func sameKeys(keys []Mods, stars map[Mods]float64) bool {
+	if keys == nil || len(keys) != len(stars) {
+		return false
+	}
+	for i, mods := range keys {
+		if _, ok := stars[mods]; !ok || slices.Contains(keys[:i], mods) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stars returns the cached star rating of the beatmap for a game mode and
+// mod combination. Mods that do not affect difficulty are ignored.
+func (b *Beatmap) Stars(mode GameMode, mods Mods) (float64, bool) {
+	var stars map[Mods]float64
+	switch mode {
+	case ModeStandard:
+		stars = b.StarRatingsStandard
+	case ModeTaiko:
+		stars = b.StarRatingsTaiko
+	case ModeCatch:
+		stars = b.StarRatingsCTB
+	case ModeMania:
+		stars = b.StarRatingsMania
+	}
+
+	rating, ok := stars[mods.DifficultyMods()]
+	return rating, ok
+}
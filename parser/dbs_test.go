@@ -0,0 +1,236 @@
+package osuParser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+func roundTrip[T any](t *testing.T, filename string, decode func(io.Reader) (T, error), encode func(io.Writer, T) error) T {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+
+	if got := buf.Bytes(); !bytes.Equal(got, data) {
+		i := 0
+		for i < len(got) && i < len(data) && got[i] == data[i] {
+			i++
+		}
+		t.Errorf("%s: written file differs at byte %d, got %d bytes, want %d", filename, i, len(got), len(data))
+	}
+	return v
+}
+
+func TestOsuDBRoundTrip(t *testing.T) {
+	tests := []struct {
+		version      int32
+		sizeInBytes  bool
+		starRatings  int
+		unknownShort bool
+	}{
+		{20140608, true, 0, true},
+		{20140609, true, 9, false},
+		{20191105, true, 9, false},
+		{20191106, false, 9, false},
+		{20250107, false, 9, false},
+	}
+
+	for _, test := range tests {
+		filename := "testdata/osu_" + strconv.Itoa(int(test.version)) + ".db"
+		db := roundTrip(t, filename, DecodeOsuDB, writeOsuDB)
+
+		if db.Version != test.version || len(db.Beatmaps) != 3 {
+			t.Fatalf("%s: version %d with %d beatmaps", filename, db.Version, len(db.Beatmaps))
+		}
+		beatmap := db.Beatmaps[0]
+		if (beatmap.SizeInBytes != nil) != test.sizeInBytes {
+			t.Errorf("%s: SizeInBytes = %v", filename, beatmap.SizeInBytes)
+		}
+		if (beatmap.UnknownShort != nil) != test.unknownShort {
+			t.Errorf("%s: UnknownShort = %v", filename, beatmap.UnknownShort)
+		}
+		if n := len(beatmap.StarRatingsStandard); n != test.starRatings {
+			t.Errorf("%s: %d standard star ratings, want %d", filename, n, test.starRatings)
+		}
+		if beatmap.SongSource != "" || db.Beatmaps[1].ArtistUnicode != "" {
+			t.Errorf("%s: null strings decoded as %q and %q", filename, beatmap.SongSource, db.Beatmaps[1].ArtistUnicode)
+		}
+		if _, ok := db.Beatmaps[1].StarRatingsCTB[ModFlashlight]; ok != (test.starRatings > 0) {
+			t.Errorf("%s: uncommon mod combination cached %v", filename, ok)
+		}
+		if beatmap.ArtistUnicode != "アーティスト" || beatmap.FolderName != "123 Artist - Title" {
+			t.Errorf("%s: strings decoded as %q and %q", filename, beatmap.ArtistUnicode, beatmap.FolderName)
+		}
+	}
+}
+
+func TestScoresDBRoundTrip(t *testing.T) {
+	scores := roundTrip(t, "testdata/scores.db", DecodeScoresDB, writeScores)
+
+	if len(scores.Beatmaps) != 2 {
+		t.Fatalf("got %d beatmaps, want 2", len(scores.Beatmaps))
+	}
+	if n := len(scores.Beatmaps[0].Scores); n != 2 {
+		t.Errorf("got %d scores, want 2", n)
+	}
+	if score := scores.Beatmaps[0].Scores[1]; score.UnknownInt == nil || *score.UnknownInt != 0 {
+		t.Errorf("unknown int %v, want 0", score.UnknownInt)
+	}
+	score := scores.Beatmaps[1].Scores[0]
+	if !score.Mods.Has(ModTargetPractice) || score.AdditionalModInfo != 0.95 {
+		t.Errorf("target practice score has mods %v and extra %v", score.Mods, score.AdditionalModInfo)
+	}
+	if score.BeatmapMD5Hash != scores.Beatmaps[1].BeatmapMD5Hash {
+		t.Errorf("score hash %q, want %q", score.BeatmapMD5Hash, scores.Beatmaps[1].BeatmapMD5Hash)
+	}
+}
+
+func TestCollectionsDBRoundTrip(t *testing.T) {
+	collections := roundTrip(t, "testdata/collection.db", DecodeCollectionsDB, writeCollections)
+
+	if len(collections.Collections) != 3 {
+		t.Fatalf("got %d collections, want 3", len(collections.Collections))
+	}
+	if c := collections.Collections[0]; c.Name != "Favourites" || len(c.Beatmaps) != 2 || c.Beatmaps[0] == nil {
+		t.Errorf("collections[0] = %+v", c)
+	}
+	if c := collections.Collections[1]; c.Name != "" || len(c.Beatmaps) != 1 || c.Beatmaps[0] != nil {
+		t.Errorf("collections[1] = %+v", c)
+	}
+}
+
+func TestWriteEmptyStrings(t *testing.T) {
+	// strings of entries built in code are written as present, like osu! does
+	var buf bytes.Buffer
+	if err := writeCollection(&buf, &Collection{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x0b, 0x00, 0, 0, 0, 0}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestCorruptCounts(t *testing.T) {
+	// counts are read before their entries, so huge counts have to fail at
+	// the end of the file and negative ones mean no entries
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"collections", []byte{0x2e, 0x06, 0x34, 0x01, 0xff, 0xff, 0xff, 0x7f}, true},
+		{"collection beatmaps", []byte{0x2e, 0x06, 0x34, 0x01, 0x01, 0, 0, 0, 0x00, 0xff, 0xff, 0xff, 0x7f}, true},
+		{"negative collections", []byte{0x2e, 0x06, 0x34, 0x01, 0xff, 0xff, 0xff, 0xff}, false},
+		{"negative collection beatmaps", []byte{0x2e, 0x06, 0x34, 0x01, 0x01, 0, 0, 0, 0x00, 0xff, 0xff, 0xff, 0xff}, false},
+	}
+
+	for _, test := range tests {
+		_, err := DecodeCollectionsDB(bytes.NewReader(test.data))
+		var parseErr *ParseError
+		if test.wantErr != errors.As(err, &parseErr) || !test.wantErr && err != nil {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+
+	// the file ends in the first score of a beatmap
+	scores := []byte{0x2e, 0x06, 0x34, 0x01, 0x01, 0, 0, 0, 0x00, 0xff, 0xff, 0xff, 0x7f}
+	_, err := DecodeScoresDB(bytes.NewReader(scores))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Entry != "score" || parseErr.Index != 0 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("scores: got %v, want io.ErrUnexpectedEOF in score 0", err)
+	}
+}
+
+func TestCorruptStringLength(t *testing.T) {
+	// a collection named by a string of 2^31-1 bytes, of which none follow
+	data := []byte{0x2e, 0x06, 0x34, 0x01, 0x01, 0, 0, 0, 0x0b, 0xff, 0xff, 0xff, 0xff, 0x07}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := DecodeCollectionsDB(bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes", n)
+	}
+}
+
+func TestParseDBsFS(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"osu!.db", "scores.db", "collection.db"} {
+		fixture := "testdata/" + name
+		if name == "osu!.db" {
+			fixture = "testdata/osu_20250107.db"
+		}
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["osu!/"+name] = &fstest.MapFile{Data: data}
+		fsys["corrupt/"+name] = &fstest.MapFile{Data: data[:len(data)/2]}
+	}
+
+	db, err := ParseOsuDB("testdata/osu_20250107.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsDB, err := ParseOsuDBFS(fsys, "osu!/osu!.db"); err != nil || !reflect.DeepEqual(fsDB, db) {
+		t.Errorf("osu!.db parsed from the file system differs (%v)", err)
+	}
+
+	scores, err := ParseScoresDB("testdata/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsScores, err := ParseScoresDBFS(fsys, "osu!/scores.db"); err != nil || !reflect.DeepEqual(fsScores, scores) {
+		t.Errorf("scores.db parsed from the file system differs (%v)", err)
+	}
+
+	collections, err := ParseCollectionsDB("testdata/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsCollections, err := ParseCollectionsDBFS(fsys, "osu!/collection.db"); err != nil || !reflect.DeepEqual(fsCollections, collections) {
+		t.Errorf("collection.db parsed from the file system differs (%v)", err)
+	}
+
+	// errors name the file of the file system
+	corrupt := map[string]error{}
+	_, corrupt["corrupt/osu!.db"] = ParseOsuDBFS(fsys, "corrupt/osu!.db")
+	_, corrupt["corrupt/scores.db"] = ParseScoresDBFS(fsys, "corrupt/scores.db")
+	_, corrupt["corrupt/collection.db"] = ParseCollectionsDBFS(fsys, "corrupt/collection.db")
+	for name, err := range corrupt {
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.File != name || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: got %v, want an unexpected EOF of the file", name, err)
+		}
+	}
+
+	if _, err := ParseOsuDBFS(fsys, "missing/osu!.db"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,205 @@
+package osuParser
+
+import (
+	"slices"
+	"testing"
+)
+
+// the star ratings and pp are regression values like in standard_test.go.
+// the great windows and approach rates follow from the osu! formulas, with
+// the rate applied twice to the mania window like osu! does, and taiko and
+// mania stars don't change with HR, HD and FL in osu! either.
+
+// mirrored returns a copy of the beatmap mirrored horizontally.
+func mirrored(f *OsuFile) *OsuFile {
+	g := *f
+	g.HitObjects = slices.Clone(f.HitObjects)
+	for i := range g.HitObjects {
+		h := &g.HitObjects[i]
+		h.X = 512 - h.X
+		if slider, ok := h.ObjectParams.(*Slider); ok {
+			s := *slider
+			s.Points = nil
+			for _, p := range slider.Points {
+				s.Points = append(s.Points, Point{X: 512 - p.X, Y: p.Y})
+			}
+			h.ObjectParams = &s
+		}
+	}
+	return &g
+}
+
+func TestTaikoDifficulty(t *testing.T) {
+	f := parseFixture(t, "taiko.osu")
+
+	tests := []struct {
+		mods      string
+		stars     float64
+		stamina   float64
+		rhythm    float64
+		colour    float64
+		great     float64
+		ss        float64
+		imperfect float64
+	}{
+		{"NM", 2.719138, 1.106003, 0.227092, 1.065744, 32, 73.136575, 43.177522},
+		{"HR", 2.719138, 1.106003, 0.227092, 1.065744, 24.8, 85.963096, 50.958367},
+		{"DT", 3.394933, 1.452492, 0.212168, 1.341825, 21.333333, 123.523292, 72.949654},
+		{"EZHT", 2.277165, 0.916342, 0.112087, 0.880257, 54.666667, 42.084544, 24.746049},
+		{"HDFL", 2.719138, 1.106003, 0.227092, 1.065744, 32, 83.883785, 49.496916},
+	}
+
+	for _, test := range tests {
+		mods := mustParseMods(t, test.mods)
+		d := f.TaikoDifficulty(mods)
+
+		got := []float64{d.StarRating, d.StaminaDifficulty, d.RhythmDifficulty, d.ColourDifficulty, d.GreatHitWindow}
+		want := []float64{test.stars, test.stamina, test.rhythm, test.colour, test.great}
+		for i := range got {
+			if !approxEqual(got[i], want[i]) {
+				t.Errorf("%s: got stars, stamina, rhythm, colour, great %.6f, want %.6f", test.mods, got, want)
+				break
+			}
+		}
+		if d.MaxCombo != 67 || d.Converted {
+			t.Errorf("%s: max combo %d, converted %v", test.mods, d.MaxCombo, d.Converted)
+		}
+
+		n := uint16(d.MaxCombo)
+		ss := d.Performance(&Score{Gamemode: ModeTaiko, Count300s: n, MaxCombo: n, Mods: mods})
+		imperfect := d.Performance(&Score{Gamemode: ModeTaiko, Count300s: n - 6, Count100s: 4, CountMiss: 2, MaxCombo: n / 3, Mods: mods})
+		if !approxEqual(ss.Total, test.ss) || !approxEqual(imperfect.Total, test.imperfect) {
+			t.Errorf("%s: pp %.6f and %.6f, want %.6f and %.6f", test.mods, ss.Total, imperfect.Total, test.ss, test.imperfect)
+		}
+	}
+}
+
+func TestCatchDifficulty(t *testing.T) {
+	f := parseFixture(t, "catch.osu")
+
+	tests := []struct {
+		mods         string
+		stars        float64
+		approachRate float64
+		ss           float64
+		imperfect    float64
+	}{
+		{"NM", 1.447607, 8.5, 20.822351, 7.740800},
+		{"HR", 2.037386, 10, 45.441268, 16.892989},
+		{"DT", 1.823193, 10, 36.372414, 13.521603},
+		{"EZHT", 1.065610, 0.666667, 13.325576, 4.953841},
+		{"HDFL", 1.447607, 8.5, 31.353962, 11.655972},
+	}
+
+	for _, test := range tests {
+		mods := mustParseMods(t, test.mods)
+		d := f.CatchDifficulty(mods)
+
+		if !approxEqual(d.StarRating, test.stars) || !approxEqual(d.ApproachRate, test.approachRate) {
+			t.Errorf("%s: %.6f stars at AR %.6f, want %.6f at AR %.6f", test.mods, d.StarRating, d.ApproachRate, test.stars, test.approachRate)
+		}
+		if d.MaxCombo != 79 {
+			t.Errorf("%s: max combo %d, want 79", test.mods, d.MaxCombo)
+		}
+
+		n := uint16(d.MaxCombo)
+		ss := d.Performance(&Score{Gamemode: ModeCatch, Count300s: n, MaxCombo: n, Mods: mods})
+		imperfect := d.Performance(&Score{Gamemode: ModeCatch, Count300s: n - 3, Count50: 20, Katus: 3, CountMiss: 3, MaxCombo: n / 2, Mods: mods})
+		if !approxEqual(ss.Total, test.ss) || !approxEqual(imperfect.Total, test.imperfect) {
+			t.Errorf("%s: pp %.6f and %.6f, want %.6f and %.6f", test.mods, ss.Total, imperfect.Total, test.ss, test.imperfect)
+		}
+	}
+}
+
+func TestManiaDifficulty(t *testing.T) {
+	f := parseFixture(t, "mania.osu")
+
+	tests := []struct {
+		mods      string
+		stars     float64
+		great     float64
+		ss        float64
+		imperfect float64
+	}{
+		{"NM", 3.568374, 40, 121.319473, 86.199787},
+		{"HR", 3.568374, 28, 121.319473, 86.199787},
+		{"DT", 4.590486, 60, 215.712177, 153.267594},
+		{"EZHT", 2.988869, 42, 40.310428, 28.641324},
+		{"HDFL", 3.568374, 40, 121.319473, 86.199787},
+	}
+
+	for _, test := range tests {
+		mods := mustParseMods(t, test.mods)
+		d := f.ManiaDifficulty(mods)
+
+		if !approxEqual(d.StarRating, test.stars) || !approxEqual(d.GreatHitWindow, test.great) {
+			t.Errorf("%s: %.6f stars with a %.6fms great window, want %.6f and %.6fms", test.mods, d.StarRating, d.GreatHitWindow, test.stars, test.great)
+		}
+		if d.MaxCombo != 342 {
+			t.Errorf("%s: max combo %d, want 342", test.mods, d.MaxCombo)
+		}
+
+		n := uint16(len(f.HitObjects))
+		ss := d.Performance(&Score{Gamemode: ModeMania, Gekis: n, Mods: mods})
+		imperfect := d.Performance(&Score{Gamemode: ModeMania, Gekis: n - 40, Count300s: 20, Katus: 10, Count100s: 5, Count50: 3, CountMiss: 2, Mods: mods})
+		if !approxEqual(ss.Total, test.ss) || !approxEqual(imperfect.Total, test.imperfect) {
+			t.Errorf("%s: pp %.6f and %.6f, want %.6f and %.6f", test.mods, ss.Total, imperfect.Total, test.ss, test.imperfect)
+		}
+	}
+}
+
+func TestConvertedDifficulty(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+
+	taiko := f.TaikoDifficulty(ModNone)
+	if !approxEqual(taiko.StarRating, 1.835448) || taiko.MaxCombo != 54 || !taiko.Converted {
+		t.Errorf("taiko: %.6f stars, max combo %d, converted %v", taiko.StarRating, taiko.MaxCombo, taiko.Converted)
+	}
+
+	catch := f.CatchDifficulty(ModNone)
+	if !approxEqual(catch.StarRating, 1.806092) || catch.MaxCombo != 68 {
+		t.Errorf("catch: %.6f stars, max combo %d", catch.StarRating, catch.MaxCombo)
+	}
+
+	tests := []struct {
+		mods     string
+		stars    float64
+		maxCombo int
+	}{
+		{"NM", 1.590178, 185},
+		{"4K", 1.601777, 175},
+	}
+	for _, test := range tests {
+		mania := f.ManiaDifficulty(mustParseMods(t, test.mods))
+		if !approxEqual(mania.StarRating, test.stars) || mania.MaxCombo != test.maxCombo {
+			t.Errorf("mania %s: %.6f stars, max combo %d, want %.6f and %d", test.mods, mania.StarRating, mania.MaxCombo, test.stars, test.maxCombo)
+		}
+	}
+}
+
+func TestDifficultyModEquivalents(t *testing.T) {
+	taiko := parseFixture(t, "taiko.osu")
+	catch := parseFixture(t, "catch.osu")
+	mania := parseFixture(t, "mania.osu")
+
+	// the catch movement depends on the clock rate, so catch is only checked
+	// against the mirrored beatmap, the catcher moves the same either way
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{"taiko DT", stretched(taiko, 1.5).TaikoDifficulty(ModDoubleTime).StarRating, taiko.TaikoDifficulty(ModNone).StarRating},
+		{"taiko HT", stretched(taiko, 0.75).TaikoDifficulty(ModHalfTime).StarRating, taiko.TaikoDifficulty(ModNone).StarRating},
+		{"catch mirrored", mirrored(catch).CatchDifficulty(ModNone).StarRating, catch.CatchDifficulty(ModNone).StarRating},
+		{"catch mirrored DT", mirrored(catch).CatchDifficulty(ModDoubleTime).StarRating, catch.CatchDifficulty(ModDoubleTime).StarRating},
+		{"mania DT", stretched(mania, 1.5).ManiaDifficulty(ModDoubleTime).StarRating, mania.ManiaDifficulty(ModNone).StarRating},
+		{"mania HT", stretched(mania, 0.75).ManiaDifficulty(ModHalfTime).StarRating, mania.ManiaDifficulty(ModNone).StarRating},
+	}
+
+	for _, test := range tests {
+		if !approxEqual(test.got, test.expected) {
+			t.Errorf("%s: %.6f stars, want %.6f", test.name, test.got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package osuParser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GameMode is the ruleset a beatmap or score is played in.
+type GameMode byte
+
+const (
+	ModeStandard GameMode = iota
+	ModeTaiko
+	ModeCatch
+	ModeMania
+)
+
+var gameModeNames = []string{"osu", "taiko", "catch", "mania"}
+
+// gameModeAliases are other names ParseGameMode accepts.
+var gameModeAliases = map[string]GameMode{
+	"standard": ModeStandard,
+	"std":      ModeStandard,
+	"fruits":   ModeCatch,
+	"ctb":      ModeCatch,
+}
+
+// String returns "osu", "taiko", "catch" or "mania".
+func (m GameMode) String() string {
+	if int(m) < len(gameModeNames) {
+		return gameModeNames[m]
+	}
+	return "GameMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// Validate returns an error if m is not one of the four game modes.
+func (m GameMode) Validate() error {
+	if m > ModeMania {
+		return fmt.Errorf("unknown game mode %d", m)
+	}
+	return nil
+}
+
+// Valid reports whether m is one of the four game modes.
+func (m GameMode) Valid() bool {
+	return m.Validate() == nil
+}
+
+// ParseGameMode parses a game mode by its name, e.g. "taiko", or number.
+// Names are case-insensitive and "standard", "std", "fruits" and "ctb" are
+// accepted as well.
+func ParseGameMode(s string) (GameMode, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for i, modeName := range gameModeNames {
+		if name == modeName {
+			return GameMode(i), nil
+		}
+	}
+	if mode, ok := gameModeAliases[name]; ok {
+		return mode, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= int(ModeMania) {
+		return GameMode(n), nil
+	}
+	return ModeStandard, fmt.Errorf("unknown game mode %q", s)
+}
+
+// MarshalText returns the name of m, or its number if it's unknown, so
+// one odd value in a database doesn't fail encoding all of it.
+func (m GameMode) MarshalText() ([]byte, error) {
+	if !m.Valid() {
+		return strconv.AppendInt(nil, int64(m), 10), nil
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText parses a name or number like ParseGameMode and also accepts
+// the numbers of unknown values MarshalText returns.
+func (m *GameMode) UnmarshalText(text []byte) error {
+	mode, err := ParseGameMode(string(text))
+	if err != nil {
+		n, numberErr := strconv.ParseUint(string(text), 10, 8)
+		if numberErr != nil {
+			return err
+		}
+		mode = GameMode(n)
+	}
+	*m = mode
+	return nil
+}
+
+// RankedStatus is the ranked status of a beatmap as stored in osu!.db.
+type RankedStatus byte
+
+const (
+	RankedStatusUnknown     RankedStatus = 0
+	RankedStatusUnsubmitted RankedStatus = 1
+	// pending, work in progress and graveyard beatmaps
+	RankedStatusPending   RankedStatus = 2
+	RankedStatusRanked    RankedStatus = 4
+	RankedStatusApproved  RankedStatus = 5
+	RankedStatusQualified RankedStatus = 6
+	RankedStatusLoved     RankedStatus = 7
+)
+
+var rankedStatusNames = map[RankedStatus]string{
+	RankedStatusUnknown:     "unknown",
+	RankedStatusUnsubmitted: "unsubmitted",
+	RankedStatusPending:     "pending",
+	RankedStatusRanked:      "ranked",
+	RankedStatusApproved:    "approved",
+	RankedStatusQualified:   "qualified",
+	RankedStatusLoved:       "loved",
+}
+
+// String returns the lowercase name of the status, e.g. "ranked".
+func (s RankedStatus) String() string {
+	if name, ok := rankedStatusNames[s]; ok {
+		return name
+	}
+	return "RankedStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Validate returns an error if s is not a status osu! uses. 3 is unused.
+func (s RankedStatus) Validate() error {
+	if _, ok := rankedStatusNames[s]; !ok {
+		return fmt.Errorf("unknown ranked status %d", s)
+	}
+	return nil
+}
+
+// Valid reports whether s is a status osu! uses.
+func (s RankedStatus) Valid() bool {
+	return s.Validate() == nil
+}
+
+// ParseRankedStatus parses a ranked status by its case-insensitive name or
+// number.
+func ParseRankedStatus(s string) (RankedStatus, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for status, statusName := range rankedStatusNames {
+		if name == statusName {
+			return status, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= 255 {
+		if status := RankedStatus(n); status.Valid() {
+			return status, nil
+		}
+	}
+	return RankedStatusUnknown, fmt.Errorf("unknown ranked status %q", s)
+}
+
+// MarshalText returns the name of s, or its number if it's unknown.
+func (s RankedStatus) MarshalText() ([]byte, error) {
+	if !s.Valid() {
+		return strconv.AppendInt(nil, int64(s), 10), nil
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *RankedStatus) UnmarshalText(text []byte) error {
+	status, err := ParseRankedStatus(string(text))
+	if err != nil {
+		n, numberErr := strconv.ParseUint(string(text), 10, 8)
+		if numberErr != nil {
+			return err
+		}
+		status = RankedStatus(n)
+	}
+	*s = status
+	return nil
+}
+
+// Grade is the letter grade of a score, with the values osu! stores in its
+// databases.
+type Grade byte
+
+const (
+	GradeSSHidden Grade = iota // silver SS
+	GradeSHidden               // silver S
+	GradeSS
+	GradeS
+	GradeA
+	GradeB
+	GradeC
+	GradeD
+	GradeF
+	// osu!.db uses GradeNone for beatmaps that were never passed
+	GradeNone
+)
+
+var gradeNames = []string{"SSH", "SH", "SS", "S", "A", "B", "C", "D", "F", "None"}
+
+// String returns the grade as osu! names it, e.g. "SS", or "SSH" and "SH"
+// for the silver grades.
+func (g Grade) String() string {
+	if int(g) < len(gradeNames) {
+		return gradeNames[g]
+	}
+	return "Grade(" + strconv.Itoa(int(g)) + ")"
+}
+
+// Validate returns an error if g is not a grade osu! uses.
+func (g Grade) Validate() error {
+	if g > GradeNone {
+		return fmt.Errorf("unknown grade %d", g)
+	}
+	return nil
+}
+
+// Valid reports whether g is a grade osu! uses.
+func (g Grade) Valid() bool {
+	return g.Validate() == nil
+}
+
+// ParseGrade parses a grade by its case-insensitive name, e.g. "SH". "X" and
+// "XH" are accepted for SS and silver SS like osu! names them internally.
+func ParseGrade(s string) (Grade, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	switch name {
+	case "X":
+		return GradeSS, nil
+	case "XH":
+		return GradeSSHidden, nil
+	}
+	for i, gradeName := range gradeNames {
+		if name == strings.ToUpper(gradeName) {
+			return Grade(i), nil
+		}
+	}
+	return GradeNone, fmt.Errorf("unknown grade %q", s)
+}
+
+// MarshalText returns the name of g, or its number if it's unknown.
+func (g Grade) MarshalText() ([]byte, error) {
+	if !g.Valid() {
+		return strconv.AppendInt(nil, int64(g), 10), nil
+	}
+	return []byte(g.String()), nil
+}
+
+func (g *Grade) UnmarshalText(text []byte) error {
+	grade, err := ParseGrade(string(text))
+	if err != nil {
+		n, numberErr := strconv.ParseUint(string(text), 10, 8)
+		if numberErr != nil {
+			return err
+		}
+		grade = Grade(n)
+	}
+	*g = grade
+	return nil
+}
+
+// Permissions are the flags of an osu! account stored in osu!.db.
+type Permissions int32
+
+const (
+	PermissionNormal     Permissions = 1 << 0
+	PermissionModerator  Permissions = 1 << 1
+	PermissionSupporter  Permissions = 1 << 2
+	PermissionFriend     Permissions = 1 << 3
+	PermissionPeppy      Permissions = 1 << 4
+	PermissionTournament Permissions = 1 << 5
+
+	PermissionNone Permissions = 0
+
+	permissionAll = PermissionTournament | (PermissionTournament - 1)
+)
+
+// permissionNames lists the permissions in the order String writes them.
+var permissionNames = []struct {
+	permission Permissions
+	name       string
+}{
+	{PermissionNormal, "Normal"},
+	{PermissionModerator, "Moderator"},
+	{PermissionSupporter, "Supporter"},
+	{PermissionFriend, "Friend"},
+	{PermissionPeppy, "peppy"},
+	{PermissionTournament, "Tournament"},
+}
+
+// Has reports whether all permissions of other are set.
+func (p Permissions) Has(other Permissions) bool {
+	return p&other == other
+}
+
+// String returns the names of the permissions separated by "|", e.g.
+// "Normal|Supporter", or "None".
+func (p Permissions) String() string {
+	if p == PermissionNone {
+		return "None"
+	}
+
+	var names []string
+	for _, permission := range permissionNames {
+		if p.Has(permission.permission) {
+			names = append(names, permission.name)
+		}
+	}
+	if unknown := p &^ permissionAll; unknown != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(unknown)))
+	}
+	return strings.Join(names, "|")
+}
+
+// Validate returns an error if p contains unknown bits.
+func (p Permissions) Validate() error {
+	if p&^permissionAll != 0 {
+		return fmt.Errorf("unknown permission bits 0x%x", uint32(p&^permissionAll))
+	}
+	return nil
+}
+
+// Valid reports whether p only contains known permissions.
+func (p Permissions) Valid() bool {
+	return p.Validate() == nil
+}
+
+// ParsePermissions parses permission names separated by "|", "," or spaces,
+// e.g. "Normal|Supporter". Names are case-insensitive and "None" and the
+// empty string are no permissions.
+func ParsePermissions(s string) (Permissions, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' '
+	})
+
+	permissions := PermissionNone
+	for _, field := range fields {
+		if strings.EqualFold(field, "None") {
+			continue
+		}
+
+		found := false
+		for _, permission := range permissionNames {
+			if strings.EqualFold(field, permission.name) {
+				permissions |= permission.permission
+				found = true
+				break
+			}
+		}
+		if !found {
+			return PermissionNone, fmt.Errorf("unknown permission %q", field)
+		}
+	}
+	return permissions, nil
+}
+
+// MarshalText returns the names of p, or its number if it has unknown bits.
+func (p Permissions) MarshalText() ([]byte, error) {
+	if !p.Valid() {
+		return strconv.AppendInt(nil, int64(p), 10), nil
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *Permissions) UnmarshalText(text []byte) error {
+	permissions, err := ParsePermissions(string(text))
+	if err != nil {
+		n, numberErr := strconv.ParseInt(string(text), 10, 32)
+		if numberErr != nil {
+			return err
+		}
+		permissions = Permissions(n)
+	}
+	*p = permissions
+	return nil
+}
@@ -0,0 +1,53 @@
+package osuParser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnumsJSON(t *testing.T) {
+	type enums struct {
+		Mode        GameMode
+		Status      RankedStatus
+		Grade       Grade
+		Permissions Permissions
+	}
+
+	tests := []struct {
+		value enums
+		json  string
+	}{
+		{
+			enums{ModeCatch, RankedStatusLoved, GradeSHidden, PermissionNormal | PermissionSupporter},
+			`{"Mode":"catch","Status":"loved","Grade":"SH","Permissions":"Normal|Supporter"}`,
+		},
+		{
+			// unknown values keep their number instead of failing
+			enums{GameMode(9), RankedStatus(3), Grade(200), Permissions(1 << 8)},
+			`{"Mode":"9","Status":"3","Grade":"200","Permissions":"256"}`,
+		},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.json {
+			t.Errorf("got %s, want %s", data, test.json)
+		}
+
+		var value enums
+		if err := json.Unmarshal(data, &value); err != nil {
+			t.Fatal(err)
+		}
+		if value != test.value {
+			t.Errorf("%s decoded as %+v, want %+v", data, value, test.value)
+		}
+	}
+
+	var grade Grade
+	if err := grade.UnmarshalText([]byte("Q")); err == nil {
+		t.Error("unknown grade name was accepted")
+	}
+}
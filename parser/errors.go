@@ -0,0 +1,141 @@
+package osuParser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseError is an error at a position of a parsed file. Errors in .osu files
+// have the section, line and column of the malformed value, errors in the
+// binary databases the byte offset and the entry that was being read.
+// Use errors.As to get it from the errors of the parsers.
+type ParseError struct {
+	File string
+
+	// Section is the name of the .osu section, e.g. "HitObjects", and Line
+	// and Column count from 1. Column counts bytes.
+	Section string
+	Line    int
+	Column  int
+
+	// Offset is the byte offset in a database file the error occurred at.
+	// Entry is the kind of entry being read, e.g. "beatmap", and Index its
+	// index. Entry is empty for errors in the header of the file.
+	Offset int64
+	Entry  string
+	Index  int
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		position := fmt.Sprintf("%d:%d:", e.Line, e.Column)
+		if e.File != "" {
+			position = e.File + ":" + position
+		}
+		if e.Section != "" {
+			position += " [" + e.Section + "]"
+		}
+		return fmt.Sprintf("%s %v", position, e.Err)
+	}
+
+	position := fmt.Sprintf("at byte %d", e.Offset)
+	if e.Entry != "" {
+		position = fmt.Sprintf("%s %d %s", e.Entry, e.Index, position)
+	}
+	if e.File != "" {
+		position = e.File + ": " + position
+	}
+	return fmt.Sprintf("%s: %v", position, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Warning is a malformed value the parser skipped over in lenient mode. It
+// holds the same information as the ParseError strict mode returns.
+type Warning ParseError
+
+func (w Warning) String() string {
+	e := ParseError(w)
+	return e.Error()
+}
+
+var errTooFewFields = errors.New("too few fields")
+
+// lineParser converts the values of a line of a .osu file and records the
+// malformed ones with their position.
+type lineParser struct {
+	file    string
+	section string
+	line    int
+	// indent is the column the trimmed line starts at
+	indent int
+	errors []*ParseError
+}
+
+func (p *lineParser) fail(column int, err error) {
+	p.errors = append(p.errors, &ParseError{
+		File:    p.file,
+		Section: p.section,
+		Line:    p.line,
+		Column:  p.indent + column + 1,
+		Err:     err,
+	})
+}
+
+// atoi parses the int at column of the line, returning 0 if it's malformed.
+func (p *lineParser) atoi(value string, column int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		p.fail(column, err)
+	}
+	return n
+}
+
+// parseFloat parses the float at column of the line, returning 0 if it's
+// malformed.
+func (p *lineParser) parseFloat(value string, column int) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		p.fail(column, err)
+		return 0
+	}
+	return f
+}
+
+// splitColumns splits s around sep like strings.Split and also returns the
+// column each field starts at, given the column of s.
+func splitColumns(s, sep string, column int) ([]string, []int) {
+	fields := strings.Split(s, sep)
+	columns := make([]int, len(fields))
+	for i, field := range fields {
+		columns[i] = column
+		column += len(field) + len(sep)
+	}
+	return fields, columns
+}
+
+// dbError wraps an error of reading entry index of a database file. Errors
+// that already are a ParseError of a nested entry are returned as is.
+func dbError(file string, d *decoder, entry string, index int, err error) error {
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	if errors.Is(err, io.EOF) {
+		// the file ended before everything was read
+		err = io.ErrUnexpectedEOF
+	}
+	return &ParseError{
+		File:   file,
+		Offset: d.offset(),
+		Entry:  entry,
+		Index:  index,
+		Err:    err,
+	}
+}
@@ -1,11 +1,12 @@
 package osuParser
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -15,23 +16,57 @@ type General struct {
 	AudioLeadIn              int
 	AudioHash                string
 	PreviewTime              int
-	Countdown                int
+	Countdown                Countdown
 	SampleSet                string
 	StackLeniency            float64
-	Mode                     int
-	LetterboxInBreaks        int
-	StoryFireInFront         int
-	UseSkinSprites           int
-	AlwaysShowPlayfield      int
-	OverlayPosition          string
+	Mode                     GameMode
+	LetterboxInBreaks        bool
+	StoryFireInFront         bool
+	UseSkinSprites           bool
+	AlwaysShowPlayfield      bool
+	OverlayPosition          OverlayPosition
 	SkinPreference           string
-	EpilepsyWarning          int
+	EpilepsyWarning          bool
 	CountdownOffset          int
-	SpecialStyle             int
-	WidescreenStoryboard     int
-	SamplesMatchPlaybackRate int
+	SpecialStyle             bool
+	WidescreenStoryboard     bool
+	SamplesMatchPlaybackRate bool
 }
 
+// Countdown is the speed of the countdown before the first hit object.
+type Countdown int
+
+const (
+	CountdownNone Countdown = iota
+	CountdownNormal
+	CountdownHalf
+	CountdownDouble
+)
+
+func (c Countdown) String() string {
+	switch c {
+	case CountdownNone:
+		return "None"
+	case CountdownNormal:
+		return "Normal"
+	case CountdownHalf:
+		return "Half"
+	case CountdownDouble:
+		return "Double"
+	}
+	return "Countdown(" + strconv.Itoa(int(c)) + ")"
+}
+
+// OverlayPosition is where hit circle overlays are drawn relative to the
+// numbers.
+type OverlayPosition string
+
+const (
+	OverlayNoChange OverlayPosition = "NoChange"
+	OverlayBelow    OverlayPosition = "Below"
+	OverlayAbove    OverlayPosition = "Above"
+)
+
 type Editor struct {
 	Bookmarks       []int
 	DistanceSpacing float64
@@ -66,6 +101,7 @@ type Event struct {
 	EventType   string
 	StartTime   int
 	EventParams []string
+	Depth       int
 }
 
 type TimingPointFile struct {
@@ -89,10 +125,10 @@ type HitObject struct {
 	X            float64
 	Y            float64
 	Time         float64
-	Type         int
+	Type         HitObjectType
 	HitSound     int
-	ObjectParams string
-	HitSample    string
+	ObjectParams HitObjectParams
+	HitSample    HitSample
 }
 
 type OsuFile struct {
@@ -105,188 +141,307 @@ type OsuFile struct {
 	TimingPointsFile []TimingPointFile
 	Colours          []Colour
 	HitObjects       []HitObject
+
+	// UnknownKeys holds the keys of [General], [Editor], [Metadata],
+	// [Difficulty] and [Colours] the parser doesn't know, by section, if
+	// ParseOptions.KeepUnknownKeys is set. They are written back as is.
+	UnknownKeys map[string]map[string]string
+
+	// Warnings are the malformed values skipped over while parsing.
+	Warnings []Warning
 }
 
-// not yet Implemented
-type ReplayFile struct {
-	Gamemode                 byte
-	Version                  int32
-	beatmapMD5Hash           string
-	playername               string
-	replayMD5Hash            string
-	Count300s                int16
-	Count100s                int16
-	Count50s                 int16
-	Gekis                    int16
-	Katus                    int16
-	CountMiss                int16
-	Combo                    int16
-	Score                    int32
-	PerfectCombo             byte
-	Mods                     int32
-	HealthGraph              []*Health
-	Timestamp                int64
-	LengthInBytes            int32
-	Replay                   []*ReplayData
-	OnlineScoreId            int64
-	AdditionalModInformation float64
-	LZMA                     []*byte
-}
-
-type Health struct {
-	u int32
-	v float32
-}
-
-type ReplayData struct {
+// ParseOptions change how .osu files are parsed.
+type ParseOptions struct {
+	// KeepUnknownKeys collects unknown keys into OsuFile.UnknownKeys.
+	KeepUnknownKeys bool
+	// Strict fails with a *ParseError on the first malformed value instead
+	// of collecting them in OsuFile.Warnings.
+	Strict bool
+}
+
+// newOsuFile returns an OsuFile with the defaults osu! uses for keys missing
+// from a file.
+func newOsuFile() *OsuFile {
+	return &OsuFile{
+		General: General{
+			PreviewTime:     -1,
+			Countdown:       CountdownNormal,
+			SampleSet:       "Normal",
+			StackLeniency:   0.7,
+			OverlayPosition: OverlayNoChange,
+		},
+		Difficulty: Difficulty{
+			HPDrainRate:       5,
+			CircleSize:        5,
+			OverallDifficulty: 5,
+			ApproachRate:      5,
+			SliderMultiplier:  1.4,
+			SliderTickRate:    1,
+		},
+	}
 }
 
 func ParseOsuFile(filename string) (*OsuFile, error) {
-	var err error
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("Unexpected error occored: %v", r)
-		}
-	}()
+	return ParseOsuFileWithOptions(filename, ParseOptions{})
+}
 
+// ParseOsuFileWithOptions parses a .osu file like ParseOsuFile with options.
+func ParseOsuFileWithOptions(filename string, options ParseOptions) (*OsuFile, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, fmt.Errorf("%s in file: %s", err, filename)
+		return nil, fmt.Errorf("%w in file: %s", err, filename)
 	}
 
-	OsuFile, err := parseOsuFile(filename)
-	if err != nil {
-		return nil, err
-	}
+	return decodeFile(filename, func(r io.Reader, name string) (*OsuFile, error) {
+		return decodeOsuFile(r, name, options)
+	})
+}
+
+// DecodeOsuFile reads a .osu file from r.
+func DecodeOsuFile(r io.Reader) (*OsuFile, error) {
+	return decodeOsuFile(r, "", ParseOptions{})
+}
+
+// DecodeOsuFileWithOptions reads a .osu file from r with options.
+func DecodeOsuFileWithOptions(r io.Reader, options ParseOptions) (*OsuFile, error) {
+	return decodeOsuFile(r, "", options)
+}
 
-	return OsuFile, nil
+// ParseOsuFileFS reads the .osu file name of fsys.
+func ParseOsuFileFS(fsys fs.FS, name string) (*OsuFile, error) {
+	return ParseOsuFileFSWithOptions(fsys, name, ParseOptions{})
+}
 
+// ParseOsuFileFSWithOptions reads the .osu file name of fsys with options.
+func ParseOsuFileFSWithOptions(fsys fs.FS, name string, options ParseOptions) (*OsuFile, error) {
+	return decodeFS(fsys, name, func(r io.Reader, name string) (*OsuFile, error) {
+		return decodeOsuFile(r, name, options)
+	})
 }
 
-func parseOsuFile(filename string) (*OsuFile, error) {
-	var err error
-	var lineNumber int
+func decodeOsuFile(r io.Reader, filename string, options ParseOptions) (osuFile *OsuFile, err error) {
+	p := &lineParser{file: filename}
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("%v in line %d", r, lineNumber)
+			osuFile = nil
+			err = &ParseError{
+				File:    p.file,
+				Section: p.section,
+				Line:    p.line,
+				Column:  p.indent + 1,
+				Err:     fmt.Errorf("unexpected error: %v", r),
+			}
 		}
 	}()
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	reader := bufio.NewReaderSize(file, 128*1024)
-
-	byteData, err := io.ReadAll(reader)
+	byteData, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
 	lines := bytes.Split([]byte(byteData), []byte{'\n'})
-	osuFile := &OsuFile{}
+	osuFile = newOsuFile()
 	currentSection := ""
+	hasApproachRate := false
 
 	for i, lineStr := range lines {
-		lineNumber = i
+		p.line = i + 1
 
-		line := strings.TrimSpace(string(lineStr))
+		rawLine := string(lineStr)
+		line := strings.TrimSpace(rawLine)
+		if i == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
 
 		if len(line) == 0 || strings.HasPrefix(line, "//") {
 			continue
 		}
+		p.indent = strings.Index(rawLine, line)
+
+		if currentSection == "" && strings.HasPrefix(line, "osu file format v") {
+			osuFile.Version = p.atoi(strings.TrimPrefix(line, "osu file format v"), len("osu file format v"))
+			continue
+		}
 
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			currentSection = strings.ToLower(line[1 : len(line)-1])
+			p.section = line[1 : len(line)-1]
+			currentSection = strings.ToLower(p.section)
 			continue
 		}
 
+		known := true
 		switch currentSection {
 		case "general":
-			parseGeneral(line, &osuFile.General)
+			known = parseGeneral(p, line, &osuFile.General)
 		case "editor":
-			parseEditor(line, &osuFile.Editor)
+			known = parseEditor(p, line, &osuFile.Editor)
 		case "metadata":
-			parseMetadata(line, &osuFile.Metadata)
+			known = parseMetadata(p, line, &osuFile.Metadata)
 		case "difficulty":
-			parseDifficulty(line, &osuFile.Difficulty)
+			known = parseDifficulty(p, line, &osuFile.Difficulty)
+			hasApproachRate = hasApproachRate || strings.HasPrefix(line, "ApproachRate")
 		case "events":
-			parseEvents(line, &osuFile.Events)
+			parseEvents(p, line, eventDepth(lineStr), &osuFile.Events)
 		case "timingpoints":
-			parseTimingPoints(line, &osuFile.TimingPointsFile)
+			parseTimingPoints(p, line, &osuFile.TimingPointsFile)
 		case "colours":
-			parseColours(line, &osuFile.Colours)
+			known = parseColours(p, line, &osuFile.Colours)
 		case "hitobjects":
-			parseHitObjects(line, &osuFile.HitObjects)
+			parseHitObjects(p, line, &osuFile.HitObjects)
+		}
+
+		if !known && options.KeepUnknownKeys {
+			osuFile.addUnknownKey(currentSection, line)
 		}
+		if options.Strict && len(p.errors) > 0 {
+			return nil, p.errors[0]
+		}
+	}
+
+	for _, e := range p.errors {
+		osuFile.Warnings = append(osuFile.Warnings, Warning(*e))
+	}
+
+	// old beatmaps use the overall difficulty as approach rate
+	if !hasApproachRate {
+		osuFile.ApproachRate = osuFile.OverallDifficulty
 	}
 
 	return osuFile, nil
 }
 
-func parseGeneral(line string, general *General) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
+// keyValueSections are the sections of key-value pairs by their lowercase
+// name, as UnknownKeys names them.
+var keyValueSections = map[string]string{
+	"general":    "General",
+	"editor":     "Editor",
+	"metadata":   "Metadata",
+	"difficulty": "Difficulty",
+	"colours":    "Colours",
+}
+
+func (f *OsuFile) addUnknownKey(section, line string) {
+	key, value, _, ok := splitKeyValue(line)
+	if !ok {
 		return
 	}
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
+	section = keyValueSections[section]
+	if f.UnknownKeys == nil {
+		f.UnknownKeys = make(map[string]map[string]string)
+	}
+	if f.UnknownKeys[section] == nil {
+		f.UnknownKeys[section] = make(map[string]string)
+	}
+	f.UnknownKeys[section][key] = value
+}
+
+// splitKeyValue splits a "Key: value" line and returns the column the value
+// starts at.
+func splitKeyValue(line string) (string, string, int, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", 0, false
+	}
+	column := len(key) + 1 + len(value) - len(strings.TrimLeft(value, " \t"))
+	return strings.TrimSpace(key), strings.TrimSpace(value), column, true
+}
+
+// parseBool parses the "0" and "1" osu! stores flags as.
+func (p *lineParser) parseBool(value string, column int) bool {
+	return p.atoi(value, column) == 1
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// parseGeneral parses a line of [General] and reports whether its key is
+// known. Lines that aren't key-value pairs are ignored.
+func parseGeneral(p *lineParser, line string, general *General) bool {
+	key, value, column, ok := splitKeyValue(line)
+	if !ok {
+		return true
+	}
 
 	switch key {
 	case "AudioFilename":
 		general.AudioFilename = value
 	case "AudioLeadIn":
-		general.AudioLeadIn, _ = strconv.Atoi(value)
+		general.AudioLeadIn = p.atoi(value, column)
+	case "AudioHash":
+		general.AudioHash = value
 	case "PreviewTime":
-		general.PreviewTime, _ = strconv.Atoi(value)
+		general.PreviewTime = p.atoi(value, column)
 	case "Countdown":
-		general.Countdown, _ = strconv.Atoi(value)
+		general.Countdown = Countdown(p.atoi(value, column))
 	case "SampleSet":
 		general.SampleSet = value
 	case "StackLeniency":
-		general.StackLeniency, _ = strconv.ParseFloat(value, 64)
+		general.StackLeniency = p.parseFloat(value, column)
 	case "Mode":
-		general.Mode, _ = strconv.Atoi(value)
+		general.Mode = GameMode(p.atoi(value, column))
 	case "LetterboxInBreaks":
-		general.LetterboxInBreaks, _ = strconv.Atoi(value)
+		general.LetterboxInBreaks = p.parseBool(value, column)
+	case "StoryFireInFront":
+		general.StoryFireInFront = p.parseBool(value, column)
+	case "UseSkinSprites":
+		general.UseSkinSprites = p.parseBool(value, column)
+	case "AlwaysShowPlayfield":
+		general.AlwaysShowPlayfield = p.parseBool(value, column)
+	case "OverlayPosition":
+		general.OverlayPosition = OverlayPosition(value)
+	case "SkinPreference":
+		general.SkinPreference = value
+	case "EpilepsyWarning":
+		general.EpilepsyWarning = p.parseBool(value, column)
+	case "CountdownOffset":
+		general.CountdownOffset = p.atoi(value, column)
+	case "SpecialStyle":
+		general.SpecialStyle = p.parseBool(value, column)
 	case "WidescreenStoryboard":
-		general.WidescreenStoryboard, _ = strconv.Atoi(value)
+		general.WidescreenStoryboard = p.parseBool(value, column)
+	case "SamplesMatchPlaybackRate":
+		general.SamplesMatchPlaybackRate = p.parseBool(value, column)
+	default:
+		return false
 	}
+	return true
 }
 
-func parseEditor(line string, editor *Editor) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return
+func parseEditor(p *lineParser, line string, editor *Editor) bool {
+	key, value, column, ok := splitKeyValue(line)
+	if !ok {
+		return true
 	}
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
 
 	switch key {
 	case "Bookmarks":
-		for _, v := range strings.Split(value, ",") {
-			bookmark, _ := strconv.Atoi(v)
-			editor.Bookmarks = append(editor.Bookmarks, bookmark)
+		bookmarks, columns := splitColumns(value, ",", column)
+		for i, v := range bookmarks {
+			editor.Bookmarks = append(editor.Bookmarks, p.atoi(v, columns[i]))
 		}
 	case "DistanceSpacing":
-		editor.DistanceSpacing, _ = strconv.ParseFloat(value, 64)
+		editor.DistanceSpacing = p.parseFloat(value, column)
 	case "BeatDivisor":
-		editor.BeatDivisor, _ = strconv.Atoi(value)
+		editor.BeatDivisor = p.atoi(value, column)
 	case "GridSize":
-		editor.GridSize, _ = strconv.Atoi(value)
+		editor.GridSize = p.atoi(value, column)
 	case "TimelineZoom":
-		editor.TimelineZoom, _ = strconv.ParseFloat(value, 64)
+		editor.TimelineZoom = p.parseFloat(value, column)
+	default:
+		return false
 	}
+	return true
 }
 
-func parseMetadata(line string, metadata *Metadata) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return
+func parseMetadata(p *lineParser, line string, metadata *Metadata) bool {
+	key, value, column, ok := splitKeyValue(line)
+	if !ok {
+		return true
 	}
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
 
 	switch key {
 	case "Title":
@@ -304,40 +459,47 @@ func parseMetadata(line string, metadata *Metadata) {
 	case "Source":
 		metadata.Source = value
 	case "Tags":
-		metadata.Tags = strings.Split(value, " ")
+		// osu! writes the key even without tags
+		if tags := strings.Fields(value); len(tags) > 0 {
+			metadata.Tags = tags
+		}
 	case "BeatmapID":
-		metadata.BeatmapID, _ = strconv.Atoi(value)
+		metadata.BeatmapID = p.atoi(value, column)
 	case "BeatmapSetID":
-		metadata.BeatmapSetID, _ = strconv.Atoi(value)
+		metadata.BeatmapSetID = p.atoi(value, column)
+	default:
+		return false
 	}
+	return true
 }
 
-func parseDifficulty(line string, difficulty *Difficulty) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return
+func parseDifficulty(p *lineParser, line string, difficulty *Difficulty) bool {
+	key, value, column, ok := splitKeyValue(line)
+	if !ok {
+		return true
 	}
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
 
 	switch key {
 	case "HPDrainRate":
-		difficulty.HPDrainRate, _ = strconv.ParseFloat(value, 64)
+		difficulty.HPDrainRate = p.parseFloat(value, column)
 	case "CircleSize":
-		difficulty.CircleSize, _ = strconv.ParseFloat(value, 64)
+		difficulty.CircleSize = p.parseFloat(value, column)
 	case "OverallDifficulty":
-		difficulty.OverallDifficulty, _ = strconv.ParseFloat(value, 64)
+		difficulty.OverallDifficulty = p.parseFloat(value, column)
 	case "ApproachRate":
-		difficulty.ApproachRate, _ = strconv.ParseFloat(value, 64)
+		difficulty.ApproachRate = p.parseFloat(value, column)
 	case "SliderMultiplier":
-		difficulty.SliderMultiplier, _ = strconv.ParseFloat(value, 64)
+		difficulty.SliderMultiplier = p.parseFloat(value, column)
 	case "SliderTickRate":
-		difficulty.SliderTickRate, _ = strconv.ParseFloat(value, 64)
+		difficulty.SliderTickRate = p.parseFloat(value, column)
+	default:
+		return false
 	}
+	return true
 }
 
-func parseEvents(line string, events *[]Event) {
-	parts := strings.Split(line, ",")
+func parseEvents(p *lineParser, line string, depth int, events *[]Event) {
+	parts, columns := splitColumns(line, ",", 0)
 
 	if len(parts) < 1 {
 		return
@@ -347,35 +509,65 @@ func parseEvents(line string, events *[]Event) {
 	startTime := 0
 	var eventParams []string
 
-	if len(parts) > 1 {
-		startTime, _ = strconv.Atoi(parts[1])
-	}
+	if !eventHasStartTime(eventType) {
+		eventParams = parts[1:]
+	} else {
+		if len(parts) > 1 {
+			startTime = p.atoi(parts[1], columns[1])
+		}
 
-	if len(parts) > 2 {
-		eventParams = parts[2:]
+		if len(parts) > 2 {
+			eventParams = parts[2:]
+		}
 	}
 
 	*events = append(*events, Event{
 		EventType:   eventType,
 		StartTime:   startTime,
 		EventParams: eventParams,
+		Depth:       depth,
 	})
 }
 
-func parseTimingPoints(line string, timingPoints *[]TimingPointFile) {
-	parts := strings.Split(line, ",")
-	if len(parts) < 8 {
+// eventHasStartTime reports whether the second field of an event is its
+// start time. Storyboard objects carry their layer there, trigger commands
+// their trigger name and the other commands their easing.
+func eventHasStartTime(eventType string) bool {
+	switch eventType {
+	case "0", "Background", "1", "Video", "2", "Break", "3", "5", "Sample", "L":
+		return true
+	}
+	return false
+}
+
+// eventDepth counts the leading spaces or underscores which nest storyboard
+// commands below their object.
+func eventDepth(line []byte) int {
+	depth := 0
+	for depth < len(line) && (line[depth] == ' ' || line[depth] == '_') {
+		depth++
+	}
+	return depth
+}
+
+func parseTimingPoints(p *lineParser, line string, timingPoints *[]TimingPointFile) {
+	parts, columns := splitColumns(line, ",", 0)
+	if len(parts) < 2 {
+		p.fail(0, errTooFewFields)
 		return
 	}
 
-	time, _ := strconv.Atoi(parts[0])
-	beatLength, _ := strconv.ParseFloat(parts[1], 64)
-	meter, _ := strconv.Atoi(parts[2])
-	sampleSet, _ := strconv.Atoi(parts[3])
-	sampleIndex, _ := strconv.Atoi(parts[4])
-	volume, _ := strconv.Atoi(parts[5])
-	uninherited, _ := strconv.Atoi(parts[6])
-	effects, _ := strconv.Atoi(parts[7])
+	// older file format versions omit the trailing fields
+	time := int(p.parseFloat(parts[0], columns[0]))
+	beatLength := p.parseFloat(parts[1], columns[1])
+	meter, sampleSet, sampleIndex, volume, uninherited, effects := 4, 0, 0, 100, 1, 0
+
+	optional := []*int{&meter, &sampleSet, &sampleIndex, &volume, &uninherited, &effects}
+	for i, field := range optional {
+		if len(parts) > i+2 {
+			*field = p.atoi(parts[i+2], columns[i+2])
+		}
+	}
 
 	*timingPoints = append(*timingPoints, TimingPointFile{
 		Time:        time,
@@ -389,22 +581,20 @@ func parseTimingPoints(line string, timingPoints *[]TimingPointFile) {
 	})
 }
 
-func parseColours(line string, colours *[]Colour) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return
+func parseColours(p *lineParser, line string, colours *[]Colour) bool {
+	key, value, column, ok := splitKeyValue(line)
+	if !ok {
+		return true
 	}
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
-	rgb := strings.Split(value, ",")
+	rgb, columns := splitColumns(value, ",", column)
 
 	if len(rgb) != 3 {
-		return
+		return false
 	}
 
-	r, _ := strconv.Atoi(rgb[0])
-	g, _ := strconv.Atoi(rgb[1])
-	b, _ := strconv.Atoi(rgb[2])
+	r := p.atoi(rgb[0], columns[0])
+	g := p.atoi(rgb[1], columns[1])
+	b := p.atoi(rgb[2], columns[2])
 
 	switch {
 	case strings.HasPrefix(key, "Combo"):
@@ -413,37 +603,270 @@ func parseColours(line string, colours *[]Colour) {
 		*colours = append(*colours, Colour{SliderTrackOverride: []int{r, g, b}})
 	case key == "SliderBorder":
 		*colours = append(*colours, Colour{SliderBorder: []int{r, g, b}})
+	default:
+		return false
 	}
+	return true
 }
 
-func parseHitObjects(line string, hitObjects *[]HitObject) {
-	parts := strings.Split(line, ",")
+func parseHitObjects(p *lineParser, line string, hitObjects *[]HitObject) {
+	parts, columns := splitColumns(line, ",", 0)
 	if len(parts) < 5 {
+		p.fail(0, errTooFewFields)
 		return
 	}
 
-	x, _ := strconv.ParseFloat(parts[0], 64)
-	y, _ := strconv.ParseFloat(parts[1], 64)
-	time, _ := strconv.ParseFloat(parts[2], 64)
-	objectType, _ := strconv.Atoi(parts[3])
-	hitSound, _ := strconv.Atoi(parts[4])
+	x := p.parseFloat(parts[0], columns[0])
+	y := p.parseFloat(parts[1], columns[1])
+	time := p.parseFloat(parts[2], columns[2])
+	objectType := p.atoi(parts[3], columns[3])
+	hitSound := p.atoi(parts[4], columns[4])
 
-	objectParams := ""
-	if len(parts) > 5 {
-		objectParams = parts[5]
-	}
-	hitSample := ""
-	if len(parts) > 6 {
-		hitSample = parts[6]
-	}
+	objectParams, hitSample := parseHitObjectParams(p, HitObjectType(objectType), parts[5:], columns[5:])
 
 	*hitObjects = append(*hitObjects, HitObject{
 		X:            x,
 		Y:            y,
 		Time:         time,
-		Type:         objectType,
+		Type:         HitObjectType(objectType),
 		HitSound:     hitSound,
 		ObjectParams: objectParams,
 		HitSample:    hitSample,
 	})
 }
+
+func WriteOsuFile(filename string, osuFile *OsuFile) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return writeOsuFile(w, osuFile)
+	})
+}
+
+func writeOsuFile(w io.Writer, osuFile *OsuFile) error {
+	version := osuFile.Version
+	if version == 0 {
+		version = 14
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "osu file format v%d\r\n", version)
+
+	unknown := osuFile.UnknownKeys
+	encodeGeneral(&sb, &osuFile.General)
+	encodeUnknownKeys(&sb, unknown["General"], writeKeyValue)
+	encodeEditor(&sb, &osuFile.Editor)
+	encodeUnknownKeys(&sb, unknown["Editor"], writeKeyValue)
+	encodeMetadata(&sb, &osuFile.Metadata)
+	encodeUnknownKeys(&sb, unknown["Metadata"], writeKeyValueTight)
+	encodeDifficulty(&sb, &osuFile.Difficulty)
+	encodeUnknownKeys(&sb, unknown["Difficulty"], writeKeyValueTight)
+	encodeEvents(&sb, osuFile.Events)
+	encodeTimingPoints(&sb, osuFile.TimingPointsFile)
+	encodeColours(&sb, osuFile.Colours, unknown["Colours"])
+	encodeHitObjects(&sb, osuFile.HitObjects)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatInts(values []int, sep string) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, sep)
+}
+
+func writeSection(sb *strings.Builder, name string) {
+	sb.WriteString("\r\n[")
+	sb.WriteString(name)
+	sb.WriteString("]\r\n")
+}
+
+// writeKeyValue writes a "Key: value" pair as used by [General] and [Editor].
+func writeKeyValue(sb *strings.Builder, key string, value string) {
+	sb.WriteString(key)
+	sb.WriteString(": ")
+	sb.WriteString(value)
+	sb.WriteString("\r\n")
+}
+
+// writeKeyValueTight writes a "Key:value" pair as used by [Metadata] and
+// [Difficulty].
+func writeKeyValueTight(sb *strings.Builder, key string, value string) {
+	sb.WriteString(key)
+	sb.WriteString(":")
+	sb.WriteString(value)
+	sb.WriteString("\r\n")
+}
+
+func encodeGeneral(sb *strings.Builder, general *General) {
+	writeSection(sb, "General")
+	writeKeyValue(sb, "AudioFilename", general.AudioFilename)
+	writeKeyValue(sb, "AudioLeadIn", strconv.Itoa(general.AudioLeadIn))
+	if general.AudioHash != "" {
+		writeKeyValue(sb, "AudioHash", general.AudioHash)
+	}
+	writeKeyValue(sb, "PreviewTime", strconv.Itoa(general.PreviewTime))
+	writeKeyValue(sb, "Countdown", strconv.Itoa(int(general.Countdown)))
+	writeKeyValue(sb, "SampleSet", general.SampleSet)
+	writeKeyValue(sb, "StackLeniency", formatFloat(general.StackLeniency))
+	writeKeyValue(sb, "Mode", strconv.Itoa(int(general.Mode)))
+	writeKeyValue(sb, "LetterboxInBreaks", formatBool(general.LetterboxInBreaks))
+
+	// optional keys osu! only writes when they differ from the default
+	optional := []struct {
+		key   string
+		value bool
+	}{
+		{"StoryFireInFront", general.StoryFireInFront},
+		{"UseSkinSprites", general.UseSkinSprites},
+		{"AlwaysShowPlayfield", general.AlwaysShowPlayfield},
+	}
+	for _, o := range optional {
+		if o.value {
+			writeKeyValue(sb, o.key, formatBool(o.value))
+		}
+	}
+	if general.OverlayPosition != "" && general.OverlayPosition != OverlayNoChange {
+		writeKeyValue(sb, "OverlayPosition", string(general.OverlayPosition))
+	}
+	if general.SkinPreference != "" {
+		writeKeyValue(sb, "SkinPreference", general.SkinPreference)
+	}
+	if general.EpilepsyWarning {
+		writeKeyValue(sb, "EpilepsyWarning", formatBool(general.EpilepsyWarning))
+	}
+	if general.CountdownOffset != 0 {
+		writeKeyValue(sb, "CountdownOffset", strconv.Itoa(general.CountdownOffset))
+	}
+	if general.SpecialStyle {
+		writeKeyValue(sb, "SpecialStyle", formatBool(general.SpecialStyle))
+	}
+	writeKeyValue(sb, "WidescreenStoryboard", formatBool(general.WidescreenStoryboard))
+	if general.SamplesMatchPlaybackRate {
+		writeKeyValue(sb, "SamplesMatchPlaybackRate", formatBool(general.SamplesMatchPlaybackRate))
+	}
+}
+
+func encodeEditor(sb *strings.Builder, editor *Editor) {
+	writeSection(sb, "Editor")
+	if len(editor.Bookmarks) > 0 {
+		writeKeyValue(sb, "Bookmarks", formatInts(editor.Bookmarks, ","))
+	}
+	writeKeyValue(sb, "DistanceSpacing", formatFloat(editor.DistanceSpacing))
+	writeKeyValue(sb, "BeatDivisor", strconv.Itoa(editor.BeatDivisor))
+	writeKeyValue(sb, "GridSize", strconv.Itoa(editor.GridSize))
+	writeKeyValue(sb, "TimelineZoom", formatFloat(editor.TimelineZoom))
+}
+
+func encodeMetadata(sb *strings.Builder, metadata *Metadata) {
+	writeSection(sb, "Metadata")
+	writeKeyValueTight(sb, "Title", metadata.Title)
+	writeKeyValueTight(sb, "TitleUnicode", metadata.TitleUnicode)
+	writeKeyValueTight(sb, "Artist", metadata.Artist)
+	writeKeyValueTight(sb, "ArtistUnicode", metadata.ArtistUnicode)
+	writeKeyValueTight(sb, "Creator", metadata.Creator)
+	writeKeyValueTight(sb, "Version", metadata.Version)
+	writeKeyValueTight(sb, "Source", metadata.Source)
+	writeKeyValueTight(sb, "Tags", strings.Join(metadata.Tags, " "))
+	writeKeyValueTight(sb, "BeatmapID", strconv.Itoa(metadata.BeatmapID))
+	writeKeyValueTight(sb, "BeatmapSetID", strconv.Itoa(metadata.BeatmapSetID))
+}
+
+func encodeDifficulty(sb *strings.Builder, difficulty *Difficulty) {
+	writeSection(sb, "Difficulty")
+	writeKeyValueTight(sb, "HPDrainRate", formatFloat(difficulty.HPDrainRate))
+	writeKeyValueTight(sb, "CircleSize", formatFloat(difficulty.CircleSize))
+	writeKeyValueTight(sb, "OverallDifficulty", formatFloat(difficulty.OverallDifficulty))
+	writeKeyValueTight(sb, "ApproachRate", formatFloat(difficulty.ApproachRate))
+	writeKeyValueTight(sb, "SliderMultiplier", formatFloat(difficulty.SliderMultiplier))
+	writeKeyValueTight(sb, "SliderTickRate", formatFloat(difficulty.SliderTickRate))
+}
+
+func encodeEvents(sb *strings.Builder, events []Event) {
+	writeSection(sb, "Events")
+	for _, event := range events {
+		sb.WriteString(strings.Repeat(" ", event.Depth))
+		sb.WriteString(event.EventType)
+		if eventHasStartTime(event.EventType) {
+			sb.WriteByte(',')
+			sb.WriteString(strconv.Itoa(event.StartTime))
+		}
+		for _, param := range event.EventParams {
+			sb.WriteByte(',')
+			sb.WriteString(param)
+		}
+		sb.WriteString("\r\n")
+	}
+}
+
+func encodeTimingPoints(sb *strings.Builder, timingPoints []TimingPointFile) {
+	writeSection(sb, "TimingPoints")
+	for _, tp := range timingPoints {
+		fmt.Fprintf(sb, "%d,%s,%d,%d,%d,%d,%d,%d\r\n",
+			tp.Time,
+			formatFloat(tp.BeatLength),
+			tp.Meter,
+			tp.SampleSet,
+			tp.SampleIndex,
+			tp.Volume,
+			tp.Uninherited,
+			tp.Effects,
+		)
+	}
+}
+
+func encodeColours(sb *strings.Builder, colours []Colour, unknown map[string]string) {
+	if len(colours) == 0 && len(unknown) == 0 {
+		return
+	}
+
+	writeSection(sb, "Colours")
+	combo := 0
+	for _, colour := range colours {
+		switch {
+		case colour.Combo != nil:
+			combo++
+			fmt.Fprintf(sb, "Combo%d : %s\r\n", combo, formatInts(colour.Combo, ","))
+		case colour.SliderTrackOverride != nil:
+			fmt.Fprintf(sb, "SliderTrackOverride : %s\r\n", formatInts(colour.SliderTrackOverride, ","))
+		case colour.SliderBorder != nil:
+			fmt.Fprintf(sb, "SliderBorder : %s\r\n", formatInts(colour.SliderBorder, ","))
+		}
+	}
+	encodeUnknownKeys(sb, unknown, func(sb *strings.Builder, key string, value string) {
+		fmt.Fprintf(sb, "%s : %s\r\n", key, value)
+	})
+}
+
+// encodeUnknownKeys writes the unknown keys of a section sorted by key.
+func encodeUnknownKeys(sb *strings.Builder, keys map[string]string, write func(*strings.Builder, string, string)) {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		write(sb, key, keys[key])
+	}
+}
+
+func encodeHitObjects(sb *strings.Builder, hitObjects []HitObject) {
+	writeSection(sb, "HitObjects")
+	for _, hitObject := range hitObjects {
+		fmt.Fprintf(sb, "%s,%s,%s,%d,%d",
+			formatFloat(hitObject.X),
+			formatFloat(hitObject.Y),
+			formatFloat(hitObject.Time),
+			hitObject.Type,
+			hitObject.HitSound,
+		)
+		sb.WriteByte(',')
+		sb.WriteString(formatHitObjectParams(&hitObject))
+		sb.WriteString("\r\n")
+	}
+}
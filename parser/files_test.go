@@ -0,0 +1,304 @@
+package osuParser
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEvents(t *testing.T) {
+	const osu = "osu file format v14\r\n\r\n[Events]\r\n" +
+		"0,0,\"bg.jpg\",0,0\r\n" +
+		"Video,-200,\"video.mp4\"\r\n" +
+		"2,10000,12500\r\n" +
+		"Sample,3000,0,\"hit.wav\",70\r\n" +
+		"Sprite,Foreground,Centre,\"sb.png\",320,240\r\n" +
+		" F,1,1000,2000,0,1\r\n" +
+		" M,0,1000,,320,240\r\n" +
+		" L,4000,2\r\n" +
+		"  S,2,0,500,1,2\r\n" +
+		" T,HitSoundClap,0,10000\r\n"
+
+	f, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Event{
+		{"0", 0, []string{`"bg.jpg"`, "0", "0"}, 0},
+		{"Video", -200, []string{`"video.mp4"`}, 0},
+		{"2", 10000, []string{"12500"}, 0},
+		{"Sample", 3000, []string{"0", `"hit.wav"`, "70"}, 0},
+		{"Sprite", 0, []string{"Foreground", "Centre", `"sb.png"`, "320", "240"}, 0},
+		{"F", 0, []string{"1", "1000", "2000", "0", "1"}, 1},
+		{"M", 0, []string{"0", "1000", "", "320", "240"}, 1},
+		{"L", 4000, []string{"2"}, 1},
+		{"S", 0, []string{"2", "0", "500", "1", "2"}, 2},
+		{"T", 0, []string{"HitSoundClap", "0", "10000"}, 1},
+	}
+	if len(f.Events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(f.Events), len(want))
+	}
+	for i, event := range f.Events {
+		if event.EventType != want[i].EventType || event.StartTime != want[i].StartTime ||
+			event.Depth != want[i].Depth || !slices.Equal(event.EventParams, want[i].EventParams) {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+
+	// the events are written back as they were
+	var buf bytes.Buffer
+	if err := writeOsuFile(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	events := osu[strings.Index(osu, "[Events]"):]
+	if !strings.Contains(buf.String(), events) {
+		t.Errorf("events written as\n%s", buf.String())
+	}
+}
+
+// osuFile is written the way osu! writes beatmaps: CRLF line endings, a space
+// after the colon in [General] and [Editor], none in [Metadata] and
+// [Difficulty], and spaces around it in [Colours].
+const osuFile = "osu file format v14\r\n\r\n" +
+	"[General]\r\nAudioFilename: audio.mp3\r\nAudioLeadIn: 0\r\nPreviewTime: 1234\r\nCountdown: 0\r\nSampleSet: Soft\r\n" +
+	"StackLeniency: 0.5\r\nMode: 0\r\nLetterboxInBreaks: 1\r\nEpilepsyWarning: 1\r\nWidescreenStoryboard: 1\r\n\r\n" +
+	"[Editor]\r\nBookmarks: 100,200\r\nDistanceSpacing: 1.2\r\nBeatDivisor: 4\r\nGridSize: 8\r\nTimelineZoom: 2.5\r\n\r\n" +
+	"[Metadata]\r\nTitle:Title\r\nTitleUnicode:タイトル\r\nArtist:Artist\r\nArtistUnicode:Artist\r\nCreator:Mapper\r\n" +
+	"Version:Insane\r\nSource:\r\nTags:tag1 tag2\r\nBeatmapID:1\r\nBeatmapSetID:2\r\n\r\n" +
+	"[Difficulty]\r\nHPDrainRate:5\r\nCircleSize:4\r\nOverallDifficulty:8.5\r\nApproachRate:9\r\nSliderMultiplier:1.6\r\nSliderTickRate:1\r\n\r\n" +
+	"[Events]\r\n0,0,\"bg.jpg\",0,0\r\n2,10000,12500\r\n\r\n" +
+	"[TimingPoints]\r\n0,500,4,2,0,70,1,0\r\n1000,-50,4,2,0,70,0,1\r\n\r\n" +
+	"[Colours]\r\nCombo1 : 255,128,0\r\nCombo2 : 0,0,255\r\nSliderTrackOverride : 10,20,30\r\nSliderBorder : 1,2,3\r\n\r\n" +
+	"[HitObjects]\r\n256,192,500,5,0,0:0:0:0:\r\n0,0,1000,2,0,L|100:0,1,100,2|0,0:0|0:0,0:0:0:0:\r\n0,0,2000,2,0,L|100:0,1,100\r\n"
+
+func TestWriteOsuFile(t *testing.T) {
+	// LF line endings and loose spacing are written the way osu! does
+	loose := strings.ReplaceAll(osuFile, "\r\n", "\n")
+	loose = strings.Replace(loose, "Combo2 : ", "Combo2:", 1)
+	loose = strings.Replace(loose, "Title:Title", "Title: Title", 1)
+	loose = strings.Replace(loose, "GridSize: 8", "GridSize:8", 1)
+
+	for _, osu := range []string{osuFile, loose} {
+		f, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeOsuFile(&buf, f); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != osuFile {
+			t.Errorf("written as\n%s\nwant\n%s", buf.String(), osuFile)
+		}
+	}
+}
+
+func TestOsuFileRoundTrip(t *testing.T) {
+	for _, name := range []string{"standard.osu", "taiko.osu", "catch.osu", "mania.osu"} {
+		f := parseFixture(t, name)
+
+		var buf bytes.Buffer
+		if err := writeOsuFile(&buf, f); err != nil {
+			t.Fatal(err)
+		}
+		written := buf.String()
+		if strings.Count(written, "\n") != strings.Count(written, "\r\n") {
+			t.Errorf("%s: written with LF line endings", name)
+		}
+
+		again, err := DecodeOsuFileWithOptions(strings.NewReader(written), ParseOptions{Strict: true})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(again, f) {
+			t.Errorf("%s: written file parsed as %+v, want %+v", name, again, f)
+		}
+
+		buf.Reset()
+		if err := writeOsuFile(&buf, again); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != written {
+			t.Errorf("%s: written differently the second time", name)
+		}
+	}
+}
+
+func TestGeneralAndUnknownKeys(t *testing.T) {
+	const osu = "osu file format v14\r\n\r\n" +
+		"[General]\r\nAudioFilename: song.ogg\r\nAudioLeadIn: 500\r\nAudioHash: abc\r\nPreviewTime: 1000\r\nCountdown: 2\r\n" +
+		"SampleSet: Drum\r\nStackLeniency: 0.4\r\nMode: 3\r\nLetterboxInBreaks: 1\r\nStoryFireInFront: 1\r\nUseSkinSprites: 1\r\n" +
+		"AlwaysShowPlayfield: 1\r\nOverlayPosition: Above\r\nSkinPreference: Default\r\nEpilepsyWarning: 1\r\nCountdownOffset: 2\r\n" +
+		"SpecialStyle: 1\r\nWidescreenStoryboard: 1\r\nSamplesMatchPlaybackRate: 1\r\nFutureKey: yes\r\n\r\n" +
+		"[Editor]\r\nBeatDivisor: 4\r\nEditorTool: 2\r\n\r\n" +
+		"[Metadata]\r\nTitle:Title\r\nLanguage:ja\r\n\r\n" +
+		"[Difficulty]\r\nCircleSize:7\r\nSliderBodyWidth:3\r\n\r\n" +
+		"[Colours]\r\nCombo1 : 1,2,3\r\nSliderBody : 4,5,6\r\n"
+
+	want := General{
+		AudioFilename:            "song.ogg",
+		AudioLeadIn:              500,
+		AudioHash:                "abc",
+		PreviewTime:              1000,
+		Countdown:                CountdownHalf,
+		SampleSet:                "Drum",
+		StackLeniency:            0.4,
+		Mode:                     ModeMania,
+		LetterboxInBreaks:        true,
+		StoryFireInFront:         true,
+		UseSkinSprites:           true,
+		AlwaysShowPlayfield:      true,
+		OverlayPosition:          OverlayAbove,
+		SkinPreference:           "Default",
+		EpilepsyWarning:          true,
+		CountdownOffset:          2,
+		SpecialStyle:             true,
+		WidescreenStoryboard:     true,
+		SamplesMatchPlaybackRate: true,
+	}
+	unknown := map[string]map[string]string{
+		"General":    {"FutureKey": "yes"},
+		"Editor":     {"EditorTool": "2"},
+		"Metadata":   {"Language": "ja"},
+		"Difficulty": {"SliderBodyWidth": "3"},
+		"Colours":    {"SliderBody": "4,5,6"},
+	}
+
+	f, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.General != want {
+		t.Errorf("general %+v, want %+v", f.General, want)
+	}
+	if f.UnknownKeys != nil {
+		t.Errorf("unknown keys %v kept without KeepUnknownKeys", f.UnknownKeys)
+	}
+
+	f, err = DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true, KeepUnknownKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.UnknownKeys, unknown) {
+		t.Errorf("unknown keys %v, want %v", f.UnknownKeys, unknown)
+	}
+
+	// the unknown keys are written with the spacing of their section
+	var buf bytes.Buffer
+	if err := writeOsuFile(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"FutureKey: yes", "EditorTool: 2", "Language:ja", "SliderBodyWidth:3", "SliderBody : 4,5,6"} {
+		if !strings.Contains(buf.String(), "\r\n"+line+"\r\n") {
+			t.Errorf("%q not written", line)
+		}
+	}
+	written, err := DecodeOsuFileWithOptions(&buf, ParseOptions{Strict: true, KeepUnknownKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.General != want || !reflect.DeepEqual(written.UnknownKeys, unknown) {
+		t.Errorf("written file parsed as %+v with unknown keys %v", written.General, written.UnknownKeys)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	const osu = "osu file format v14\r\n" +
+		"\r\n" +
+		"[Difficulty]\r\n" +
+		"CircleSize:abc\r\n" +
+		"OverallDifficulty:7\r\n" +
+		"\r\n" +
+		"[TimingPoints]\r\n" +
+		"0,500,4,x,0,100,1,0\r\n" +
+		"\r\n" +
+		"[HitObjects]\r\n" +
+		"256,192\r\n" +
+		"  100,1x0,500,1,0\r\n" +
+		"256,192,1000,1,0,0:0:0:0:\r\n"
+
+	want := []Warning{
+		{Section: "Difficulty", Line: 4, Column: 12},
+		{Section: "TimingPoints", Line: 8, Column: 9},
+		{Section: "HitObjects", Line: 11, Column: 1},
+		{Section: "HitObjects", Line: 12, Column: 7},
+	}
+
+	_, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("got %v, want a *ParseError", err)
+	}
+	if parseErr.Section != "Difficulty" || parseErr.Line != 4 || parseErr.Column != 12 || !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %v, want a syntax error at 4:12 in [Difficulty]", err)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "4:12: [Difficulty] ") {
+		t.Errorf("error message %q", msg)
+	}
+
+	f, err := DecodeOsuFile(strings.NewReader(osu))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Warnings) != len(want) {
+		t.Fatalf("got warnings %v, want %d", f.Warnings, len(want))
+	}
+	for i, w := range f.Warnings {
+		if w.Section != want[i].Section || w.Line != want[i].Line || w.Column != want[i].Column || w.Err == nil {
+			t.Errorf("warning %d at %s %d:%d (%v), want %s %d:%d", i, w.Section, w.Line, w.Column, w.Err, want[i].Section, want[i].Line, want[i].Column)
+		}
+	}
+	if f.OverallDifficulty != 7 || len(f.HitObjects) != 2 {
+		t.Errorf("parsed OD %v and %d hit objects around the warnings, want 7 and 2", f.OverallDifficulty, len(f.HitObjects))
+	}
+	if !errors.Is(f.Warnings[2].Err, errTooFewFields) {
+		t.Errorf("third warning %v, want too few fields", f.Warnings[2].Err)
+	}
+}
+
+func TestParseOsuFileFS(t *testing.T) {
+	data, err := os.ReadFile("testdata/standard.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"Songs/1 Set/standard.osu": {Data: data},
+		"Songs/2 Set/broken.osu":   {Data: []byte("osu file format v14\n\n[Difficulty]\nCircleSize:abc\n")},
+	}
+
+	want, err := ParseOsuFile("testdata/standard.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseOsuFileFS(fsys, "Songs/1 Set/standard.osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("parsed from the file system as %+v, want %+v", f, want)
+	}
+
+	_, err = ParseOsuFileFSWithOptions(fsys, "Songs/2 Set/broken.osu", ParseOptions{Strict: true})
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.File != "Songs/2 Set/broken.osu" || parseErr.Line != 4 {
+		t.Errorf("got %v, want a ParseError of line 4 of the file", err)
+	}
+	if f, err := ParseOsuFileFS(fsys, "Songs/2 Set/broken.osu"); err != nil || len(f.Warnings) != 1 || f.Warnings[0].File != "Songs/2 Set/broken.osu" {
+		t.Errorf("got %v, want a warning of the file", err)
+	}
+
+	if _, err := ParseOsuFileFS(fsys, "Songs/missing.osu"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want fs.ErrNotExist", err)
+	}
+	if _, err := ParseOsuFile("testdata/missing.osu"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want fs.ErrNotExist", err)
+	}
+}
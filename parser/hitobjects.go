@@ -0,0 +1,318 @@
+package osuParser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type HitObjectType int
+
+const (
+	HitObjectCircle   HitObjectType = 1 << 0
+	HitObjectSlider   HitObjectType = 1 << 1
+	HitObjectNewCombo HitObjectType = 1 << 2
+	HitObjectSpinner  HitObjectType = 1 << 3
+	HitObjectHold     HitObjectType = 1 << 7
+
+	hitObjectComboSkipMask  HitObjectType = 0b0111_0000
+	hitObjectComboSkipShift               = 4
+)
+
+// Flags of HitObject.HitSound.
+const (
+	HitSoundNormal  = 1 << 0
+	HitSoundWhistle = 1 << 1
+	HitSoundFinish  = 1 << 2
+	HitSoundClap    = 1 << 3
+)
+
+func (t HitObjectType) IsCircle() bool {
+	return t&HitObjectCircle != 0
+}
+
+func (t HitObjectType) IsSlider() bool {
+	return t&HitObjectSlider != 0
+}
+
+func (t HitObjectType) IsSpinner() bool {
+	return t&HitObjectSpinner != 0
+}
+
+func (t HitObjectType) IsHold() bool {
+	return t&HitObjectHold != 0
+}
+
+// NewCombo reports whether the object starts a new combo.
+func (t HitObjectType) NewCombo() bool {
+	return t&HitObjectNewCombo != 0
+}
+
+// ComboSkip returns how many combo colours are skipped when a new combo starts.
+func (t HitObjectType) ComboSkip() int {
+	return int(t&hitObjectComboSkipMask) >> hitObjectComboSkipShift
+}
+
+type CurveType byte
+
+const (
+	CurveBezier  CurveType = 'B'
+	CurveCatmull CurveType = 'C'
+	CurveLinear  CurveType = 'L'
+	CurvePerfect CurveType = 'P'
+)
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+func (p Point) Add(o Point) Point {
+	return Point{X: p.X + o.X, Y: p.Y + o.Y}
+}
+
+func (p Point) Sub(o Point) Point {
+	return Point{X: p.X - o.X, Y: p.Y - o.Y}
+}
+
+func (p Point) Scale(f float64) Point {
+	return Point{X: p.X * f, Y: p.Y * f}
+}
+
+func (p Point) Dot(o Point) float64 {
+	return p.X*o.X + p.Y*o.Y
+}
+
+func (p Point) LengthSquared() float64 {
+	return p.X*p.X + p.Y*p.Y
+}
+
+func (p Point) Length() float64 {
+	return math.Sqrt(p.LengthSquared())
+}
+
+func (p Point) Distance(o Point) float64 {
+	return p.Sub(o).Length()
+}
+
+// Normalized returns the unit vector in the direction of p, or p itself for
+// the zero vector.
+func (p Point) Normalized() Point {
+	length := p.Length()
+	if length == 0 {
+		return p
+	}
+	return p.Scale(1 / length)
+}
+
+type HitSample struct {
+	NormalSet   int
+	AdditionSet int
+	Index       int
+	Volume      int
+	Filename    string
+}
+
+type EdgeSet struct {
+	NormalSet   int
+	AdditionSet int
+}
+
+// HitObjectParams is one of Circle, *Slider, Spinner or Hold.
+type HitObjectParams interface {
+	hitObjectParams()
+}
+
+type Circle struct{}
+
+// Slider holds the curve of a slider. Points are the control points after
+// the head, in absolute osu!pixels.
+type Slider struct {
+	CurveType  CurveType
+	Points     []Point
+	Slides     int
+	Length     float64
+	EdgeSounds []int
+	EdgeSets   []EdgeSet
+}
+
+type Spinner struct {
+	EndTime float64
+}
+
+type Hold struct {
+	EndTime float64
+}
+
+func (Circle) hitObjectParams()  {}
+func (*Slider) hitObjectParams() {}
+func (Spinner) hitObjectParams() {}
+func (Hold) hitObjectParams()    {}
+
+// Position returns the position of the hit object.
+func (h *HitObject) Position() Point {
+	return Point{X: h.X, Y: h.Y}
+}
+
+// parseHitObjectParams parses the fields after the hitsound of a hit object,
+// which start at columns.
+func parseHitObjectParams(p *lineParser, objectType HitObjectType, extras []string, columns []int) (HitObjectParams, HitSample) {
+	var hitSample HitSample
+
+	switch {
+	case objectType.IsSlider():
+		slider := &Slider{Slides: 1}
+		if len(extras) > 0 {
+			slider.CurveType, slider.Points = parseCurve(p, extras[0], columns[0])
+		}
+		if len(extras) > 1 {
+			slider.Slides = p.atoi(extras[1], columns[1])
+		}
+		if len(extras) > 2 {
+			slider.Length = p.parseFloat(extras[2], columns[2])
+		}
+		if len(extras) > 3 && extras[3] != "" {
+			sounds, soundColumns := splitColumns(extras[3], "|", columns[3])
+			for i, v := range sounds {
+				slider.EdgeSounds = append(slider.EdgeSounds, p.atoi(v, soundColumns[i]))
+			}
+		}
+		if len(extras) > 4 && extras[4] != "" {
+			sets, setColumns := splitColumns(extras[4], "|", columns[4])
+			for i, v := range sets {
+				normalSet, additionSet, hasAdditionSet := strings.Cut(v, ":")
+				var edgeSet EdgeSet
+				edgeSet.NormalSet = p.atoi(normalSet, setColumns[i])
+				if hasAdditionSet {
+					edgeSet.AdditionSet = p.atoi(additionSet, setColumns[i]+len(normalSet)+1)
+				}
+				slider.EdgeSets = append(slider.EdgeSets, edgeSet)
+			}
+		}
+		if len(extras) > 5 {
+			hitSample = parseHitSample(p, extras[5], columns[5])
+		}
+		return slider, hitSample
+
+	case objectType.IsSpinner():
+		spinner := Spinner{}
+		if len(extras) > 0 {
+			spinner.EndTime = p.parseFloat(extras[0], columns[0])
+		}
+		if len(extras) > 1 {
+			hitSample = parseHitSample(p, extras[1], columns[1])
+		}
+		return spinner, hitSample
+
+	case objectType.IsHold():
+		hold := Hold{}
+		if len(extras) > 0 {
+			// endTime:hitSample
+			endTime, sample, hasSample := strings.Cut(extras[0], ":")
+			hold.EndTime = p.parseFloat(endTime, columns[0])
+			if hasSample {
+				hitSample = parseHitSample(p, sample, columns[0]+len(endTime)+1)
+			}
+		}
+		return hold, hitSample
+
+	default:
+		if len(extras) > 0 {
+			hitSample = parseHitSample(p, extras[0], columns[0])
+		}
+		return Circle{}, hitSample
+	}
+}
+
+func parseCurve(p *lineParser, value string, column int) (CurveType, []Point) {
+	parts, columns := splitColumns(value, "|", column)
+	curveType := CurveBezier
+	if len(parts[0]) == 1 {
+		curveType = CurveType(parts[0][0])
+	}
+
+	points := make([]Point, 0, len(parts)-1)
+	for i, part := range parts[1:] {
+		xValue, yValue, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		x := p.parseFloat(xValue, columns[i+1])
+		y := p.parseFloat(yValue, columns[i+1]+len(xValue)+1)
+		points = append(points, Point{X: x, Y: y})
+	}
+	return curveType, points
+}
+
+func parseHitSample(p *lineParser, value string, column int) HitSample {
+	parts := strings.SplitN(value, ":", 5)
+	_, columns := splitColumns(value, ":", column)
+	var hitSample HitSample
+	fields := []*int{
+		&hitSample.NormalSet,
+		&hitSample.AdditionSet,
+		&hitSample.Index,
+		&hitSample.Volume,
+	}
+	for i, field := range fields {
+		if i < len(parts) {
+			*field = p.atoi(parts[i], columns[i])
+		}
+	}
+	if len(parts) > 4 {
+		hitSample.Filename = parts[4]
+	}
+	return hitSample
+}
+
+func (s HitSample) String() string {
+	return fmt.Sprintf("%d:%d:%d:%d:%s", s.NormalSet, s.AdditionSet, s.Index, s.Volume, s.Filename)
+}
+
+func formatHitObjectParams(hitObject *HitObject) string {
+	switch params := hitObject.ObjectParams.(type) {
+	case *Slider:
+		points := make([]string, 0, len(params.Points)+1)
+		points = append(points, string(params.CurveType))
+		for _, p := range params.Points {
+			points = append(points, formatFloat(p.X)+":"+formatFloat(p.Y))
+		}
+
+		// the edge sounds, edge sets and hit sample are optional together
+		if params.EdgeSounds == nil && params.EdgeSets == nil && hitObject.HitSample == (HitSample{}) {
+			return strings.Join(points, "|") + "," + strconv.Itoa(params.Slides) + "," + formatFloat(params.Length)
+		}
+
+		edges := max(params.Slides, 0) + 1
+		edgeSounds := make([]string, edges)
+		edgeSets := make([]string, edges)
+		for i := range edgeSounds {
+			sound := 0
+			if i < len(params.EdgeSounds) {
+				sound = params.EdgeSounds[i]
+			}
+			set := EdgeSet{}
+			if i < len(params.EdgeSets) {
+				set = params.EdgeSets[i]
+			}
+			edgeSounds[i] = strconv.Itoa(sound)
+			edgeSets[i] = fmt.Sprintf("%d:%d", set.NormalSet, set.AdditionSet)
+		}
+
+		return strings.Join([]string{
+			strings.Join(points, "|"),
+			strconv.Itoa(params.Slides),
+			formatFloat(params.Length),
+			strings.Join(edgeSounds, "|"),
+			strings.Join(edgeSets, "|"),
+			hitObject.HitSample.String(),
+		}, ",")
+	case Spinner:
+		return formatFloat(params.EndTime) + "," + hitObject.HitSample.String()
+	case Hold:
+		return formatFloat(params.EndTime) + ":" + hitObject.HitSample.String()
+	default:
+		return hitObject.HitSample.String()
+	}
+}
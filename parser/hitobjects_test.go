@@ -0,0 +1,73 @@
+package osuParser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHitObjectParams(t *testing.T) {
+	tests := []struct {
+		line      string
+		newCombo  bool
+		comboSkip int
+		params    HitObjectParams
+		hitSample HitSample
+	}{
+		{"256,192,1000,5,2,1:2:0:0:", true, 0, Circle{}, HitSample{NormalSet: 1, AdditionSet: 2}},
+		{"100,100,1500,37,0,0:0:0:70:normal-hitclap.wav", true, 2, Circle{}, HitSample{Volume: 70, Filename: "normal-hitclap.wav"}},
+		{
+			"100,100,2000,2,0,B|200:100|200:100|300:150.5,2,250.5,2|0|8,0:0|1:2|0:0,0:0:0:0:", false, 0,
+			&Slider{
+				CurveType:  CurveBezier,
+				Points:     []Point{{200, 100}, {200, 100}, {300, 150.5}},
+				Slides:     2,
+				Length:     250.5,
+				EdgeSounds: []int{2, 0, 8},
+				EdgeSets:   []EdgeSet{{0, 0}, {1, 2}, {0, 0}},
+			},
+			HitSample{},
+		},
+		{
+			"0,0,3000,6,0,P|50:50|100:0,1,140,0|0,0:0|0:0,3:0:1:0:", true, 0,
+			&Slider{
+				CurveType:  CurvePerfect,
+				Points:     []Point{{50, 50}, {100, 0}},
+				Slides:     1,
+				Length:     140,
+				EdgeSounds: []int{0, 0},
+				EdgeSets:   []EdgeSet{{0, 0}, {0, 0}},
+			},
+			HitSample{NormalSet: 3, Index: 1},
+		},
+		{"256,192,4000,12,0,6000,0:0:0:0:", true, 0, Spinner{EndTime: 6000}, HitSample{}},
+		{"64,192,7000,128,0,7500:0:0:0:0:hit.wav", false, 0, Hold{EndTime: 7500}, HitSample{Filename: "hit.wav"}},
+	}
+
+	for _, test := range tests {
+		osu := "osu file format v14\r\n\r\n[HitObjects]\r\n" + test.line + "\r\n"
+		f, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+		if err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		if len(f.HitObjects) != 1 {
+			t.Errorf("%s: got %d hit objects, want 1", test.line, len(f.HitObjects))
+			continue
+		}
+
+		h := f.HitObjects[0]
+		if h.Type.NewCombo() != test.newCombo || h.Type.ComboSkip() != test.comboSkip {
+			t.Errorf("%s: new combo %v skipping %d, want %v skipping %d", test.line, h.Type.NewCombo(), h.Type.ComboSkip(), test.newCombo, test.comboSkip)
+		}
+		if !reflect.DeepEqual(h.ObjectParams, test.params) || h.HitSample != test.hitSample {
+			t.Errorf("%s: got %+v and %+v, want %+v and %+v", test.line, h.ObjectParams, h.HitSample, test.params, test.hitSample)
+		}
+
+		var sb strings.Builder
+		encodeHitObjects(&sb, f.HitObjects)
+		if got := strings.TrimPrefix(sb.String(), "\r\n[HitObjects]\r\n"); got != test.line+"\r\n" {
+			t.Errorf("%s: written as %q", test.line, got)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package osuParser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// BeatmapFields selects groups of Beatmap fields to decode. The groups follow
+// the order of the fields in osu!.db.
+type BeatmapFields uint8
+
+const (
+	// BeatmapMetadata are the artist, title, creator, difficulty name, audio
+	// file name, MD5 hash and .osu file name.
+	BeatmapMetadata BeatmapFields = 1 << iota
+	// BeatmapStats are the ranked status, object counts, modification time,
+	// difficulty settings and slider velocity.
+	BeatmapStats
+	// BeatmapStarRatings are the cached star ratings.
+	BeatmapStarRatings
+	// BeatmapTiming are the drain, total and preview times and the timing
+	// points.
+	BeatmapTiming
+	// BeatmapOnline are the ids, grades, offsets, stack leniency, game mode,
+	// source, tags and font.
+	BeatmapOnline
+	// BeatmapLocal are the last played and checked times, the folder name
+	// and the local overrides.
+	BeatmapLocal
+
+	BeatmapAllFields = BeatmapMetadata | BeatmapStats | BeatmapStarRatings | BeatmapTiming | BeatmapOnline | BeatmapLocal
+)
+
+// indexDecoderSize is the buffer size for decoding single beatmaps, which
+// are usually well below a kilobyte.
+const indexDecoderSize = 4 * 1024
+
+// OsuDBIndex is an osu!.db whose beatmaps are decoded on demand. Indexing
+// skips over every beatmap once to record the offset it starts at and its
+// MD5 hash and folder name, without decoding the rest. Beatmaps may be
+// decoded concurrently.
+type OsuDBIndex struct {
+	// OsuDB holds the header of the database. Its Beatmaps are left empty.
+	OsuDB
+
+	r       io.ReaderAt
+	closer  io.Closer
+	name    string
+	offsets []int64
+
+	hashes map[string]int
+	// folderNames is nil for versions before 20191106, whose beatmaps are
+	// skipped by their size
+	folderNames []string
+}
+
+// IndexOsuDB indexes the osu!.db filename. The file stays open until Close is
+// called.
+func IndexOsuDB(filename string) (*OsuDBIndex, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := indexOsuDB(file, filename)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	index.closer = file
+	return index, nil
+}
+
+// DecodeOsuDBIndex indexes an osu!.db read from r.
+func DecodeOsuDBIndex(r io.ReaderAt) (*OsuDBIndex, error) {
+	return indexOsuDB(r, "")
+}
+
+func indexOsuDB(r io.ReaderAt, filename string) (*OsuDBIndex, error) {
+	d := newDecoder(io.NewSectionReader(r, 0, math.MaxInt64))
+
+	header, err := readOsuDBHeader(d)
+	if err != nil {
+		return nil, dbError(filename, d, "", 0, err)
+	}
+
+	var folderNames []string
+	if header.Version >= 20191106 {
+		folderNames = make([]string, 0, capacityHint(header.NumberOfBeatmaps))
+	}
+	offsets := make([]int64, 0, capacityHint(header.NumberOfBeatmaps))
+	hashes := make(map[string]int, capacityHint(header.NumberOfBeatmaps))
+	var skipped Beatmap
+	for i := 0; i < int(header.NumberOfBeatmaps); i++ {
+		offsets = append(offsets, d.offset())
+
+		if err := indexBeatmap(d, header.Version, &skipped); err != nil {
+			return nil, dbError(filename, d, "beatmap", i, err)
+		}
+		if _, ok := hashes[skipped.MD5Hash]; !ok {
+			hashes[skipped.MD5Hash] = i
+		}
+		if folderNames != nil {
+			folderNames = append(folderNames, skipped.FolderName)
+		}
+	}
+
+	userPermissions, err := d.readInt()
+	if err != nil {
+		return nil, dbError(filename, d, "", 0, err)
+	}
+	header.UserPermissions = Permissions(userPermissions)
+
+	return &OsuDBIndex{
+		OsuDB:       *header,
+		r:           r,
+		name:        filename,
+		offsets:     offsets,
+		hashes:      hashes,
+		folderNames: folderNames,
+	}, nil
+}
+
+// indexBeatmap reads the MD5 hash and folder name of a beatmap and skips over
+// the rest. Beatmaps of versions before 20191106 start with their size, so
+// everything after the MD5 hash is skipped at once and the folder name is
+// left empty.
+func indexBeatmap(d *decoder, version int32, beatmap *Beatmap) error {
+	defer d.takeNulls()
+
+	if version >= 20191106 {
+		if err := skipBeatmapMetadata(d, &beatmap.MD5Hash); err != nil {
+			return err
+		}
+		if err := skipBeatmapStats(d, version); err != nil {
+			return err
+		}
+		if err := skipStarRatings(d, version); err != nil {
+			return err
+		}
+		if err := skipBeatmapTiming(d); err != nil {
+			return err
+		}
+		if err := skipBeatmapOnline(d); err != nil {
+			return err
+		}
+		return skipBeatmapLocal(d, version, &beatmap.FolderName)
+	}
+
+	size, err := d.readInt()
+	if err != nil {
+		return err
+	}
+	end := d.offset() + int64(size)
+
+	if err := skipBeatmapMetadata(d, &beatmap.MD5Hash); err != nil {
+		return err
+	}
+	if end < d.offset() {
+		return errors.New("invalid beatmap size")
+	}
+	return d.skip(int(end - d.offset()))
+}
+
+// Close closes the file opened by IndexOsuDB.
+func (db *OsuDBIndex) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	return db.closer.Close()
+}
+
+// Len returns the number of beatmaps.
+func (db *OsuDBIndex) Len() int {
+	return len(db.offsets)
+}
+
+// Offset returns the byte offset beatmap i starts at.
+func (db *OsuDBIndex) Offset(i int) (int64, error) {
+	if i < 0 || i >= len(db.offsets) {
+		return 0, fmt.Errorf("beatmap %d out of range [0, %d)", i, len(db.offsets))
+	}
+	return db.offsets[i], nil
+}
+
+// Find returns the index of the beatmap with the MD5 hash md5, or false if
+// there's none.
+func (db *OsuDBIndex) Find(md5 string) (int, bool) {
+	i, ok := db.hashes[md5]
+	return i, ok
+}
+
+// FolderName returns the folder name of beatmap i in the Songs directory.
+// It's decoded on demand for versions before 20191106.
+func (db *OsuDBIndex) FolderName(i int) (string, error) {
+	if db.folderNames != nil && i >= 0 && i < len(db.folderNames) {
+		return db.folderNames[i], nil
+	}
+
+	beatmap, err := db.BeatmapFields(i, BeatmapLocal)
+	if err != nil {
+		return "", err
+	}
+	return beatmap.FolderName, nil
+}
+
+// Beatmap decodes beatmap i.
+func (db *OsuDBIndex) Beatmap(i int) (*Beatmap, error) {
+	return db.BeatmapFields(i, BeatmapAllFields)
+}
+
+// BeatmapFields decodes only the fields of beatmap i, leaving the others
+// zero.
+func (db *OsuDBIndex) BeatmapFields(i int, fields BeatmapFields) (*Beatmap, error) {
+	offset, err := db.Offset(i)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDecoderSize(io.NewSectionReader(db.r, offset, math.MaxInt64-offset), indexDecoderSize)
+	d.base = offset
+
+	beatmap := &Beatmap{}
+	if err := readBeatmapFields(d, db.Version, fields, beatmap); err != nil {
+		return nil, dbError(db.name, d, "beatmap", i, err)
+	}
+	return beatmap, nil
+}
@@ -0,0 +1,62 @@
+package osuParser
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestOsuDBIndex(t *testing.T) {
+	for _, version := range []int{20140608, 20140609, 20191105, 20191106, 20250107} {
+		filename := "testdata/osu_" + strconv.Itoa(version) + ".db"
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := DecodeOsuDB(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		index, err := DecodeOsuDBIndex(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		if index.Len() != len(db.Beatmaps) || index.UserPermissions != db.UserPermissions {
+			t.Fatalf("%s: indexed %d beatmaps with permissions %v, want %d with %v",
+				filename, index.Len(), index.UserPermissions, len(db.Beatmaps), db.UserPermissions)
+		}
+		for i, want := range db.Beatmaps {
+			beatmap, err := index.Beatmap(i)
+			if err != nil {
+				t.Fatalf("%s: beatmap %d: %v", filename, i, err)
+			}
+			if !reflect.DeepEqual(beatmap, want) {
+				t.Errorf("%s: beatmap %d differs from the parsed one", filename, i)
+			}
+
+			if found, ok := index.Find(want.MD5Hash); !ok || found != i {
+				t.Errorf("%s: found %q at %d, %v, want %d", filename, want.MD5Hash, found, ok, i)
+			}
+			folderName, err := index.FolderName(i)
+			if err != nil || folderName != want.FolderName {
+				t.Errorf("%s: folder name %q, %v, want %q", filename, folderName, err, want.FolderName)
+			}
+		}
+
+		if _, ok := index.Find("missing"); ok {
+			t.Errorf("%s: found a missing hash", filename)
+		}
+		for _, i := range []int{-1, index.Len()} {
+			if _, err := index.Offset(i); err == nil {
+				t.Errorf("%s: offset of beatmap %d", filename, i)
+			}
+			if _, err := index.FolderName(i); err == nil {
+				t.Errorf("%s: folder name of beatmap %d", filename, i)
+			}
+		}
+	}
+}
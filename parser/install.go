@@ -0,0 +1,224 @@
+package osuParser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Install is an osu! installation whose osu!.db, scores.db and collection.db
+// are linked by the MD5 hash of the beatmaps.
+type Install struct {
+	// Dir is the osu! root directory.
+	Dir string
+	// BeatmapDirectory is the directory of the beatmap folders, absolute or
+	// relative to Dir. It's Songs if empty.
+	BeatmapDirectory string
+
+	OsuDB         *OsuDB
+	ScoresDB      *Scores
+	CollectionsDB *Collections
+
+	// Dangling are the hashes of scores.db and collection.db without a
+	// beatmap in osu!.db, e.g. of deleted beatmaps.
+	Dangling []DanglingReference
+
+	beatmaps map[string]*Beatmap
+	scores   map[string]*BeatmapScores
+}
+
+// DanglingReference is a beatmap hash that isn't in osu!.db.
+type DanglingReference struct {
+	// File is "scores.db" or "collection.db".
+	File string
+	// Collection is the name of the collection for collection.db.
+	Collection string
+	MD5Hash    string
+}
+
+// OpenInstall reads the databases of the osu! root directory dir. A missing
+// scores.db or collection.db is treated as empty, as osu! only creates them
+// when needed. The beatmap directory is read from the osu!.<user>.cfg of dir.
+func OpenInstall(dir string) (*Install, error) {
+	db, err := ParseOsuDB(filepath.Join(dir, "osu!.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	scores, err := ParseScoresDB(filepath.Join(dir, "scores.db"))
+	if errors.Is(err, fs.ErrNotExist) {
+		scores, err = &Scores{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := ParseCollectionsDB(filepath.Join(dir, "collection.db"))
+	if errors.Is(err, fs.ErrNotExist) {
+		collections, err = &Collections{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	beatmapDirectory, err := readBeatmapDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	install := NewInstall(dir, db, scores, collections)
+	install.BeatmapDirectory = beatmapDirectory
+	return install, nil
+}
+
+// readBeatmapDirectory returns the BeatmapDirectory setting of the
+// osu!.<user>.cfg in dir, or "" if there's none. osu! names the file after
+// the user of the system, so the one of the current user is preferred when
+// there are several.
+func readBeatmapDirectory(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "osu!.*.cfg"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+
+	filename := matches[0]
+	if current, err := user.Current(); err == nil {
+		// windows usernames include the domain
+		name := current.Username[strings.LastIndex(current.Username, `\`)+1:]
+		for _, match := range matches {
+			if strings.EqualFold(filepath.Base(match), "osu!."+name+".cfg") {
+				filename = match
+			}
+		}
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.TrimSpace(key) == "BeatmapDirectory" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// NewInstall links databases that were already read. dir is the osu! root
+// directory the .osu paths are resolved against, BeatmapDirectory can be set
+// if the beatmaps aren't in its Songs directory.
+func NewInstall(dir string, db *OsuDB, scores *Scores, collections *Collections) *Install {
+	install := &Install{
+		Dir:           dir,
+		OsuDB:         db,
+		ScoresDB:      scores,
+		CollectionsDB: collections,
+		beatmaps:      make(map[string]*Beatmap, len(db.Beatmaps)),
+		scores:        make(map[string]*BeatmapScores, len(scores.Beatmaps)),
+	}
+
+	for _, beatmap := range db.Beatmaps {
+		// keep the first of duplicate entries
+		if _, ok := install.beatmaps[beatmap.MD5Hash]; !ok {
+			install.beatmaps[beatmap.MD5Hash] = beatmap
+		}
+	}
+
+	for _, beatmap := range scores.Beatmaps {
+		install.scores[beatmap.BeatmapMD5Hash] = beatmap
+		if _, ok := install.beatmaps[beatmap.BeatmapMD5Hash]; !ok {
+			install.Dangling = append(install.Dangling, DanglingReference{
+				File:    "scores.db",
+				MD5Hash: beatmap.BeatmapMD5Hash,
+			})
+		}
+	}
+
+	for _, collection := range collections.Collections {
+		for _, hash := range collection.Beatmaps {
+			if hash == nil {
+				continue
+			}
+			if _, ok := install.beatmaps[*hash]; !ok {
+				install.Dangling = append(install.Dangling, DanglingReference{
+					File:       "collection.db",
+					Collection: collection.Name,
+					MD5Hash:    *hash,
+				})
+			}
+		}
+	}
+
+	return install
+}
+
+// Beatmap returns the beatmap with the MD5 hash md5Hash.
+func (in *Install) Beatmap(md5Hash string) (*Beatmap, bool) {
+	beatmap, ok := in.beatmaps[md5Hash]
+	return beatmap, ok
+}
+
+// Scores returns the scores of beatmap, or nil if it has none.
+func (in *Install) Scores(beatmap *Beatmap) *BeatmapScores {
+	return in.scores[beatmap.MD5Hash]
+}
+
+// ScoresBeatmap returns the beatmap scores belong to.
+func (in *Install) ScoresBeatmap(scores *BeatmapScores) (*Beatmap, bool) {
+	return in.Beatmap(scores.BeatmapMD5Hash)
+}
+
+// CollectionBeatmaps returns the beatmaps of collection in order and the
+// hashes that aren't in osu!.db.
+func (in *Install) CollectionBeatmaps(collection *Collection) ([]*Beatmap, []string) {
+	beatmaps := make([]*Beatmap, 0, len(collection.Beatmaps))
+	var missing []string
+	for _, hash := range collection.Beatmaps {
+		if hash == nil {
+			continue
+		}
+		if beatmap, ok := in.beatmaps[*hash]; ok {
+			beatmaps = append(beatmaps, beatmap)
+		} else {
+			missing = append(missing, *hash)
+		}
+	}
+	return beatmaps, missing
+}
+
+// SongsDir returns the directory of the beatmap folders of the
+// installation.
+func (in *Install) SongsDir() string {
+	switch {
+	case in.BeatmapDirectory == "":
+		return filepath.Join(in.Dir, "Songs")
+	case filepath.IsAbs(in.BeatmapDirectory):
+		return in.BeatmapDirectory
+	default:
+		return filepath.Join(in.Dir, in.BeatmapDirectory)
+	}
+}
+
+// OsuFilePath returns the path of the .osu file of beatmap.
+func (in *Install) OsuFilePath(beatmap *Beatmap) string {
+	return filepath.Join(in.SongsDir(), beatmap.FolderName, beatmap.FileName)
+}
+
+// ParseOsuFile parses the .osu file of beatmap.
+func (in *Install) ParseOsuFile(beatmap *Beatmap) (*OsuFile, error) {
+	return ParseOsuFile(in.OsuFilePath(beatmap))
+}
+
+// ParseOsuFiles parses the .osu files of all beatmaps like ParseOsuDBFiles.
+func (in *Install) ParseOsuFiles(ctx context.Context, options BulkOptions) ([]BulkResult, error) {
+	return ParseOsuDBFiles(ctx, in.OsuDB, in.SongsDir(), options)
+}
@@ -0,0 +1,61 @@
+package osuParser
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallSongsDir(t *testing.T) {
+	songs := t.TempDir()
+
+	tests := []struct {
+		cfg  string
+		want string
+	}{
+		{"", "Songs"},
+		{"# osu! configuration\r\nVolumeUniversal = 100\r\n", "Songs"},
+		{"VolumeUniversal = 100\r\nBeatmapDirectory = Beatmaps\r\n", "Beatmaps"},
+		{"BeatmapDirectory = " + songs + "\r\n", songs},
+	}
+
+	data, err := os.ReadFile("testdata/osu_20250107.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "osu!.db"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if test.cfg != "" {
+			if err := os.WriteFile(filepath.Join(dir, "osu!.player.cfg"), []byte(test.cfg), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		install, err := OpenInstall(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := test.want
+		if !filepath.IsAbs(want) {
+			want = filepath.Join(dir, want)
+		}
+		if got := install.SongsDir(); got != want {
+			t.Errorf("cfg %q: songs directory %q, want %q", test.cfg, got, want)
+		}
+
+		beatmap := install.OsuDB.Beatmaps[0]
+		if got := install.OsuFilePath(beatmap); got != filepath.Join(want, beatmap.FolderName, beatmap.FileName) {
+			t.Errorf("cfg %q: .osu path %q", test.cfg, got)
+		}
+		if _, err := install.ParseOsuFile(beatmap); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("cfg %q: parsing a missing .osu file returned %v", test.cfg, err)
+		}
+	}
+}
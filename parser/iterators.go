@@ -0,0 +1,165 @@
+package osuParser
+
+import (
+	"io"
+	"os"
+)
+
+// BeatmapSeq yields the beatmaps of an osu!.db one by one as they are
+// decoded, so memory doesn't grow with the database. It has the shape of
+// iter.Seq2[*Beatmap, error] and can be ranged over since Go 1.23, or be
+// called with a yield function that returns false to stop early. A decoding
+// error is yielded last.
+type BeatmapSeq func(yield func(*Beatmap, error) bool)
+
+// BeatmapScoresSeq yields the scores of scores.db beatmap by beatmap like
+// BeatmapSeq.
+type BeatmapScoresSeq func(yield func(*BeatmapScores, error) bool)
+
+// CollectionSeq yields the collections of collection.db one by one like
+// BeatmapSeq.
+type CollectionSeq func(yield func(*Collection, error) bool)
+
+// ParseOsuDBSeq iterates over the beatmaps of the osu!.db filename. The file
+// is opened for each iteration and closed when it stops.
+func ParseOsuDBSeq(filename string) BeatmapSeq {
+	return func(yield func(*Beatmap, error) bool) {
+		file, err := os.Open(filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		decodeOsuDBSeq(file, filename)(yield)
+	}
+}
+
+// DecodeOsuDBSeq iterates over the beatmaps of an osu!.db read from r. It
+// can only be iterated once.
+func DecodeOsuDBSeq(r io.Reader) BeatmapSeq {
+	return decodeOsuDBSeq(r, "")
+}
+
+func decodeOsuDBSeq(r io.Reader, filename string) BeatmapSeq {
+	return func(yield func(*Beatmap, error) bool) {
+		d := newDecoder(r)
+
+		db, err := readOsuDBHeader(d)
+		if err != nil {
+			yield(nil, dbError(filename, d, "", 0, err))
+			return
+		}
+
+		for i := 0; i < int(db.NumberOfBeatmaps); i++ {
+			beatmap, err := readBeatmap(d, db.Version)
+			if err != nil {
+				yield(nil, dbError(filename, d, "beatmap", i, err))
+				return
+			}
+			if !yield(beatmap, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ParseScoresDBSeq iterates over the beatmaps of the scores.db filename. The
+// file is opened for each iteration and closed when it stops.
+func ParseScoresDBSeq(filename string) BeatmapScoresSeq {
+	return func(yield func(*BeatmapScores, error) bool) {
+		file, err := os.Open(filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		decodeScoresDBSeq(file, filename)(yield)
+	}
+}
+
+// DecodeScoresDBSeq iterates over the beatmaps of a scores.db read from r. It
+// can only be iterated once.
+func DecodeScoresDBSeq(r io.Reader) BeatmapScoresSeq {
+	return decodeScoresDBSeq(r, "")
+}
+
+func decodeScoresDBSeq(r io.Reader, filename string) BeatmapScoresSeq {
+	return func(yield func(*BeatmapScores, error) bool) {
+		d := newDecoder(r)
+
+		// version
+		if _, err := d.readInt(); err != nil {
+			yield(nil, dbError(filename, d, "", 0, err))
+			return
+		}
+
+		count, err := d.readInt()
+		if err != nil {
+			yield(nil, dbError(filename, d, "", 0, err))
+			return
+		}
+
+		for i := 0; i < int(count); i++ {
+			beatmap, err := readBeatmapScore(d, filename)
+			if err != nil {
+				yield(nil, dbError(filename, d, "beatmap", i, err))
+				return
+			}
+			if !yield(beatmap, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ParseCollectionsDBSeq iterates over the collections of the collection.db
+// filename. The file is opened for each iteration and closed when it stops.
+func ParseCollectionsDBSeq(filename string) CollectionSeq {
+	return func(yield func(*Collection, error) bool) {
+		file, err := os.Open(filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		decodeCollectionsDBSeq(file, filename)(yield)
+	}
+}
+
+// DecodeCollectionsDBSeq iterates over the collections of a collection.db
+// read from r. It can only be iterated once.
+func DecodeCollectionsDBSeq(r io.Reader) CollectionSeq {
+	return decodeCollectionsDBSeq(r, "")
+}
+
+func decodeCollectionsDBSeq(r io.Reader, filename string) CollectionSeq {
+	return func(yield func(*Collection, error) bool) {
+		d := newDecoder(r)
+
+		// version
+		if _, err := d.readInt(); err != nil {
+			yield(nil, dbError(filename, d, "", 0, err))
+			return
+		}
+
+		count, err := d.readInt()
+		if err != nil {
+			yield(nil, dbError(filename, d, "", 0, err))
+			return
+		}
+
+		for i := 0; i < int(count); i++ {
+			collection, err := readCollection(d)
+			if err != nil {
+				yield(nil, dbError(filename, d, "collection", i, err))
+				return
+			}
+			if !yield(collection, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package osuParser
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// collect gathers the values of a sequence and the errors it yields.
+func collect[T any](seq func(yield func(T, error) bool)) ([]T, []error) {
+	var values []T
+	var errs []error
+	seq(func(v T, err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			values = append(values, v)
+		}
+		return true
+	})
+	return values, errs
+}
+
+func TestSeqsMatchDecoders(t *testing.T) {
+	for _, filename := range []string{"testdata/osu_20140608.db", "testdata/osu_20191106.db", "testdata/osu_20250107.db"} {
+		db, err := ParseOsuDB(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		beatmaps, errs := collect(ParseOsuDBSeq(filename))
+		if len(errs) != 0 || !reflect.DeepEqual(beatmaps, db.Beatmaps) {
+			t.Errorf("%s: iterated %d beatmaps with errors %v, want %d", filename, len(beatmaps), errs, len(db.Beatmaps))
+		}
+	}
+
+	scores, err := ParseScoresDB("testdata/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	beatmapScores, errs := collect(ParseScoresDBSeq("testdata/scores.db"))
+	if len(errs) != 0 || !reflect.DeepEqual(beatmapScores, scores.Beatmaps) {
+		t.Errorf("iterated %d beatmaps of scores with errors %v, want %d", len(beatmapScores), errs, len(scores.Beatmaps))
+	}
+
+	collections, err := ParseCollectionsDB("testdata/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iterated, errs := collect(ParseCollectionsDBSeq("testdata/collection.db"))
+	if len(errs) != 0 || !reflect.DeepEqual(iterated, collections.Collections) {
+		t.Errorf("iterated %d collections with errors %v, want %d", len(iterated), errs, len(collections.Collections))
+	}
+}
+
+// stopAfterFirst stops a sequence at its first value and returns how often
+// yield was called and the error it was called with.
+func stopAfterFirst[T any](seq func(yield func(T, error) bool)) (int, error) {
+	calls := 0
+	var first error
+	seq(func(_ T, err error) bool {
+		if calls == 0 {
+			first = err
+		}
+		calls++
+		return false
+	})
+	return calls, first
+}
+
+func TestSeqStopEarly(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files can't be counted:", err)
+	}
+
+	tests := []struct {
+		filename string
+		calls    func() (int, error)
+	}{
+		{"testdata/osu_20250107.db", func() (int, error) { return stopAfterFirst(ParseOsuDBSeq("testdata/osu_20250107.db")) }},
+		{"testdata/scores.db", func() (int, error) { return stopAfterFirst(ParseScoresDBSeq("testdata/scores.db")) }},
+		{"testdata/collection.db", func() (int, error) { return stopAfterFirst(ParseCollectionsDBSeq("testdata/collection.db")) }},
+	}
+
+	for _, test := range tests {
+		calls, err := test.calls()
+		if calls != 1 || err != nil {
+			t.Errorf("%s: yield called %d times after stopping, first with %v", test.filename, calls, err)
+		}
+
+		open, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(open) != len(fds) {
+			t.Errorf("%s: %d files open after stopping, want %d", test.filename, len(open), len(fds))
+		}
+	}
+}
+
+func TestSeqErrorsYieldedOnce(t *testing.T) {
+	data, err := os.ReadFile("testdata/osu_20250107.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last 4 bytes are the permissions, which come after the beatmaps
+	for _, n := range []int{0, 10, len(data) / 2, len(data) - 5} {
+		beatmaps, errs := collect(DecodeOsuDBSeq(bytes.NewReader(data[:n])))
+		if len(errs) != 1 {
+			t.Errorf("truncated to %d bytes: %d errors %v after %d beatmaps, want 1", n, len(errs), errs, len(beatmaps))
+		}
+	}
+
+	scores, err := os.ReadFile("testdata/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := collect(DecodeScoresDBSeq(bytes.NewReader(scores[:len(scores)-1]))); len(errs) != 1 {
+		t.Errorf("truncated scores.db: errors %v, want 1", errs)
+	}
+
+	collections, err := os.ReadFile("testdata/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := collect(DecodeCollectionsDBSeq(bytes.NewReader(collections[:len(collections)-1]))); len(errs) != 1 {
+		t.Errorf("truncated collection.db: errors %v, want 1", errs)
+	}
+
+	_, errs := collect(ParseOsuDBSeq("testdata/missing.db"))
+	if len(errs) != 1 || !errors.Is(errs[0], fs.ErrNotExist) {
+		t.Errorf("missing file: errors %v, want one fs.ErrNotExist", errs)
+	}
+}
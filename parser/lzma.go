@@ -0,0 +1,991 @@
+package osuParser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// LZMA "alone" (.lzma) stream support, the format osu! uses for the replay
+// frame payload. The layout and the decoding algorithm follow the reference
+// implementation in the LZMA SDK (LzmaSpec.cpp).
+
+const (
+	lzmaNumBitModelTotalBits = 11
+	lzmaBitModelTotal        = 1 << lzmaNumBitModelTotalBits
+	lzmaNumMoveBits          = 5
+	lzmaTopValue             = 1 << 24
+	lzmaProbInit             = lzmaBitModelTotal / 2
+
+	lzmaNumStates          = 12
+	lzmaNumPosBitsMax      = 4
+	lzmaNumLenToPosStates  = 4
+	lzmaNumAlignBits       = 4
+	lzmaStartPosModelIndex = 4
+	lzmaEndPosModelIndex   = 14
+	lzmaNumFullDistances   = 1 << (lzmaEndPosModelIndex >> 1)
+	lzmaMatchMinLen        = 2
+
+	lzmaHeaderSize  = 13
+	lzmaMinDictSize = 1 << 12
+)
+
+var errLZMACorrupted = errors.New("lzma: corrupted data")
+
+type lzmaProb uint16
+
+func initProbs(probs []lzmaProb) {
+	for i := range probs {
+		probs[i] = lzmaProbInit
+	}
+}
+
+type lzmaProperties struct {
+	lc, lp, pb uint
+	dictSize   uint32
+}
+
+func decodeLZMAProperties(d byte, dictSize uint32) (lzmaProperties, error) {
+	if d >= 9*5*5 {
+		return lzmaProperties{}, errors.New("lzma: invalid properties byte")
+	}
+	props := lzmaProperties{
+		lc:       uint(d % 9),
+		lp:       uint((d / 9) % 5),
+		pb:       uint(d / 45),
+		dictSize: dictSize,
+	}
+	if props.dictSize < lzmaMinDictSize {
+		props.dictSize = lzmaMinDictSize
+	}
+	return props, nil
+}
+
+func (p lzmaProperties) byte() byte {
+	return byte((p.pb*5+p.lp)*9 + p.lc)
+}
+
+type lzmaRangeDecoder struct {
+	r    io.ByteReader
+	rng  uint32
+	code uint32
+	err  error
+}
+
+func (rc *lzmaRangeDecoder) init() error {
+	rc.rng = 0xFFFFFFFF
+	b, err := rc.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 4; i++ {
+		c, err := rc.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		rc.code = rc.code<<8 | uint32(c)
+	}
+	if b != 0 || rc.code == rc.rng {
+		return errLZMACorrupted
+	}
+	return nil
+}
+
+func (rc *lzmaRangeDecoder) finishedOK() bool {
+	return rc.code == 0
+}
+
+func (rc *lzmaRangeDecoder) normalize() {
+	if rc.rng < lzmaTopValue {
+		rc.rng <<= 8
+		b, err := rc.r.ReadByte()
+		if err != nil && rc.err == nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			rc.err = err
+		}
+		rc.code = rc.code<<8 | uint32(b)
+	}
+}
+
+func (rc *lzmaRangeDecoder) decodeDirectBits(numBits int) uint32 {
+	var res uint32
+	for ; numBits > 0; numBits-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+		if rc.code == rc.rng && rc.err == nil {
+			rc.err = errLZMACorrupted
+		}
+		rc.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+func (rc *lzmaRangeDecoder) decodeBit(prob *lzmaProb) uint32 {
+	v := uint32(*prob)
+	bound := (rc.rng >> lzmaNumBitModelTotalBits) * v
+	var symbol uint32
+	if rc.code < bound {
+		v += (lzmaBitModelTotal - v) >> lzmaNumMoveBits
+		rc.rng = bound
+	} else {
+		v -= v >> lzmaNumMoveBits
+		rc.code -= bound
+		rc.rng -= bound
+		symbol = 1
+	}
+	*prob = lzmaProb(v)
+	rc.normalize()
+	return symbol
+}
+
+func (rc *lzmaRangeDecoder) bitTree(probs []lzmaProb, numBits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = m<<1 + rc.decodeBit(&probs[m])
+	}
+	return m - (1 << numBits)
+}
+
+func (rc *lzmaRangeDecoder) bitTreeReverse(probs []lzmaProb, numBits int) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := 0; i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = m<<1 + bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+type lzmaLenCoder struct {
+	choice  lzmaProb
+	choice2 lzmaProb
+	low     [1 << lzmaNumPosBitsMax][1 << 3]lzmaProb
+	mid     [1 << lzmaNumPosBitsMax][1 << 3]lzmaProb
+	high    [1 << 8]lzmaProb
+}
+
+func (ld *lzmaLenCoder) init() {
+	ld.choice = lzmaProbInit
+	ld.choice2 = lzmaProbInit
+	for i := range ld.low {
+		initProbs(ld.low[i][:])
+		initProbs(ld.mid[i][:])
+	}
+	initProbs(ld.high[:])
+}
+
+func (ld *lzmaLenCoder) decode(rc *lzmaRangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&ld.choice) == 0 {
+		return rc.bitTree(ld.low[posState][:], 3)
+	}
+	if rc.decodeBit(&ld.choice2) == 0 {
+		return 8 + rc.bitTree(ld.mid[posState][:], 3)
+	}
+	return 16 + rc.bitTree(ld.high[:], 8)
+}
+
+// lzmaWindow is the sliding dictionary shared by the decoder's literal and
+// match stages.
+type lzmaWindow struct {
+	buf      []byte
+	pos      int
+	isFull   bool
+	totalPos uint64
+}
+
+func (w *lzmaWindow) putByte(b byte) {
+	w.totalPos++
+	w.buf[w.pos] = b
+	w.pos++
+	if w.pos == len(w.buf) {
+		w.pos = 0
+		w.isFull = true
+	}
+}
+
+func (w *lzmaWindow) getByte(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += len(w.buf)
+	}
+	return w.buf[i]
+}
+
+func (w *lzmaWindow) checkDistance(dist uint32) bool {
+	return int64(dist) <= int64(w.pos) || w.isFull
+}
+
+func (w *lzmaWindow) isEmpty() bool {
+	return w.pos == 0 && !w.isFull
+}
+
+// lzmaModel holds the adaptive probabilities and coder state shared by the
+// decoder and the encoder.
+type lzmaModel struct {
+	props lzmaProperties
+
+	literalProbs []lzmaProb
+	posSlot      [lzmaNumLenToPosStates][1 << 6]lzmaProb
+	posDecoders  [1 + lzmaNumFullDistances - lzmaEndPosModelIndex]lzmaProb
+	align        [1 << lzmaNumAlignBits]lzmaProb
+	isMatch      [lzmaNumStates << lzmaNumPosBitsMax]lzmaProb
+	isRep        [lzmaNumStates]lzmaProb
+	isRepG0      [lzmaNumStates]lzmaProb
+	isRepG1      [lzmaNumStates]lzmaProb
+	isRepG2      [lzmaNumStates]lzmaProb
+	isRep0Long   [lzmaNumStates << lzmaNumPosBitsMax]lzmaProb
+	lenCoder     lzmaLenCoder
+	repLenCoder  lzmaLenCoder
+
+	state                  uint32
+	rep0, rep1, rep2, rep3 uint32
+}
+
+func (m *lzmaModel) init(props lzmaProperties) {
+	m.props = props
+	m.literalProbs = make([]lzmaProb, 0x300<<(props.lc+props.lp))
+	initProbs(m.literalProbs)
+	for i := range m.posSlot {
+		initProbs(m.posSlot[i][:])
+	}
+	initProbs(m.posDecoders[:])
+	initProbs(m.align[:])
+	initProbs(m.isMatch[:])
+	initProbs(m.isRep[:])
+	initProbs(m.isRepG0[:])
+	initProbs(m.isRepG1[:])
+	initProbs(m.isRepG2[:])
+	initProbs(m.isRep0Long[:])
+	m.lenCoder.init()
+	m.repLenCoder.init()
+}
+
+func (m *lzmaModel) literalProbsAt(totalPos uint64, prevByte byte) []lzmaProb {
+	litState := ((totalPos & ((1 << m.props.lp) - 1)) << m.props.lc) + uint64(prevByte>>(8-m.props.lc))
+	return m.literalProbs[0x300*litState:]
+}
+
+// lzmaReader decompresses an LZMA alone stream incrementally. Output is
+// produced on demand, so only the dictionary window is held in memory.
+type lzmaReader struct {
+	lzmaModel
+	rc  lzmaRangeDecoder
+	win lzmaWindow
+
+	unpackSize        uint64
+	unpackSizeDefined bool
+	markerIsMandatory bool
+
+	remLen   uint32
+	finished bool
+	err      error
+}
+
+func newLZMAReader(r io.Reader) (*lzmaReader, error) {
+	var header [lzmaHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	props, err := decodeLZMAProperties(header[0], binary.LittleEndian.Uint32(header[1:5]))
+	if err != nil {
+		return nil, err
+	}
+
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	z := &lzmaReader{
+		rc: lzmaRangeDecoder{r: br},
+	}
+	z.init(props)
+
+	z.unpackSize = binary.LittleEndian.Uint64(header[5:13])
+	z.unpackSizeDefined = z.unpackSize != ^uint64(0)
+	z.markerIsMandatory = !z.unpackSizeDefined
+
+	bufSize := uint64(props.dictSize)
+	if z.unpackSizeDefined && z.unpackSize < bufSize {
+		bufSize = z.unpackSize
+	}
+	if bufSize < lzmaMinDictSize {
+		bufSize = lzmaMinDictSize
+	}
+	z.win.buf = make([]byte, bufSize)
+
+	if err := z.rc.init(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return z, nil
+}
+
+func (z *lzmaReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if z.remLen > 0 {
+			b := z.win.getByte(z.rep0 + 1)
+			z.win.putByte(b)
+			p[n] = b
+			n++
+			z.remLen--
+			continue
+		}
+		if z.err != nil || z.finished {
+			break
+		}
+		if b, ok := z.decodePacket(); ok {
+			p[n] = b
+			n++
+		}
+		if z.err == nil && z.rc.err != nil {
+			z.err = z.rc.err
+		}
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	return 0, io.EOF
+}
+
+// decodePacket decodes the next literal or match. A literal or short rep is
+// returned directly; longer matches are queued in remLen for Read to copy.
+func (z *lzmaReader) decodePacket() (byte, bool) {
+	rc := &z.rc
+
+	if z.unpackSizeDefined && z.unpackSize == 0 && !z.markerIsMandatory && rc.finishedOK() {
+		z.finished = true
+		return 0, false
+	}
+
+	posState := uint32(z.win.totalPos & ((1 << z.props.pb) - 1))
+
+	if rc.decodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState]) == 0 {
+		if z.unpackSizeDefined && z.unpackSize == 0 {
+			z.err = errLZMACorrupted
+			return 0, false
+		}
+		b := z.decodeLiteral()
+		z.state = lzmaUpdateLiteral(z.state)
+		z.unpackSize--
+		return b, true
+	}
+
+	var length uint32
+	if rc.decodeBit(&z.isRep[z.state]) != 0 {
+		if z.unpackSizeDefined && z.unpackSize == 0 || z.win.isEmpty() {
+			z.err = errLZMACorrupted
+			return 0, false
+		}
+		if rc.decodeBit(&z.isRepG0[z.state]) == 0 {
+			if rc.decodeBit(&z.isRep0Long[(z.state<<lzmaNumPosBitsMax)+posState]) == 0 {
+				z.state = lzmaUpdateShortRep(z.state)
+				b := z.win.getByte(z.rep0 + 1)
+				z.win.putByte(b)
+				z.unpackSize--
+				return b, true
+			}
+		} else {
+			var dist uint32
+			if rc.decodeBit(&z.isRepG1[z.state]) == 0 {
+				dist = z.rep1
+			} else {
+				if rc.decodeBit(&z.isRepG2[z.state]) == 0 {
+					dist = z.rep2
+				} else {
+					dist = z.rep3
+					z.rep3 = z.rep2
+				}
+				z.rep2 = z.rep1
+			}
+			z.rep1 = z.rep0
+			z.rep0 = dist
+		}
+		length = z.repLenCoder.decode(rc, posState)
+		z.state = lzmaUpdateRep(z.state)
+	} else {
+		z.rep3 = z.rep2
+		z.rep2 = z.rep1
+		z.rep1 = z.rep0
+		length = z.lenCoder.decode(rc, posState)
+		z.state = lzmaUpdateMatch(z.state)
+		z.rep0 = z.decodeDistance(length)
+		if z.rep0 == 0xFFFFFFFF {
+			if !rc.finishedOK() {
+				z.err = errLZMACorrupted
+			}
+			z.finished = true
+			return 0, false
+		}
+		if z.unpackSizeDefined && z.unpackSize == 0 ||
+			z.rep0 >= z.props.dictSize || !z.win.checkDistance(z.rep0) {
+			z.err = errLZMACorrupted
+			return 0, false
+		}
+	}
+
+	length += lzmaMatchMinLen
+	if z.unpackSizeDefined && z.unpackSize < uint64(length) {
+		z.err = errLZMACorrupted
+		length = uint32(z.unpackSize)
+	}
+	z.remLen = length
+	z.unpackSize -= uint64(length)
+	return 0, false
+}
+
+func (z *lzmaReader) decodeLiteral() byte {
+	var prevByte byte
+	if !z.win.isEmpty() {
+		prevByte = z.win.getByte(1)
+	}
+	probs := z.literalProbsAt(z.win.totalPos, prevByte)
+
+	symbol := uint32(1)
+	if z.state >= 7 {
+		matchByte := uint32(z.win.getByte(z.rep0 + 1))
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := z.rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | z.rc.decodeBit(&probs[symbol])
+	}
+
+	b := byte(symbol - 0x100)
+	z.win.putByte(b)
+	return b
+}
+
+func (z *lzmaReader) decodeDistance(length uint32) uint32 {
+	lenState := length
+	if lenState > lzmaNumLenToPosStates-1 {
+		lenState = lzmaNumLenToPosStates - 1
+	}
+
+	posSlot := z.rc.bitTree(z.posSlot[lenState][:], 6)
+	if posSlot < 4 {
+		return posSlot
+	}
+
+	numDirectBits := int(posSlot>>1) - 1
+	dist := (2 | (posSlot & 1)) << numDirectBits
+	if posSlot < lzmaEndPosModelIndex {
+		dist += z.rc.bitTreeReverse(z.posDecoders[dist-posSlot:], numDirectBits)
+	} else {
+		dist += z.rc.decodeDirectBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+		dist += z.rc.bitTreeReverse(z.align[:], lzmaNumAlignBits)
+	}
+	return dist
+}
+
+func lzmaUpdateLiteral(state uint32) uint32 {
+	switch {
+	case state < 4:
+		return 0
+	case state < 10:
+		return state - 3
+	default:
+		return state - 6
+	}
+}
+
+func lzmaUpdateMatch(state uint32) uint32 {
+	if state < 7 {
+		return 7
+	}
+	return 10
+}
+
+func lzmaUpdateRep(state uint32) uint32 {
+	if state < 7 {
+		return 8
+	}
+	return 11
+}
+
+func lzmaUpdateShortRep(state uint32) uint32 {
+	if state < 7 {
+		return 9
+	}
+	return 11
+}
+
+const (
+	lzmaMatchMaxLen   = lzmaMatchMinLen + 16 + (1 << 8) - 1
+	lzmaHashBits      = 16
+	lzmaMaxChainDepth = 32
+
+	// osu! writes replays with the 7-Zip defaults: lc=3, lp=0, pb=2.
+	lzmaDefaultDictSize = 1 << 21
+)
+
+var lzmaDefaultProperties = lzmaProperties{lc: 3, lp: 0, pb: 2, dictSize: lzmaDefaultDictSize}
+
+type lzmaRangeEncoder struct {
+	w         *bufio.Writer
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize int64
+	err       error
+}
+
+func (rc *lzmaRangeEncoder) init(w *bufio.Writer) {
+	rc.w = w
+	rc.rng = 0xFFFFFFFF
+	rc.cacheSize = 1
+}
+
+func (rc *lzmaRangeEncoder) shiftLow() {
+	if uint32(rc.low) < 0xFF000000 || rc.low>>32 != 0 {
+		temp := rc.cache
+		for {
+			if err := rc.w.WriteByte(temp + byte(rc.low>>32)); err != nil && rc.err == nil {
+				rc.err = err
+			}
+			temp = 0xFF
+			rc.cacheSize--
+			if rc.cacheSize == 0 {
+				break
+			}
+		}
+		rc.cache = byte(uint32(rc.low) >> 24)
+	}
+	rc.cacheSize++
+	rc.low = uint64(uint32(rc.low) << 8)
+}
+
+func (rc *lzmaRangeEncoder) flush() {
+	for i := 0; i < 5; i++ {
+		rc.shiftLow()
+	}
+}
+
+func (rc *lzmaRangeEncoder) encodeBit(prob *lzmaProb, bit uint32) {
+	v := uint32(*prob)
+	bound := (rc.rng >> lzmaNumBitModelTotalBits) * v
+	if bit == 0 {
+		rc.rng = bound
+		v += (lzmaBitModelTotal - v) >> lzmaNumMoveBits
+	} else {
+		rc.low += uint64(bound)
+		rc.rng -= bound
+		v -= v >> lzmaNumMoveBits
+	}
+	*prob = lzmaProb(v)
+	for rc.rng < lzmaTopValue {
+		rc.rng <<= 8
+		rc.shiftLow()
+	}
+}
+
+func (rc *lzmaRangeEncoder) encodeDirectBits(value uint32, numBits int) {
+	for i := numBits - 1; i >= 0; i-- {
+		rc.rng >>= 1
+		if (value>>uint(i))&1 != 0 {
+			rc.low += uint64(rc.rng)
+		}
+		if rc.rng < lzmaTopValue {
+			rc.rng <<= 8
+			rc.shiftLow()
+		}
+	}
+}
+
+func (rc *lzmaRangeEncoder) bitTree(probs []lzmaProb, numBits int, symbol uint32) {
+	m := uint32(1)
+	for i := numBits - 1; i >= 0; i-- {
+		bit := (symbol >> uint(i)) & 1
+		rc.encodeBit(&probs[m], bit)
+		m = m<<1 | bit
+	}
+}
+
+func (rc *lzmaRangeEncoder) bitTreeReverse(probs []lzmaProb, numBits int, symbol uint32) {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		bit := symbol & 1
+		rc.encodeBit(&probs[m], bit)
+		m = m<<1 | bit
+		symbol >>= 1
+	}
+}
+
+func (ld *lzmaLenCoder) encode(rc *lzmaRangeEncoder, length uint32, posState uint32) {
+	switch {
+	case length < 8:
+		rc.encodeBit(&ld.choice, 0)
+		rc.bitTree(ld.low[posState][:], 3, length)
+	case length < 16:
+		rc.encodeBit(&ld.choice, 1)
+		rc.encodeBit(&ld.choice2, 0)
+		rc.bitTree(ld.mid[posState][:], 3, length-8)
+	default:
+		rc.encodeBit(&ld.choice, 1)
+		rc.encodeBit(&ld.choice2, 1)
+		rc.bitTree(ld.high[:], 8, length-16)
+	}
+}
+
+// lzmaWriter compresses into an LZMA alone stream. It uses a greedy
+// hash-chain match finder, which is far simpler than the 7-Zip optimum
+// parser but produces streams any LZMA decoder, including osu!'s, accepts.
+type lzmaWriter struct {
+	lzmaModel
+	rc lzmaRangeEncoder
+	bw *bufio.Writer
+
+	size    int64
+	written int64
+
+	// buf holds at least dictSize bytes of history followed by the
+	// lookahead that has not been encoded yet. base is the absolute stream
+	// position of buf[0], pos the absolute position of the next byte to encode.
+	buf  []byte
+	base int64
+	pos  int64
+
+	head  []int64
+	chain []int64
+
+	closed bool
+}
+
+// newLZMAWriter writes an LZMA alone header and returns a writer for the
+// payload. A negative size writes an unknown size and terminates the stream
+// with an end marker on Close.
+func newLZMAWriter(w io.Writer, size int64) (*lzmaWriter, error) {
+	props := lzmaDefaultProperties
+	if size >= 0 && size < int64(props.dictSize) {
+		props.dictSize = uint32(max(size, lzmaMinDictSize))
+	}
+
+	z := &lzmaWriter{
+		bw:    bufio.NewWriter(w),
+		size:  size,
+		head:  make([]int64, 1<<lzmaHashBits),
+		chain: make([]int64, props.dictSize),
+	}
+	z.init(props)
+	z.rc.init(z.bw)
+
+	var header [lzmaHeaderSize]byte
+	header[0] = props.byte()
+	binary.LittleEndian.PutUint32(header[1:5], props.dictSize)
+	if size < 0 {
+		binary.LittleEndian.PutUint64(header[5:13], ^uint64(0))
+	} else {
+		binary.LittleEndian.PutUint64(header[5:13], uint64(size))
+	}
+	if _, err := z.bw.Write(header[:]); err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+func (z *lzmaWriter) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("lzma: write to closed writer")
+	}
+	if z.size >= 0 && z.written+int64(len(p)) > z.size {
+		return 0, errors.New("lzma: write exceeds declared size")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > int(z.props.dictSize) {
+			chunk = chunk[:z.props.dictSize]
+		}
+		z.buf = append(z.buf, chunk...)
+		z.written += int64(len(chunk))
+		n += len(chunk)
+		p = p[len(chunk):]
+
+		for z.end()-z.pos >= lzmaMatchMaxLen {
+			z.encodeNext()
+		}
+		z.slide()
+
+		if z.rc.err != nil {
+			return n, z.rc.err
+		}
+	}
+	return n, nil
+}
+
+// Close encodes the remaining lookahead and flushes the stream. It does not
+// close the underlying writer.
+func (z *lzmaWriter) Close() error {
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+
+	if z.size >= 0 && z.written != z.size {
+		return errors.New("lzma: written data does not match declared size")
+	}
+
+	for z.pos < z.end() {
+		z.encodeNext()
+	}
+	if z.size < 0 {
+		z.encodeEndMarker()
+	}
+	z.rc.flush()
+
+	if z.rc.err != nil {
+		return z.rc.err
+	}
+	return z.bw.Flush()
+}
+
+func (z *lzmaWriter) end() int64 {
+	return z.base + int64(len(z.buf))
+}
+
+func (z *lzmaWriter) at(pos int64) byte {
+	return z.buf[pos-z.base]
+}
+
+// slide drops history that can no longer be referenced by a match.
+func (z *lzmaWriter) slide() {
+	keep := z.pos - int64(z.props.dictSize)
+	if keep-z.base < int64(z.props.dictSize) {
+		return
+	}
+	n := copy(z.buf, z.buf[keep-z.base:])
+	z.buf = z.buf[:n]
+	z.base = keep
+}
+
+func (z *lzmaWriter) hash(pos int64) uint32 {
+	i := pos - z.base
+	v := uint32(z.buf[i]) | uint32(z.buf[i+1])<<8 | uint32(z.buf[i+2])<<16
+	return (v * 2654435761) >> (32 - lzmaHashBits)
+}
+
+func (z *lzmaWriter) insert(pos int64) {
+	if z.end()-pos < 3 {
+		return
+	}
+	h := z.hash(pos)
+	z.chain[pos%int64(z.props.dictSize)] = z.head[h]
+	z.head[h] = pos + 1
+}
+
+func (z *lzmaWriter) matchLen(pos, cand int64, limit int) int {
+	a := z.buf[pos-z.base:]
+	b := z.buf[cand-z.base:]
+	n := 0
+	for n < limit && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func (z *lzmaWriter) findMatch(limit int) (int, uint32) {
+	if limit < 3 {
+		return 0, 0
+	}
+
+	bestLen := 0
+	var bestDist uint32
+
+	cand := z.head[z.hash(z.pos)] - 1
+	for depth := 0; depth < lzmaMaxChainDepth && cand >= z.base && cand < z.pos; depth++ {
+		dist := z.pos - cand
+		if dist > int64(z.props.dictSize) {
+			break
+		}
+		if bestLen > 0 && z.at(cand+int64(bestLen)) != z.at(z.pos+int64(bestLen)) {
+			// cannot beat the current best match
+		} else if l := z.matchLen(z.pos, cand, limit); l > bestLen {
+			bestLen = l
+			bestDist = uint32(dist - 1)
+			if l == limit {
+				break
+			}
+		}
+
+		next := z.chain[cand%int64(z.props.dictSize)] - 1
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+
+	if bestLen == 3 && bestDist >= 1<<14 {
+		return 0, 0
+	}
+	return bestLen, bestDist
+}
+
+func (z *lzmaWriter) encodeNext() {
+	limit := int(z.end() - z.pos)
+	if limit > lzmaMatchMaxLen {
+		limit = lzmaMatchMaxLen
+	}
+	posState := uint32(z.pos) & ((1 << z.props.pb) - 1)
+
+	repLen := 0
+	if repPos := z.pos - int64(z.rep0) - 1; z.pos > 0 && repPos >= z.base {
+		repLen = z.matchLen(z.pos, repPos, limit)
+	}
+	matchLen, matchDist := z.findMatch(limit)
+
+	length := 1
+	switch {
+	case repLen >= lzmaMatchMinLen && repLen+1 >= matchLen:
+		z.encodeRep0(repLen, posState)
+		length = repLen
+	case matchLen >= 3:
+		z.encodeMatch(matchDist, matchLen, posState)
+		length = matchLen
+	case repLen == 1 && z.state >= 7:
+		z.encodeShortRep(posState)
+	default:
+		z.encodeLiteral(posState)
+	}
+
+	for i := 0; i < length; i++ {
+		z.insert(z.pos)
+		z.pos++
+	}
+}
+
+func (z *lzmaWriter) encodeLiteral(posState uint32) {
+	rc := &z.rc
+	rc.encodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState], 0)
+
+	var prevByte byte
+	if z.pos > 0 {
+		prevByte = z.at(z.pos - 1)
+	}
+	probs := z.literalProbsAt(uint64(z.pos), prevByte)
+	symbol := uint32(z.at(z.pos))
+
+	context := uint32(1)
+	if z.state >= 7 {
+		matchByte := uint32(z.at(z.pos - int64(z.rep0) - 1))
+		same := true
+		for i := 7; i >= 0; i-- {
+			bit := (symbol >> uint(i)) & 1
+			state := context
+			if same {
+				matchBit := (matchByte >> uint(i)) & 1
+				state += (1 + matchBit) << 8
+				same = matchBit == bit
+			}
+			rc.encodeBit(&probs[state], bit)
+			context = context<<1 | bit
+		}
+	} else {
+		for i := 7; i >= 0; i-- {
+			bit := (symbol >> uint(i)) & 1
+			rc.encodeBit(&probs[context], bit)
+			context = context<<1 | bit
+		}
+	}
+
+	z.state = lzmaUpdateLiteral(z.state)
+}
+
+func (z *lzmaWriter) encodeShortRep(posState uint32) {
+	rc := &z.rc
+	rc.encodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState], 1)
+	rc.encodeBit(&z.isRep[z.state], 1)
+	rc.encodeBit(&z.isRepG0[z.state], 0)
+	rc.encodeBit(&z.isRep0Long[(z.state<<lzmaNumPosBitsMax)+posState], 0)
+	z.state = lzmaUpdateShortRep(z.state)
+}
+
+func (z *lzmaWriter) encodeRep0(length int, posState uint32) {
+	rc := &z.rc
+	rc.encodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState], 1)
+	rc.encodeBit(&z.isRep[z.state], 1)
+	rc.encodeBit(&z.isRepG0[z.state], 0)
+	rc.encodeBit(&z.isRep0Long[(z.state<<lzmaNumPosBitsMax)+posState], 1)
+	z.repLenCoder.encode(rc, uint32(length-lzmaMatchMinLen), posState)
+	z.state = lzmaUpdateRep(z.state)
+}
+
+func (z *lzmaWriter) encodeMatch(dist uint32, length int, posState uint32) {
+	rc := &z.rc
+	rc.encodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState], 1)
+	rc.encodeBit(&z.isRep[z.state], 0)
+	z.lenCoder.encode(rc, uint32(length-lzmaMatchMinLen), posState)
+	z.state = lzmaUpdateMatch(z.state)
+	z.encodeDistance(dist, uint32(length-lzmaMatchMinLen))
+
+	z.rep3 = z.rep2
+	z.rep2 = z.rep1
+	z.rep1 = z.rep0
+	z.rep0 = dist
+}
+
+func (z *lzmaWriter) encodeEndMarker() {
+	posState := uint32(z.pos) & ((1 << z.props.pb) - 1)
+	rc := &z.rc
+	rc.encodeBit(&z.isMatch[(z.state<<lzmaNumPosBitsMax)+posState], 1)
+	rc.encodeBit(&z.isRep[z.state], 0)
+	z.lenCoder.encode(rc, 0, posState)
+	z.state = lzmaUpdateMatch(z.state)
+	z.encodeDistance(0xFFFFFFFF, 0)
+}
+
+func (z *lzmaWriter) encodeDistance(dist uint32, length uint32) {
+	lenState := length
+	if lenState > lzmaNumLenToPosStates-1 {
+		lenState = lzmaNumLenToPosStates - 1
+	}
+
+	posSlot := lzmaPosSlot(dist)
+	z.rc.bitTree(z.posSlot[lenState][:], 6, posSlot)
+	if posSlot < lzmaStartPosModelIndex {
+		return
+	}
+
+	footerBits := int(posSlot>>1) - 1
+	base := (2 | (posSlot & 1)) << footerBits
+	reduced := dist - base
+	if posSlot < lzmaEndPosModelIndex {
+		z.rc.bitTreeReverse(z.posDecoders[base-posSlot:], footerBits, reduced)
+	} else {
+		z.rc.encodeDirectBits(reduced>>lzmaNumAlignBits, footerBits-lzmaNumAlignBits)
+		z.rc.bitTreeReverse(z.align[:], lzmaNumAlignBits, reduced&(1<<lzmaNumAlignBits-1))
+	}
+}
+
+func lzmaPosSlot(dist uint32) uint32 {
+	if dist < lzmaStartPosModelIndex {
+		return dist
+	}
+	n := uint32(bits.Len32(dist)) - 1
+	return 2*n + (dist>>(n-1))&1
+}
@@ -0,0 +1,137 @@
+package osuParser
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func lzmaCompress(t *testing.T, data []byte, size int64) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := newLZMAWriter(&buf, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func lzmaDecompress(t *testing.T, compressed []byte) []byte {
+	t.Helper()
+
+	r, err := newLZMAReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLZMARoundTrip(t *testing.T) {
+	random := make([]byte, 100_000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	frames, err := os.ReadFile("testdata/frames.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"single byte", []byte("a")},
+		{"frames", frames},
+		{"repeated", bytes.Repeat([]byte("0|256|-500|0,"), 50_000)},
+		{"random", random},
+		{"long run", make([]byte, 3<<20)},
+		{"text", []byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 1000))},
+	}
+
+	for _, test := range tests {
+		for _, size := range []int64{int64(len(test.data)), -1} {
+			compressed := lzmaCompress(t, test.data, size)
+			got := lzmaDecompress(t, compressed)
+			if !bytes.Equal(got, test.data) {
+				t.Errorf("%s (size %d): round trip differs, got %d bytes, want %d", test.name, size, len(got), len(test.data))
+			}
+		}
+	}
+}
+
+func TestLZMADecodeReference(t *testing.T) {
+	// frames.lzma was written by the xz utils encoder with an end marker
+	compressed, err := os.ReadFile("testdata/frames.lzma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/frames.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := lzmaDecompress(t, compressed); !bytes.Equal(got, want) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestLZMATruncated(t *testing.T) {
+	frames, err := os.ReadFile("testdata/frames.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := lzmaCompress(t, frames, -1)
+
+	for _, n := range []int{0, 5, lzmaHeaderSize, len(compressed) / 2, len(compressed) - 1} {
+		r, err := newLZMAReader(bytes.NewReader(compressed[:n]))
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		if err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+}
+
+func TestReplayFrames(t *testing.T) {
+	compressed, err := os.ReadFile("testdata/frames.lzma")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, seed, err := decodeReplayData(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != 7262 {
+		t.Errorf("seed = %d, want 7262", seed)
+	}
+	if len(frames) != 402 {
+		t.Fatalf("got %d frames, want 402", len(frames))
+	}
+	if frames[1].W != -1 || frames[1].Time != -1 || frames[1].X != 256 || frames[1].Y != -500 {
+		t.Errorf("frames[1] = %+v", frames[1])
+	}
+	last := frames[len(frames)-1]
+	var time int64
+	for _, frame := range frames {
+		time += frame.W
+	}
+	if last.Time != time {
+		t.Errorf("last frame time = %d, want %d", last.Time, time)
+	}
+}
@@ -0,0 +1,178 @@
+package osuParser
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// ManiaDifficulty are the difficulty attributes of a beatmap in osu!mania,
+// as calculated by the osu! difficulty algorithm.
+type ManiaDifficulty struct {
+	Mods           Mods
+	StarRating     float64
+	GreatHitWindow float64
+	MaxCombo       int
+}
+
+const (
+	maniaDifficultyMultiplier = 0.018
+
+	maniaIndividualDecayBase = 0.125
+	maniaOverallDecayBase    = 0.30
+	maniaReleaseThreshold    = 24
+)
+
+// ManiaObject is a note or hold note of an osu!mania beatmap. Notes end at
+// their start time.
+type ManiaObject struct {
+	Column    int
+	StartTime float64
+	EndTime   float64
+}
+
+// maniaGreatHitWindow is the great hit window the pp calculation uses.
+// Converted beatmaps use a fixed window depending on the overall difficulty.
+func maniaGreatHitWindow(od float64, converted bool, mods Mods) float64 {
+	window := 34 + 3*math.Min(10, math.Max(0, 10-od))
+	if converted {
+		window = 47
+		if math.RoundToEven(od) > 4 {
+			window = 34
+		}
+	}
+
+	if mods.Has(ModHardRock) {
+		window /= 1.4
+	} else if mods.Has(ModEasy) {
+		window *= 1.4
+	}
+
+	clockRate := mods.ClockRate()
+	window *= clockRate
+
+	// truncated like osu! does
+	return math.Ceil(float64(int(window*clockRate)) / clockRate)
+}
+
+// maniaStrain is the strain of pressing and holding keys, both per column
+// and over all columns.
+type maniaStrain struct {
+	strainPeaks
+
+	startTimes        []float64
+	endTimes          []float64
+	individualStrains []float64
+
+	individualStrain float64
+	overallStrain    float64
+}
+
+func newManiaStrain(columns int) *maniaStrain {
+	return &maniaStrain{
+		startTimes:        make([]float64, columns),
+		endTimes:          make([]float64, columns),
+		individualStrains: make([]float64, columns),
+		overallStrain:     1,
+	}
+}
+
+// process records the strain of the object at index, with times already
+// divided by the clock rate.
+func (s *maniaStrain) process(index, column int, startTime, endTime, deltaTime, previousStartTime float64) {
+	s.strainPeaks.process(index, startTime, func() float64 {
+		return s.strainValueOf(column, startTime, endTime, deltaTime)
+	}, func(time float64) float64 {
+		return math.Pow(maniaIndividualDecayBase, (time-previousStartTime)/1000)*s.individualStrain +
+			math.Pow(maniaOverallDecayBase, (time-previousStartTime)/1000)*s.overallStrain
+	})
+}
+
+func (s *maniaStrain) strainValueOf(column int, startTime, endTime, deltaTime float64) float64 {
+	isOverlapping := false
+	// lowest value we can assume with the current information
+	closestEndTime := math.Abs(endTime - startTime)
+	// factor to all additional strains in case something else is held
+	holdFactor := 1.0
+	// addition to the current note in case it's a hold and has to be
+	// released awkwardly
+	holdAddition := 0.0
+
+	for i := range s.endTimes {
+		// the current note is overlapped if a previous note or end is
+		// overlapping the current note body
+		isOverlapping = isOverlapping || (s.endTimes[i]-1 > startTime && endTime-1 > s.endTimes[i])
+
+		// slight bonus to everything if something is held meanwhile
+		if s.endTimes[i]-1 > endTime {
+			holdFactor = 1.25
+		}
+
+		closestEndTime = math.Min(closestEndTime, math.Abs(endTime-s.endTimes[i]))
+	}
+
+	// releasing multiple notes is just as easy as releasing one, so the
+	// hold addition is halved when the closest release is
+	// maniaReleaseThreshold away
+	if isOverlapping {
+		holdAddition = 1 / (1 + math.Exp(0.5*(maniaReleaseThreshold-closestEndTime)))
+	}
+
+	s.individualStrains[column] *= strainDecay(maniaIndividualDecayBase, startTime-s.startTimes[column])
+	s.individualStrains[column] += 2 * holdFactor
+
+	// for chords the hardest column counts
+	if deltaTime <= 1 {
+		s.individualStrain = math.Max(s.individualStrain, s.individualStrains[column])
+	} else {
+		s.individualStrain = s.individualStrains[column]
+	}
+
+	s.overallStrain *= strainDecay(maniaOverallDecayBase, deltaTime)
+	s.overallStrain += (1 + holdAddition) * holdFactor
+
+	s.startTimes[column] = startTime
+	s.endTimes[column] = endTime
+
+	return s.individualStrain + s.overallStrain
+}
+
+// ManiaDifficulty calculates the osu!mania difficulty attributes of the
+// beatmap with mods applied.
+func (f *OsuFile) ManiaDifficulty(mods Mods) *ManiaDifficulty {
+	converted := f.Mode != ModeMania
+	clockRate := mods.ClockRate()
+	keyCount := f.DifficultyAttributes(ModeMania, mods).KeyCount
+
+	difficulty := &ManiaDifficulty{
+		Mods:           mods,
+		GreatHitWindow: maniaGreatHitWindow(f.OverallDifficulty, converted, mods),
+	}
+
+	objects := f.ManiaObjects(mods)
+	for _, o := range objects {
+		// hold notes give combo every 100ms
+		difficulty.MaxCombo += 1 + int((o.EndTime-o.StartTime)/100)
+	}
+
+	// osu! sorts by the rounded start time with a stable sort
+	slices.SortStableFunc(objects, func(a, b ManiaObject) int {
+		return cmp.Compare(int(math.RoundToEven(a.StartTime)), int(math.RoundToEven(b.StartTime)))
+	})
+
+	strain := newManiaStrain(keyCount)
+	for i := 1; i < len(objects); i++ {
+		current := objects[i]
+		previous := objects[i-1]
+
+		strain.process(i-1, current.Column,
+			current.StartTime/clockRate,
+			current.EndTime/clockRate,
+			(current.StartTime-previous.StartTime)/clockRate,
+			previous.StartTime/clockRate,
+		)
+	}
+
+	difficulty.StarRating = weightedSum(strain.all(), 0.9) * maniaDifficultyMultiplier
+	return difficulty
+}
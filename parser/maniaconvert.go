@@ -0,0 +1,915 @@
+package osuParser
+
+import (
+	"math"
+)
+
+// maniaPatternType are the flags deciding how a hit object is turned into
+// notes when converting to osu!mania.
+type maniaPatternType int
+
+const (
+	// keep the same columns as the previous pattern
+	maniaForceStack maniaPatternType = 1 << iota
+	// avoid the columns of the previous pattern
+	maniaForceNotStack
+	// only generate a single note
+	maniaKeepSingle
+	// lower the chance of generating chords
+	maniaLowProbability
+	// place notes next to each other
+	maniaGathered
+	// mirror notes around the centre
+	maniaMirror
+	// mirror the columns of the previous pattern
+	maniaReverse
+	// mirror the column of a single previous note
+	maniaCycle
+	// step one column to the right of the previous note
+	maniaStair
+	// step one column to the left of the previous note
+	maniaReverseStair
+)
+
+// maniaDensityNotes is how many of the last notes the density is measured
+// over.
+const maniaDensityNotes = 7
+
+// maniaPattern is the notes generated for one hit object.
+type maniaPattern struct {
+	objects []ManiaObject
+}
+
+func (p *maniaPattern) add(o ManiaObject) {
+	p.objects = append(p.objects, o)
+}
+
+func (p *maniaPattern) hasColumn(column int) bool {
+	for _, o := range p.objects {
+		if o.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// columnsWithObjects returns how many columns have a note.
+func (p *maniaPattern) columnsWithObjects() int {
+	count := 0
+	for i, o := range p.objects {
+		unique := true
+		for _, other := range p.objects[:i] {
+			if other.Column == o.Column {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			count++
+		}
+	}
+	return count
+}
+
+// maniaConverter converts osu!standard beatmaps to osu!mania with the legacy
+// pattern generators of osu! stable.
+type maniaConverter struct {
+	f        *OsuFile
+	timeline *Timeline
+	random   *legacyRandom
+
+	totalColumns int
+	// randomStart skips the special column of 8K conversions
+	randomStart          int
+	conversionDifficulty float64
+
+	noteTimes    []float64
+	density      float64
+	lastTime     float64
+	lastPosition Point
+	lastStair    maniaPatternType
+	lastPattern  *maniaPattern
+}
+
+func newManiaConverter(f *OsuFile, totalColumns int) *maniaConverter {
+	c := &maniaConverter{
+		f:            f,
+		timeline:     NewTimeline(f.TimingPointsFile),
+		totalColumns: totalColumns,
+		density:      math.MaxInt32,
+		lastStair:    maniaStair,
+		lastPattern:  &maniaPattern{},
+	}
+	if totalColumns == 8 {
+		c.randomStart = 1
+	}
+
+	// osu! keeps the difficulty in single precision
+	hp := float32(f.HPDrainRate)
+	cs := float32(f.CircleSize)
+	od := float32(f.OverallDifficulty)
+	ar := float32(f.ApproachRate)
+
+	seed := int(roundToEven32(hp+cs))*20 + int(float64(od)*41.2) + int(roundToEven32(ar))
+	c.random = newLegacyRandom(int32(seed))
+
+	c.conversionDifficulty = c.computeConversionDifficulty(hp, ar)
+	return c
+}
+
+func roundToEven32(v float32) float32 {
+	return float32(math.RoundToEven(float64(v)))
+}
+
+// computeConversionDifficulty rates the beatmap by its drain rate, approach
+// rate and note density. Denser beatmaps get more chords.
+func (c *maniaConverter) computeConversionDifficulty(hp, ar float32) float64 {
+	var firstTime, lastTime float64
+	if len(c.f.HitObjects) > 0 {
+		firstTime = c.f.HitObjects[0].Time
+		lastTime = c.f.HitObjects[len(c.f.HitObjects)-1].Time
+	}
+
+	// drain time in seconds
+	drainTime := int((lastTime - firstTime - c.f.totalBreakTime()) / 1000)
+	if drainTime == 0 {
+		drainTime = 10000
+	}
+
+	arClamped := float32(math.Max(4, math.Min(7, float64(ar))))
+	difficulty := (float64(hp+arClamped)/1.5 + float64(len(c.f.HitObjects))/float64(drainTime)*9) / 38 * 5 / 1.15
+	return math.Min(difficulty, 12)
+}
+
+func (c *maniaConverter) recordNote(time float64, position Point) {
+	c.lastTime = time
+	c.lastPosition = position
+}
+
+func (c *maniaConverter) computeDensity(time float64) {
+	if len(c.noteTimes) == maniaDensityNotes {
+		c.noteTimes = c.noteTimes[1:]
+	}
+	c.noteTimes = append(c.noteTimes, time)
+
+	if len(c.noteTimes) >= 2 {
+		c.density = (c.noteTimes[len(c.noteTimes)-1] - c.noteTimes[0]) / float64(len(c.noteTimes))
+	}
+}
+
+// convert generates the notes of every hit object in order.
+func (c *maniaConverter) convert() []ManiaObject {
+	var objects []ManiaObject
+	for i := range c.f.HitObjects {
+		h := &c.f.HitObjects[i]
+		g := &maniaGenerator{maniaConverter: c, h: h, previous: c.lastPattern}
+
+		switch params := h.ObjectParams.(type) {
+		case *Slider:
+			g.initPath(params)
+			for span := 0; span <= g.spanCount; span++ {
+				time := h.Time + float64(g.segmentDuration*span)
+				c.recordNote(time, h.Position())
+				c.computeDensity(time)
+			}
+
+			for _, pattern := range g.generatePath() {
+				c.lastPattern = pattern
+				objects = append(objects, pattern.objects...)
+			}
+		case Spinner, Hold:
+			endTime := c.timeline.HitObjectEndTime(c.f, h)
+			g.initEndTime(endTime)
+			c.recordNote(endTime, Point{X: 256, Y: 192})
+			c.computeDensity(endTime)
+
+			// spinners don't affect the following patterns
+			objects = append(objects, g.generateEndTime().objects...)
+		default:
+			c.computeDensity(h.Time)
+			g.initHit()
+			c.recordNote(h.Time, h.Position())
+
+			pattern := g.generateHit()
+			c.lastPattern = pattern
+			c.lastStair = g.stairType
+			objects = append(objects, pattern.objects...)
+		}
+	}
+	return objects
+}
+
+// maniaGenerator generates the pattern of a single hit object.
+type maniaGenerator struct {
+	*maniaConverter
+
+	h           *HitObject
+	previous    *maniaPattern
+	convertType maniaPatternType
+
+	// set for circles
+	stairType maniaPatternType
+
+	// set for sliders and spinners, in whole milliseconds like osu! stable
+	startTime       int
+	endTime         int
+	segmentDuration int
+	spanCount       int
+	slider          *Slider
+}
+
+func (g *maniaGenerator) hasSound(sound int) bool {
+	return g.h.HitSound&sound != 0
+}
+
+// column returns the column of an x position. With allowSpecial 8K
+// conversions leave out the special column.
+func (g *maniaGenerator) column(x float64, allowSpecial bool) int {
+	if allowSpecial && g.totalColumns == 8 {
+		return maniaColumn(x, 7) + 1
+	}
+	return maniaColumn(x, g.totalColumns)
+}
+
+func (g *maniaGenerator) randomColumn(lower, upper int) int {
+	return g.random.nextRange(float64(lower), float64(upper))
+}
+
+// randomNoteCount returns between 1 and 6 notes with p2 to p6 the
+// probabilities of at least that many notes.
+func (g *maniaGenerator) randomNoteCount(p ...float64) int {
+	value := g.random.nextDouble()
+	for i := len(p) - 1; i >= 0; i-- {
+		if value >= 1-p[i] {
+			return i + 2
+		}
+	}
+	return 1
+}
+
+// findColumn returns initial if it is valid, otherwise calls next until it
+// returns a valid column. A column is valid when validation accepts it and
+// none of the patterns have a note in it. next defaults to a random column
+// between lower and upper.
+func (g *maniaGenerator) findColumn(initial, lower, upper int, next func(int) int, validation func(int) bool, patterns ...*maniaPattern) int {
+	if next == nil {
+		next = func(int) int {
+			return g.randomColumn(lower, upper)
+		}
+	}
+
+	isValid := func(column int) bool {
+		if validation != nil && !validation(column) {
+			return false
+		}
+		for _, p := range patterns {
+			if p.hasColumn(column) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if isValid(initial) {
+		return initial
+	}
+
+	// avoid looping forever without a free column
+	hasValidColumns := false
+	for i := lower; i < upper; i++ {
+		if hasValidColumns = isValid(i); hasValidColumns {
+			break
+		}
+	}
+	if !hasValidColumns {
+		return initial
+	}
+
+	column := initial
+	for {
+		column = next(column)
+		if isValid(column) {
+			return column
+		}
+	}
+}
+
+// findFreeColumn is findColumn over all usable columns with random steps.
+func (g *maniaGenerator) findFreeColumn(initial int, patterns ...*maniaPattern) int {
+	return g.findColumn(initial, g.randomStart, g.totalColumns, nil, nil, patterns...)
+}
+
+// initHit decides the pattern type of a circle from its distance in time and
+// space to the previous note.
+func (g *maniaGenerator) initHit() {
+	g.stairType = g.lastStair
+
+	beatLength := g.timeline.BeatLengthAt(g.h.Time)
+	positionSeparation := float64(float32(g.h.Position().Distance(g.lastPosition)))
+	timeSeparation := g.h.Time - g.lastTime
+
+	switch {
+	case timeSeparation <= 80:
+		g.convertType |= maniaForceNotStack | maniaKeepSingle
+	case timeSeparation <= 95:
+		g.convertType |= maniaForceNotStack | maniaKeepSingle | g.lastStair
+	case timeSeparation <= 105:
+		g.convertType |= maniaForceNotStack | maniaLowProbability
+	case timeSeparation <= 125:
+		g.convertType |= maniaForceNotStack
+	case timeSeparation <= 135 && positionSeparation < 20:
+		g.convertType |= maniaCycle | maniaKeepSingle
+	case timeSeparation <= 150 && positionSeparation < 20:
+		g.convertType |= maniaForceStack | maniaLowProbability
+	case positionSeparation < 20 && g.density >= beatLength/2.5:
+		g.convertType |= maniaReverse | maniaLowProbability
+	case g.density < beatLength/2.5 || g.timeline.KiaiAt(g.h.Time):
+		// default
+	default:
+		g.convertType |= maniaLowProbability
+	}
+
+	if g.convertType&maniaKeepSingle == 0 {
+		if g.hasSound(HitSoundFinish) && g.totalColumns != 8 {
+			g.convertType |= maniaMirror
+		} else if g.hasSound(HitSoundClap) {
+			g.convertType |= maniaGathered
+		}
+	}
+}
+
+func (g *maniaGenerator) addHitNote(pattern *maniaPattern, column int) {
+	pattern.add(ManiaObject{Column: column, StartTime: g.h.Time, EndTime: g.h.Time})
+}
+
+func (g *maniaGenerator) generateHit() *maniaPattern {
+	pattern := g.generateHitPattern()
+
+	for _, o := range pattern.objects {
+		if g.convertType&maniaStair != 0 && o.Column == g.totalColumns-1 {
+			g.stairType = maniaReverseStair
+		}
+		if g.convertType&maniaReverseStair != 0 && o.Column == g.randomStart {
+			g.stairType = maniaStair
+		}
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateHitPattern() *maniaPattern {
+	pattern := &maniaPattern{}
+	if g.totalColumns == 1 {
+		g.addHitNote(pattern, 0)
+		return pattern
+	}
+
+	lastColumn := 0
+	if len(g.previous.objects) > 0 {
+		lastColumn = g.previous.objects[0].Column
+	}
+
+	if g.convertType&maniaReverse != 0 && len(g.previous.objects) > 0 {
+		// copy the previous pattern in reverse column order
+		for i := g.randomStart; i < g.totalColumns; i++ {
+			if g.previous.hasColumn(i) {
+				g.addHitNote(pattern, g.randomStart+g.totalColumns-i-1)
+			}
+		}
+		return pattern
+	}
+
+	if g.convertType&maniaCycle != 0 && len(g.previous.objects) == 1 &&
+		// don't overload the special key of 8K
+		(g.totalColumns != 8 || lastColumn != 0) &&
+		// and don't cycle on the centre column
+		(g.totalColumns%2 == 0 || lastColumn != g.totalColumns/2) {
+		g.addHitNote(pattern, g.randomStart+g.totalColumns-lastColumn-1)
+		return pattern
+	}
+
+	if g.convertType&maniaForceStack != 0 && len(g.previous.objects) > 0 {
+		for i := g.randomStart; i < g.totalColumns; i++ {
+			if g.previous.hasColumn(i) {
+				g.addHitNote(pattern, i)
+			}
+		}
+		return pattern
+	}
+
+	if len(g.previous.objects) == 1 {
+		if g.convertType&maniaStair != 0 {
+			column := lastColumn + 1
+			if column == g.totalColumns {
+				column = g.randomStart
+			}
+			g.addHitNote(pattern, column)
+			return pattern
+		}
+
+		if g.convertType&maniaReverseStair != 0 {
+			column := lastColumn - 1
+			if column == g.randomStart-1 {
+				column = g.totalColumns - 1
+			}
+			g.addHitNote(pattern, column)
+			return pattern
+		}
+	}
+
+	if g.convertType&maniaKeepSingle != 0 {
+		return g.generateRandomNotes(1)
+	}
+
+	lowProbability := g.convertType&maniaLowProbability != 0
+
+	if g.convertType&maniaMirror != 0 {
+		switch {
+		case g.conversionDifficulty > 6.5:
+			return g.generateRandomPatternWithMirrored(0.12, 0.38, 0.12)
+		case g.conversionDifficulty > 4:
+			return g.generateRandomPatternWithMirrored(0.12, 0.17, 0)
+		default:
+			return g.generateRandomPatternWithMirrored(0.12, 0, 0)
+		}
+	}
+
+	switch {
+	case g.conversionDifficulty > 6.5:
+		if lowProbability {
+			return g.generateRandomPattern(0.78, 0.42, 0, 0)
+		}
+		return g.generateRandomPattern(1, 0.62, 0, 0)
+	case g.conversionDifficulty > 4:
+		if lowProbability {
+			return g.generateRandomPattern(0.35, 0.08, 0, 0)
+		}
+		return g.generateRandomPattern(0.52, 0.15, 0, 0)
+	case g.conversionDifficulty > 2:
+		if lowProbability {
+			return g.generateRandomPattern(0.18, 0, 0, 0)
+		}
+		return g.generateRandomPattern(0.45, 0, 0, 0)
+	default:
+		return g.generateRandomPattern(0, 0, 0, 0)
+	}
+}
+
+func (g *maniaGenerator) generateRandomNotes(noteCount int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	allowStacking := g.convertType&maniaForceNotStack == 0
+	if !allowStacking {
+		noteCount = min(noteCount, g.totalColumns-g.randomStart-g.previous.columnsWithObjects())
+	}
+
+	next := func(last int) int {
+		if g.convertType&maniaGathered != 0 {
+			last++
+			if last == g.totalColumns {
+				last = g.randomStart
+			}
+			return last
+		}
+		return g.randomColumn(g.randomStart, g.totalColumns)
+	}
+
+	column := g.column(g.h.X, true)
+	for i := 0; i < noteCount; i++ {
+		if allowStacking {
+			column = g.findColumn(column, g.randomStart, g.totalColumns, next, nil, pattern)
+		} else {
+			column = g.findColumn(column, g.randomStart, g.totalColumns, next, nil, pattern, g.previous)
+		}
+		g.addHitNote(pattern, column)
+	}
+	return pattern
+}
+
+// hasSpecialColumn reports whether the special column of 8K is used as well.
+func (g *maniaGenerator) hasSpecialColumn() bool {
+	return g.hasSound(HitSoundClap) && g.hasSound(HitSoundFinish)
+}
+
+func (g *maniaGenerator) generateRandomPattern(p2, p3, p4, p5 float64) *maniaPattern {
+	pattern := g.generateRandomNotes(g.hitNoteCount(p2, p3, p4, p5))
+	if g.randomStart > 0 && g.hasSpecialColumn() {
+		g.addHitNote(pattern, 0)
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateRandomPatternWithMirrored(centreProbability, p2, p3 float64) *maniaPattern {
+	if g.convertType&maniaForceNotStack != 0 {
+		return g.generateRandomPattern(0.5+p2/2, p2, (p2+p3)/2, p3)
+	}
+
+	pattern := &maniaPattern{}
+
+	noteCount, addToCentre := g.mirroredNoteCount(centreProbability, p2, p3)
+
+	columnLimit := g.totalColumns / 2
+	column := g.randomColumn(g.randomStart, columnLimit)
+	for i := 0; i < noteCount; i++ {
+		column = g.findColumn(column, g.randomStart, columnLimit, nil, nil, pattern)
+		g.addHitNote(pattern, column)
+		g.addHitNote(pattern, g.randomStart+g.totalColumns-column-1)
+	}
+
+	if addToCentre {
+		g.addHitNote(pattern, g.totalColumns/2)
+	}
+	if g.randomStart > 0 && g.hasSpecialColumn() {
+		g.addHitNote(pattern, 0)
+	}
+	return pattern
+}
+
+// hitNoteCount returns how many notes a circle becomes, with fewer chords
+// for low key counts.
+func (g *maniaGenerator) hitNoteCount(p2, p3, p4, p5 float64) int {
+	switch g.totalColumns {
+	case 2:
+		p2, p3, p4, p5 = 0, 0, 0, 0
+	case 3:
+		p2, p3, p4, p5 = math.Min(p2, 0.1), 0, 0, 0
+	case 4:
+		p2, p3, p4, p5 = math.Min(p2, 0.23), math.Min(p3, 0.04), 0, 0
+	case 5:
+		p3, p4, p5 = math.Min(p3, 0.15), math.Min(p4, 0.03), 0
+	}
+
+	if g.hasSound(HitSoundClap) {
+		p2 = 1
+	}
+	return g.randomNoteCount(p2, p3, p4, p5)
+}
+
+// mirroredNoteCount returns how many notes are mirrored and whether one is
+// added to the centre column as well.
+func (g *maniaGenerator) mirroredNoteCount(centreProbability, p2, p3 float64) (int, bool) {
+	switch g.totalColumns {
+	case 2:
+		centreProbability, p2, p3 = 0, 0, 0
+	case 3:
+		centreProbability, p2, p3 = math.Min(centreProbability, 0.03), 0, 0
+	case 4:
+		// osu! stable doubles the inverse probability
+		centreProbability, p2, p3 = 0, 1-math.Max((1-p2)*2, 0.8), 0
+	case 5:
+		centreProbability, p3 = math.Min(centreProbability, 0.03), 0
+	case 6:
+		centreProbability = 0
+		p2 = 1 - math.Max((1-p2)*2, 0.5)
+		p3 = 1 - math.Max((1-p3)*2, 0.85)
+	}
+
+	// probabilities above 1 always gave a single note
+	p2 = clamp(p2, 0, 1)
+	p3 = clamp(p3, 0, 1)
+
+	centreValue := g.random.nextDouble()
+	noteCount := g.randomNoteCount(p2, p3)
+
+	addToCentre := g.totalColumns%2 != 0 && noteCount != 3 && centreValue > 1-centreProbability
+	return noteCount, addToCentre
+}
+
+func (g *maniaGenerator) initEndTime(endTime float64) {
+	g.endTime = int(endTime)
+	if g.previous.columnsWithObjects() != g.totalColumns {
+		g.convertType = maniaForceNotStack
+	}
+}
+
+// generateEndTime places a spinner in a random column, as a hold note if it
+// is long enough.
+func (g *maniaGenerator) generateEndTime() *maniaPattern {
+	pattern := &maniaPattern{}
+	duration := float64(g.endTime) - g.h.Time
+
+	column := 0
+	switch {
+	case g.totalColumns == 8 && g.hasSound(HitSoundFinish) && duration < 1000:
+		column = 0
+	case g.totalColumns == 8:
+		column = g.randomEndTimeColumn(g.randomStart)
+	default:
+		column = g.randomEndTimeColumn(0)
+	}
+
+	o := ManiaObject{Column: column, StartTime: g.h.Time, EndTime: g.h.Time}
+	if duration >= 100 {
+		o.EndTime = float64(g.endTime)
+	}
+	pattern.add(o)
+	return pattern
+}
+
+func (g *maniaGenerator) randomEndTimeColumn(lower int) int {
+	initial := g.randomColumn(lower, g.totalColumns)
+	if g.convertType&maniaForceNotStack != 0 {
+		return g.findColumn(initial, lower, g.totalColumns, nil, nil, g.previous)
+	}
+	return g.findColumn(initial, lower, g.totalColumns, nil, nil)
+}
+
+// initPath resolves the timing of a slider the way osu! stable does, in
+// whole milliseconds.
+func (g *maniaGenerator) initPath(slider *Slider) {
+	g.slider = slider
+	if !g.timeline.KiaiAt(g.h.Time) {
+		g.convertType = maniaLowProbability
+	}
+
+	beatLength := g.timeline.BeatLengthAt(g.h.Time) * g.timeline.bpmMultiplierAt(g.h.Time)
+	distance := slider.Path(g.h.Position()).Distance()
+
+	g.spanCount = max(slider.Slides, 1)
+	g.startTime = int(math.RoundToEven(g.h.Time))
+	g.endTime = int(math.Floor(float64(g.startTime) + distance*beatLength*float64(g.spanCount)*0.01/g.f.SliderMultiplier))
+	g.segmentDuration = (g.endTime - g.startTime) / g.spanCount
+}
+
+// generatePath generates the notes of a slider. Notes ending before the
+// slider are split into their own pattern, so only the notes ending with it
+// affect the following patterns.
+func (g *maniaGenerator) generatePath() []*maniaPattern {
+	original := g.generatePathPattern()
+	if len(original.objects) == 1 {
+		return []*maniaPattern{original}
+	}
+
+	intermediate := &maniaPattern{}
+	end := &maniaPattern{}
+	for _, o := range original.objects {
+		if g.endTime != int(math.RoundToEven(o.EndTime)) {
+			intermediate.add(o)
+		} else {
+			end.add(o)
+		}
+	}
+	return []*maniaPattern{intermediate, end}
+}
+
+func (g *maniaGenerator) generatePathPattern() *maniaPattern {
+	if g.totalColumns == 1 {
+		pattern := &maniaPattern{}
+		g.addPathNote(pattern, 0, g.startTime, g.endTime)
+		return pattern
+	}
+
+	if g.spanCount > 1 {
+		switch {
+		case g.segmentDuration <= 90:
+			return g.generateRandomHoldNotes(g.startTime, 1)
+		case g.segmentDuration <= 120:
+			g.convertType |= maniaForceNotStack
+			return g.generateRandomPathNotes(g.startTime, g.spanCount+1)
+		case g.segmentDuration <= 160:
+			return g.generateStair(g.startTime)
+		case g.segmentDuration <= 200 && g.conversionDifficulty > 3:
+			return g.generateRandomMultipleNotes(g.startTime)
+		case g.endTime-g.startTime >= 4000:
+			return g.generateNRandomNotes(g.startTime, 0.23, 0, 0)
+		case g.segmentDuration > 400 && g.spanCount < g.totalColumns-1-g.randomStart:
+			return g.generateTiledHoldNotes(g.startTime)
+		default:
+			return g.generateHoldAndNormalNotes(g.startTime)
+		}
+	}
+
+	if g.segmentDuration <= 110 {
+		if g.previous.columnsWithObjects() < g.totalColumns {
+			g.convertType |= maniaForceNotStack
+		} else {
+			g.convertType &^= maniaForceNotStack
+		}
+		noteCount := 2
+		if g.segmentDuration < 80 {
+			noteCount = 1
+		}
+		return g.generateRandomPathNotes(g.startTime, noteCount)
+	}
+
+	lowProbability := g.convertType&maniaLowProbability != 0
+
+	switch {
+	case g.conversionDifficulty > 6.5:
+		if lowProbability {
+			return g.generateNRandomNotes(g.startTime, 0.78, 0.3, 0)
+		}
+		return g.generateNRandomNotes(g.startTime, 0.85, 0.36, 0.03)
+	case g.conversionDifficulty > 4:
+		if lowProbability {
+			return g.generateNRandomNotes(g.startTime, 0.43, 0.08, 0)
+		}
+		return g.generateNRandomNotes(g.startTime, 0.56, 0.18, 0)
+	case g.conversionDifficulty > 2.5:
+		if lowProbability {
+			return g.generateNRandomNotes(g.startTime, 0.3, 0, 0)
+		}
+		return g.generateNRandomNotes(g.startTime, 0.37, 0.08, 0)
+	default:
+		if lowProbability {
+			return g.generateNRandomNotes(g.startTime, 0.17, 0, 0)
+		}
+		return g.generateNRandomNotes(g.startTime, 0.27, 0, 0)
+	}
+}
+
+// soundAt returns the hitsound of the slider node at time.
+func (g *maniaGenerator) soundAt(time int) int {
+	node := 0
+	if g.segmentDuration != 0 {
+		node = (time - g.startTime) / g.segmentDuration
+	}
+	return nodeSound(g.h, g.slider, node)
+}
+
+func (g *maniaGenerator) addPathNote(pattern *maniaPattern, column, startTime, endTime int) {
+	pattern.add(ManiaObject{Column: column, StartTime: float64(startTime), EndTime: float64(endTime)})
+}
+
+// freeColumn moves column away from the previous pattern when notes must not
+// stack and there is room to.
+func (g *maniaGenerator) freeColumn(column int) int {
+	if g.convertType&maniaForceNotStack != 0 && g.previous.columnsWithObjects() < g.totalColumns {
+		return g.findFreeColumn(column, g.previous)
+	}
+	return column
+}
+
+func (g *maniaGenerator) generateRandomHoldNotes(startTime, noteCount int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	usableColumns := g.totalColumns - g.randomStart - g.previous.columnsWithObjects()
+	column := g.randomColumn(g.randomStart, g.totalColumns)
+	for i := 0; i < min(usableColumns, noteCount); i++ {
+		column = g.findFreeColumn(column, pattern, g.previous)
+		g.addPathNote(pattern, column, startTime, g.endTime)
+	}
+
+	// can't be merged into the loop above because of the random numbers
+	for i := 0; i < noteCount-usableColumns; i++ {
+		column = g.findFreeColumn(column, pattern)
+		g.addPathNote(pattern, column, startTime, g.endTime)
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateRandomPathNotes(startTime, noteCount int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	column := g.freeColumn(g.column(g.h.X, true))
+	lastColumn := column
+	for i := 0; i < noteCount; i++ {
+		g.addPathNote(pattern, column, startTime, startTime)
+		column = g.findColumn(column, g.randomStart, g.totalColumns, nil, func(c int) bool {
+			return c != lastColumn
+		})
+		lastColumn = column
+		startTime += g.segmentDuration
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateStair(startTime int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	column := g.column(g.h.X, true)
+	increasing := g.random.nextDouble() > 0.5
+	for i := 0; i <= g.spanCount; i++ {
+		g.addPathNote(pattern, column, startTime, startTime)
+		startTime += g.segmentDuration
+
+		// turn around at the edges of the stage
+		if increasing {
+			if column >= g.totalColumns-1 {
+				increasing = false
+				column--
+			} else {
+				column++
+			}
+		} else {
+			if column <= g.randomStart {
+				increasing = true
+				column++
+			} else {
+				column--
+			}
+		}
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateRandomMultipleNotes(startTime int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	legacy := 0
+	if g.totalColumns >= 4 && g.totalColumns <= 8 {
+		legacy = 1
+	}
+	interval := g.randomColumn(1, g.totalColumns-legacy)
+
+	column := g.column(g.h.X, true)
+	for i := 0; i <= g.spanCount; i++ {
+		g.addPathNote(pattern, column, startTime, startTime)
+
+		column += interval
+		if column >= g.totalColumns-g.randomStart {
+			column = column - g.totalColumns - g.randomStart + legacy
+		}
+		column += g.randomStart
+
+		// no consecutive doubles in 2K
+		if g.totalColumns > 2 {
+			g.addPathNote(pattern, column, startTime, startTime)
+		}
+
+		column = g.randomColumn(g.randomStart, g.totalColumns)
+		startTime += g.segmentDuration
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateNRandomNotes(startTime int, p2, p3, p4 float64) *maniaPattern {
+	switch g.totalColumns {
+	case 2:
+		p2, p3, p4 = 0, 0, 0
+	case 3:
+		p2, p3, p4 = math.Min(p2, 0.1), 0, 0
+	case 4:
+		p2, p3, p4 = math.Min(p2, 0.3), math.Min(p3, 0.04), 0
+	case 5:
+		p2, p3, p4 = math.Min(p2, 0.34), math.Min(p3, 0.1), math.Min(p4, 0.03)
+	}
+
+	const doubleSounds = HitSoundClap | HitSoundFinish
+	if g.convertType&maniaLowProbability == 0 &&
+		(g.hasSound(doubleSounds) || g.soundAt(g.startTime)&doubleSounds != 0) {
+		p2 = 1
+	}
+
+	return g.generateRandomHoldNotes(startTime, g.randomNoteCount(p2, p3, p4))
+}
+
+func (g *maniaGenerator) generateTiledHoldNotes(startTime int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	columnRepeat := min(g.spanCount, g.totalColumns)
+	// not always the end time of the slider because of rounding
+	endTime := startTime + g.segmentDuration*g.spanCount
+
+	column := g.freeColumn(g.column(g.h.X, true))
+	for i := 0; i < columnRepeat; i++ {
+		column = g.findFreeColumn(column, pattern)
+		g.addPathNote(pattern, column, startTime, endTime)
+		startTime += g.segmentDuration
+	}
+	return pattern
+}
+
+func (g *maniaGenerator) generateHoldAndNormalNotes(startTime int) *maniaPattern {
+	pattern := &maniaPattern{}
+
+	holdColumn := g.freeColumn(g.column(g.h.X, true))
+	g.addPathNote(pattern, holdColumn, startTime, g.endTime)
+
+	column := g.randomColumn(g.randomStart, g.totalColumns)
+
+	noteCount := 0
+	switch {
+	case g.conversionDifficulty > 6.5:
+		noteCount = g.randomNoteCount(0.63, 0)
+	case g.conversionDifficulty > 4:
+		if g.totalColumns < 6 {
+			noteCount = g.randomNoteCount(0.12, 0)
+		} else {
+			noteCount = g.randomNoteCount(0.45, 0)
+		}
+	case g.conversionDifficulty > 2.5:
+		if g.totalColumns < 6 {
+			noteCount = g.randomNoteCount(0, 0)
+		} else {
+			noteCount = g.randomNoteCount(0.24, 0)
+		}
+	}
+	noteCount = min(g.totalColumns-1, noteCount)
+
+	ignoreHead := g.soundAt(startTime)&(HitSoundWhistle|HitSoundFinish|HitSoundClap) == 0
+
+	for i := 0; i <= g.spanCount; i++ {
+		row := &maniaPattern{}
+		if !(ignoreHead && startTime == g.startTime) {
+			for j := 0; j < noteCount; j++ {
+				column = g.findColumn(column, g.randomStart, g.totalColumns, nil, func(c int) bool {
+					return c != holdColumn
+				}, row)
+				g.addPathNote(row, column, startTime, startTime)
+			}
+		}
+		pattern.objects = append(pattern.objects, row.objects...)
+		startTime += g.segmentDuration
+	}
+	return pattern
+}
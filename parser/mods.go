@@ -0,0 +1,284 @@
+package osuParser
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Mods is the bitfield of mods used by osu!.
+type Mods int32
+
+const (
+	ModNoFail         Mods = 1 << 0
+	ModEasy           Mods = 1 << 1
+	ModTouchDevice    Mods = 1 << 2
+	ModHidden         Mods = 1 << 3
+	ModHardRock       Mods = 1 << 4
+	ModSuddenDeath    Mods = 1 << 5
+	ModDoubleTime     Mods = 1 << 6
+	ModRelax          Mods = 1 << 7
+	ModHalfTime       Mods = 1 << 8
+	ModNightcore      Mods = 1 << 9 // always set together with ModDoubleTime
+	ModFlashlight     Mods = 1 << 10
+	ModAutoplay       Mods = 1 << 11
+	ModSpunOut        Mods = 1 << 12
+	ModAutopilot      Mods = 1 << 13
+	ModPerfect        Mods = 1 << 14 // always set together with ModSuddenDeath
+	ModKey4           Mods = 1 << 15
+	ModKey5           Mods = 1 << 16
+	ModKey6           Mods = 1 << 17
+	ModKey7           Mods = 1 << 18
+	ModKey8           Mods = 1 << 19
+	ModFadeIn         Mods = 1 << 20
+	ModRandom         Mods = 1 << 21
+	ModCinema         Mods = 1 << 22
+	ModTargetPractice Mods = 1 << 23
+	ModKey9           Mods = 1 << 24
+	ModKeyCoop        Mods = 1 << 25
+	ModKey1           Mods = 1 << 26
+	ModKey3           Mods = 1 << 27
+	ModKey2           Mods = 1 << 28
+	ModScoreV2        Mods = 1 << 29
+	ModMirror         Mods = 1 << 30
+
+	ModNone Mods = 0
+
+	ModKeyMods = ModKey1 | ModKey2 | ModKey3 | ModKey4 | ModKey5 | ModKey6 | ModKey7 | ModKey8 | ModKey9
+
+	// ModDifficultyMods are the mods osu! keys its star rating cache by.
+	ModDifficultyMods = ModEasy | ModHardRock | ModDoubleTime | ModHalfTime
+
+	modAll = ModMirror | (ModMirror - 1)
+)
+
+// modAcronyms lists the mods in the order String writes them.
+var modAcronyms = []struct {
+	mod     Mods
+	acronym string
+}{
+	{ModNoFail, "NF"},
+	{ModEasy, "EZ"},
+	{ModTouchDevice, "TD"},
+	{ModHidden, "HD"},
+	{ModDoubleTime, "DT"},
+	{ModNightcore, "NC"},
+	{ModHalfTime, "HT"},
+	{ModHardRock, "HR"},
+	{ModSuddenDeath, "SD"},
+	{ModPerfect, "PF"},
+	{ModFlashlight, "FL"},
+	{ModRelax, "RX"},
+	{ModAutopilot, "AP"},
+	{ModSpunOut, "SO"},
+	{ModAutoplay, "AT"},
+	{ModCinema, "CN"},
+	{ModTargetPractice, "TP"},
+	{ModFadeIn, "FI"},
+	{ModRandom, "RD"},
+	{ModMirror, "MR"},
+	{ModKey1, "1K"},
+	{ModKey2, "2K"},
+	{ModKey3, "3K"},
+	{ModKey4, "4K"},
+	{ModKey5, "5K"},
+	{ModKey6, "6K"},
+	{ModKey7, "7K"},
+	{ModKey8, "8K"},
+	{ModKey9, "9K"},
+	{ModKeyCoop, "CP"},
+	{ModScoreV2, "V2"},
+}
+
+// modImplied pairs mods with the mod osu! always sets alongside them.
+var modImplied = [][2]Mods{
+	{ModNightcore, ModDoubleTime},
+	{ModPerfect, ModSuddenDeath},
+}
+
+func impliedBy(mod Mods) Mods {
+	for _, pair := range modImplied {
+		if pair[0] == mod {
+			return pair[1]
+		}
+	}
+	return ModNone
+}
+
+// modIncompatible lists the pairs of mods that cannot be played together.
+var modIncompatible = [][2]Mods{
+	{ModEasy, ModHardRock},
+	{ModDoubleTime, ModHalfTime},
+	{ModNightcore, ModHalfTime},
+	{ModNoFail, ModSuddenDeath},
+	{ModNoFail, ModPerfect},
+	{ModNoFail, ModRelax},
+	{ModNoFail, ModAutopilot},
+	{ModSuddenDeath, ModRelax},
+	{ModSuddenDeath, ModAutopilot},
+	{ModRelax, ModAutopilot},
+	{ModRelax, ModAutoplay},
+	{ModAutopilot, ModAutoplay},
+	{ModAutopilot, ModSpunOut},
+	{ModCinema, ModAutoplay},
+	{ModHidden, ModFadeIn},
+	{ModFlashlight, ModFadeIn},
+}
+
+// Has reports whether all mods of other are set.
+func (m Mods) Has(other Mods) bool {
+	return m&other == other
+}
+
+// String returns the acronyms of the mods, e.g. "HDDTHR", or "NM" for no mods.
+// Mods implied by another mod (DT for NC, SD for PF) are left out.
+func (m Mods) String() string {
+	if m == ModNone {
+		return "NM"
+	}
+
+	var sb strings.Builder
+	for _, mod := range modAcronyms {
+		if !m.Has(mod.mod) {
+			continue
+		}
+		implied := false
+		for _, pair := range modImplied {
+			if mod.mod == pair[1] && m.Has(pair[0]) {
+				implied = true
+			}
+		}
+		if !implied {
+			sb.WriteString(mod.acronym)
+		}
+	}
+	if unknown := m &^ modAll; unknown != 0 {
+		fmt.Fprintf(&sb, "+0x%x", uint32(unknown))
+	}
+	return sb.String()
+}
+
+// ParseMods parses mod acronyms such as "HDDTHR", "hd,dt" or "+HD HR".
+// Acronyms are case-insensitive and may be separated by spaces, commas or
+// plus signs. "NM" and the empty string are no mods. NC and PF also set DT
+// and SD like osu! does.
+func ParseMods(s string) (Mods, error) {
+	s = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ' ' || r == ',' || r == '+' || r == '|' {
+			return -1
+		}
+		return r
+	}, s))
+
+	if len(s)%2 != 0 {
+		return ModNone, fmt.Errorf("invalid mods %q", s)
+	}
+
+	mods := ModNone
+	for i := 0; i < len(s); i += 2 {
+		acronym := s[i : i+2]
+		if acronym == "NM" {
+			continue
+		}
+
+		found := false
+		for _, mod := range modAcronyms {
+			if mod.acronym == acronym {
+				mods |= mod.mod | impliedBy(mod.mod)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ModNone, fmt.Errorf("unknown mod %q", acronym)
+		}
+	}
+	return mods, nil
+}
+
+// Validate returns an error if the mods contain unknown bits or a
+// combination osu! does not allow.
+func (m Mods) Validate() error {
+	if m&^modAll != 0 {
+		return fmt.Errorf("unknown mod bits 0x%x", uint32(m&^modAll))
+	}
+	for _, pair := range modImplied {
+		if m.Has(pair[0]) && !m.Has(pair[1]) {
+			return fmt.Errorf("%s requires %s", pair[0], pair[1])
+		}
+	}
+	for _, pair := range modIncompatible {
+		if m.Has(pair[0]) && m.Has(pair[1]) {
+			return fmt.Errorf("%s and %s are incompatible", pair[0], pair[1])
+		}
+	}
+	if bits.OnesCount32(uint32(m&ModKeyMods)) > 1 {
+		return errors.New("only one key mod can be selected")
+	}
+	return nil
+}
+
+// Valid reports whether the mods can be played together.
+func (m Mods) Valid() bool {
+	return m.Validate() == nil
+}
+
+// DifficultyMods returns the mods that change the star rating, masked the
+// same way osu! keys its star rating cache.
+func (m Mods) DifficultyMods() Mods {
+	if m.Has(ModNightcore) {
+		m |= ModDoubleTime
+	}
+	return m & ModDifficultyMods
+}
+
+// ClockRate returns the playback rate the mods apply.
+func (m Mods) ClockRate() float64 {
+	switch {
+	case m&(ModDoubleTime|ModNightcore) != 0:
+		return 1.5
+	case m.Has(ModHalfTime):
+		return 0.75
+	default:
+		return 1
+	}
+}
+
+// KeyCount returns the mania key count forced by a key mod, or 0 if none is
+// set.
+func (m Mods) KeyCount() int {
+	keys := []Mods{ModKey1, ModKey2, ModKey3, ModKey4, ModKey5, ModKey6, ModKey7, ModKey8, ModKey9}
+	for i, key := range keys {
+		if m.Has(key) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ScoreMultiplier returns the ScoreV1 score multiplier of the mods in
+// osu!standard.
+func (m Mods) ScoreMultiplier() float64 {
+	multiplier := 1.0
+	for _, mod := range []struct {
+		mod        Mods
+		multiplier float64
+	}{
+		{ModNoFail, 0.5},
+		{ModEasy, 0.5},
+		{ModHalfTime, 0.3},
+		{ModHidden, 1.06},
+		{ModHardRock, 1.06},
+		{ModDoubleTime, 1.12},
+		{ModFlashlight, 1.12},
+		{ModSpunOut, 0.9},
+		{ModRelax, 0},
+		{ModAutopilot, 0},
+	} {
+		if m.Has(mod.mod) {
+			multiplier *= mod.multiplier
+		}
+	}
+	return multiplier
+}
@@ -0,0 +1,88 @@
+package osuParser
+
+import "testing"
+
+func TestModsString(t *testing.T) {
+	tests := []struct {
+		mods Mods
+		s    string
+	}{
+		{ModNone, "NM"},
+		{88, "HDDTHR"},
+		{24, "HDHR"},
+		{576, "NC"},
+		{16416, "PF"},
+		{1048, "HDHRFL"},
+		{2 | 256, "EZHT"},
+		{1 << 15, "4K"},
+		{ModKey7 | ModMirror | ModFadeIn, "FIMR7K"},
+		{ModScoreV2 | ModTouchDevice, "TDV2"},
+	}
+
+	for _, test := range tests {
+		if s := test.mods.String(); s != test.s {
+			t.Errorf("mods %d written as %q, want %q", test.mods, s, test.s)
+		}
+		mods, err := ParseMods(test.s)
+		if err != nil || mods != test.mods {
+			t.Errorf("%q parsed as %d (%v), want %d", test.s, mods, err, test.mods)
+		}
+	}
+
+	for s, want := range map[string]Mods{"": ModNone, "hd,dt": 72, "+HD HR": 24, "DTHD": 72, "nc": 576, "NMHD": ModHidden} {
+		if mods, err := ParseMods(s); err != nil || mods != want {
+			t.Errorf("%q parsed as %d (%v), want %d", s, mods, err, want)
+		}
+	}
+	for _, s := range []string{"HDD", "XY", "HD?R"} {
+		if _, err := ParseMods(s); err == nil {
+			t.Errorf("%q parsed without an error", s)
+		}
+	}
+}
+
+func TestModsValidate(t *testing.T) {
+	tests := []struct {
+		mods  Mods
+		valid bool
+	}{
+		{ModNone, true},
+		{88, true},
+		{576, true},
+		{ModNightcore, false},
+		{ModPerfect, false},
+		{ModEasy | ModHardRock, false},
+		{ModDoubleTime | ModHalfTime, false},
+		{ModNoFail | ModSuddenDeath, false},
+		{ModRelax | ModAutopilot, false},
+		{ModHidden | ModFadeIn, false},
+		{ModKey4 | ModKey5, false},
+		{ModKey4 | ModKeyCoop, true},
+		{-1 << 31, false},
+	}
+
+	for _, test := range tests {
+		if valid := test.mods.Valid(); valid != test.valid {
+			t.Errorf("mods %v valid %v, want %v (%v)", test.mods, valid, test.valid, test.mods.Validate())
+		}
+	}
+}
+
+func TestModsDifficultyMods(t *testing.T) {
+	tests := []struct {
+		mods Mods
+		want Mods
+	}{
+		{88, ModDoubleTime | ModHardRock},
+		{ModNightcore, ModDoubleTime},
+		{ModFlashlight | ModEasy, ModEasy},
+		{ModHalfTime | ModHidden | ModKey4, ModHalfTime},
+		{ModNoFail | ModSpunOut, ModNone},
+	}
+
+	for _, test := range tests {
+		if got := test.mods.DifficultyMods(); got != test.want {
+			t.Errorf("difficulty mods of %v are %v, want %v", test.mods, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package osuParser
+
+import "math"
+
+// StandardPerformance is the pp of an osu!standard score split into its
+// components.
+type StandardPerformance struct {
+	Total              float64
+	Aim                float64
+	Speed              float64
+	Accuracy           float64
+	Flashlight         float64
+	EffectiveMissCount float64
+}
+
+// standardScore holds the values of a score the pp calculation uses.
+type standardScore struct {
+	mods       Mods
+	maxCombo   float64
+	countGreat float64
+	countOk    float64
+	countMeh   float64
+	countMiss  float64
+	totalHits  float64
+	accuracy   float64
+}
+
+func newStandardScore(score *Score) standardScore {
+	s := standardScore{
+		mods:       score.Mods,
+		maxCombo:   float64(score.MaxCombo),
+		countGreat: float64(score.Count300s),
+		countOk:    float64(score.Count100s),
+		countMeh:   float64(score.Count50),
+		countMiss:  float64(score.CountMiss),
+	}
+	s.totalHits = s.countGreat + s.countOk + s.countMeh + s.countMiss
+	if s.totalHits > 0 {
+		s.accuracy = (300*s.countGreat + 100*s.countOk + 50*s.countMeh) / (300 * s.totalHits)
+	}
+	return s
+}
+
+// Performance calculates the pp of an osu!standard score on the beatmap the
+// difficulty was calculated for. The mods of the score should match the mods
+// of the difficulty.
+func (d *StandardDifficulty) Performance(score *Score) *StandardPerformance {
+	s := newStandardScore(score)
+	p := &StandardPerformance{}
+
+	p.EffectiveMissCount = d.effectiveMissCount(s)
+
+	multiplier := standardPerformanceMultiplier
+
+	if s.mods.Has(ModNoFail) {
+		multiplier *= math.Max(0.9, 1-0.02*p.EffectiveMissCount)
+	}
+
+	if s.mods.Has(ModSpunOut) && s.totalHits > 0 {
+		multiplier *= 1 - math.Pow(float64(d.SpinnerCount)/s.totalHits, 0.85)
+	}
+
+	if s.mods.Has(ModRelax) {
+		// OD 13.33 is used as maximum since it's the value at which the
+		// great hit window becomes 0
+		okMultiplier := 1.0
+		mehMultiplier := 1.0
+		if d.OverallDifficulty > 0 {
+			okMultiplier = math.Max(0, 1-math.Pow(d.OverallDifficulty/13.33, 1.8))
+			mehMultiplier = math.Max(0, 1-math.Pow(d.OverallDifficulty/13.33, 5))
+		}
+
+		// clamped as adding oks and mehs to the approximated combo breaks can
+		// exceed the total hits
+		p.EffectiveMissCount = math.Min(p.EffectiveMissCount+s.countOk*okMultiplier+s.countMeh*mehMultiplier, s.totalHits)
+	}
+
+	p.Aim = d.aimValue(s, p.EffectiveMissCount)
+	p.Speed = d.speedValue(s, p.EffectiveMissCount)
+	p.Accuracy = d.accuracyValue(s)
+	p.Flashlight = d.flashlightValue(s, p.EffectiveMissCount)
+
+	p.Total = math.Pow(
+		math.Pow(p.Aim, 1.1)+
+			math.Pow(p.Speed, 1.1)+
+			math.Pow(p.Accuracy, 1.1)+
+			math.Pow(p.Flashlight, 1.1),
+		1/1.1,
+	) * multiplier
+
+	return p
+}
+
+// effectiveMissCount guesses the number of misses and slider breaks from the
+// combo of the score.
+func (d *StandardDifficulty) effectiveMissCount(s standardScore) float64 {
+	comboBasedMissCount := 0.0
+	if d.SliderCount > 0 {
+		fullComboThreshold := float64(d.MaxCombo) - 0.1*float64(d.SliderCount)
+		if s.maxCombo < fullComboThreshold {
+			comboBasedMissCount = fullComboThreshold / math.Max(1, s.maxCombo)
+		}
+	}
+
+	// clamp miss count to the maximum amount of possible breaks
+	comboBasedMissCount = math.Min(comboBasedMissCount, s.countOk+s.countMeh+s.countMiss)
+
+	return math.Max(s.countMiss, comboBasedMissCount)
+}
+
+func (d *StandardDifficulty) comboScalingFactor(s standardScore) float64 {
+	if d.MaxCombo <= 0 {
+		return 1
+	}
+	return math.Min(math.Pow(s.maxCombo, 0.8)/math.Pow(float64(d.MaxCombo), 0.8), 1)
+}
+
+func standardLengthBonus(totalHits float64) float64 {
+	bonus := 0.95 + 0.4*math.Min(1, totalHits/2000)
+	if totalHits > 2000 {
+		bonus += math.Log10(totalHits/2000) * 0.5
+	}
+	return bonus
+}
+
+func (d *StandardDifficulty) aimValue(s standardScore, effectiveMissCount float64) float64 {
+	aimValue := math.Pow(5*math.Max(1, d.AimDifficulty/0.0675)-4, 3) / 100000
+
+	lengthBonus := standardLengthBonus(s.totalHits)
+	aimValue *= lengthBonus
+
+	// penalize misses relative to the total number of objects, with a 3%
+	// reduction for any number of misses
+	if effectiveMissCount > 0 {
+		aimValue *= 0.97 * math.Pow(1-math.Pow(effectiveMissCount/s.totalHits, 0.775), effectiveMissCount)
+	}
+
+	aimValue *= d.comboScalingFactor(s)
+
+	approachRateFactor := 0.0
+	if d.ApproachRate > 10.33 {
+		approachRateFactor = 0.3 * (d.ApproachRate - 10.33)
+	} else if d.ApproachRate < 8 {
+		approachRateFactor = 0.05 * (8 - d.ApproachRate)
+	}
+	if s.mods.Has(ModRelax) {
+		approachRateFactor = 0
+	}
+
+	// buff for longer maps with high AR
+	aimValue *= 1 + approachRateFactor*lengthBonus
+
+	// reward lower AR more with hidden
+	if s.mods.Has(ModHidden) {
+		aimValue *= 1 + 0.04*(12-d.ApproachRate)
+	}
+
+	// 15% of the sliders of a map are assumed to be difficult since there's
+	// no way to tell from the score
+	estimateDifficultSliders := float64(d.SliderCount) * 0.15
+
+	if d.SliderCount > 0 {
+		estimateSliderEndsDropped := clamp(math.Min(s.countOk+s.countMeh+s.countMiss, float64(d.MaxCombo)-s.maxCombo), 0, estimateDifficultSliders)
+		sliderNerfFactor := (1-d.SliderFactor)*math.Pow(1-estimateSliderEndsDropped/estimateDifficultSliders, 3) + d.SliderFactor
+		aimValue *= sliderNerfFactor
+	}
+
+	aimValue *= s.accuracy
+	// accuracy difficulty matters when scaling with accuracy
+	aimValue *= 0.98 + math.Pow(d.OverallDifficulty, 2)/2500
+
+	return aimValue
+}
+
+func (d *StandardDifficulty) speedValue(s standardScore, effectiveMissCount float64) float64 {
+	if s.mods.Has(ModRelax) {
+		return 0
+	}
+
+	speedValue := math.Pow(5*math.Max(1, d.SpeedDifficulty/0.0675)-4, 3) / 100000
+
+	lengthBonus := standardLengthBonus(s.totalHits)
+	speedValue *= lengthBonus
+
+	if effectiveMissCount > 0 {
+		speedValue *= 0.97 * math.Pow(1-math.Pow(effectiveMissCount/s.totalHits, 0.775), math.Pow(effectiveMissCount, 0.875))
+	}
+
+	speedValue *= d.comboScalingFactor(s)
+
+	approachRateFactor := 0.0
+	if d.ApproachRate > 10.33 {
+		approachRateFactor = 0.3 * (d.ApproachRate - 10.33)
+	}
+
+	// buff for longer maps with high AR
+	speedValue *= 1 + approachRateFactor*lengthBonus
+
+	if s.mods.Has(ModHidden) {
+		speedValue *= 1 + 0.04*(12-d.ApproachRate)
+	}
+
+	// accuracy of the notes relevant to speed, assuming the worst case
+	relevantTotalDiff := s.totalHits - d.SpeedNoteCount
+	relevantCountGreat := math.Max(0, s.countGreat-relevantTotalDiff)
+	relevantCountOk := math.Max(0, s.countOk-math.Max(0, relevantTotalDiff-s.countGreat))
+	relevantCountMeh := math.Max(0, s.countMeh-math.Max(0, relevantTotalDiff-s.countGreat-s.countOk))
+	relevantAccuracy := 0.0
+	if d.SpeedNoteCount != 0 {
+		relevantAccuracy = (relevantCountGreat*6 + relevantCountOk*2 + relevantCountMeh) / (d.SpeedNoteCount * 6)
+	}
+
+	// scale the speed value with accuracy and OD
+	speedValue *= (0.95 + math.Pow(d.OverallDifficulty, 2)/750) * math.Pow((s.accuracy+relevantAccuracy)/2, (14.5-math.Max(d.OverallDifficulty, 8))/2)
+
+	// scale the speed value with the number of 50s to punish doubletapping
+	if s.countMeh >= s.totalHits/500 {
+		speedValue *= math.Pow(0.99, s.countMeh-s.totalHits/500)
+	}
+
+	return speedValue
+}
+
+func (d *StandardDifficulty) accuracyValue(s standardScore) float64 {
+	if s.mods.Has(ModRelax) {
+		return 0
+	}
+
+	// only hit circles are considered as they are judged by the hit window,
+	// slider heads too with ScoreV2
+	objectsWithAccuracy := float64(d.HitCircleCount)
+	if s.mods.Has(ModScoreV2) {
+		objectsWithAccuracy += float64(d.SliderCount)
+	}
+
+	betterAccuracyPercentage := 0.0
+	if objectsWithAccuracy > 0 {
+		betterAccuracyPercentage = ((s.countGreat-(s.totalHits-objectsWithAccuracy))*6 + s.countOk*2 + s.countMeh) / (objectsWithAccuracy * 6)
+	}
+	// the formula can go negative
+	betterAccuracyPercentage = math.Max(0, betterAccuracyPercentage)
+
+	accuracyValue := math.Pow(1.52163, d.OverallDifficulty) * math.Pow(betterAccuracyPercentage, 24) * 2.83
+
+	// bonus for many hit circles, it's harder to keep good accuracy up for
+	// longer
+	accuracyValue *= math.Min(1.15, math.Pow(objectsWithAccuracy/1000, 0.3))
+
+	if s.mods.Has(ModHidden) {
+		accuracyValue *= 1.08
+	}
+	if s.mods.Has(ModFlashlight) {
+		accuracyValue *= 1.02
+	}
+
+	return accuracyValue
+}
+
+func (d *StandardDifficulty) flashlightValue(s standardScore, effectiveMissCount float64) float64 {
+	if !s.mods.Has(ModFlashlight) {
+		return 0
+	}
+
+	flashlightValue := math.Pow(d.FlashlightDifficulty, 2) * 25
+
+	if effectiveMissCount > 0 {
+		flashlightValue *= 0.97 * math.Pow(1-math.Pow(effectiveMissCount/s.totalHits, 0.775), math.Pow(effectiveMissCount, 0.875))
+	}
+
+	flashlightValue *= d.comboScalingFactor(s)
+
+	// shorter maps have a higher ratio of 0 combo/100 combo flashlight radius
+	lengthFactor := 0.7 + 0.1*math.Min(1, s.totalHits/200)
+	if s.totalHits > 200 {
+		lengthFactor += 0.2 * math.Min(1, (s.totalHits-200)/200)
+	}
+	flashlightValue *= lengthFactor
+
+	// scale the flashlight value with accuracy slightly, considering the
+	// accuracy difficulty as well
+	flashlightValue *= 0.5 + s.accuracy/2
+	flashlightValue *= 0.98 + math.Pow(d.OverallDifficulty, 2)/2500
+
+	return flashlightValue
+}
+
+// TaikoPerformance is the pp of an osu!taiko score split into its
+// components.
+type TaikoPerformance struct {
+	Total              float64
+	Difficulty         float64
+	Accuracy           float64
+	EffectiveMissCount float64
+}
+
+// Performance calculates the pp of an osu!taiko score on the beatmap the
+// difficulty was calculated for.
+func (d *TaikoDifficulty) Performance(score *Score) *TaikoPerformance {
+	countGreat := float64(score.Count300s)
+	countOk := float64(score.Count100s)
+	countMeh := float64(score.Count50)
+	countMiss := float64(score.CountMiss)
+	totalHits := countGreat + countOk + countMeh + countMiss
+	totalSuccessfulHits := countGreat + countOk + countMeh
+
+	accuracy := 0.0
+	if totalHits > 0 {
+		accuracy = (countGreat + countOk/2) / totalHits
+	}
+
+	p := &TaikoPerformance{}
+
+	// misses are penalised more on maps with less than 1000 objects
+	if totalSuccessfulHits > 0 {
+		p.EffectiveMissCount = math.Max(1, 1000/totalSuccessfulHits) * countMiss
+	}
+
+	multiplier := 1.13
+	if score.Mods.Has(ModHidden) {
+		multiplier *= 1.075
+	}
+	if score.Mods.Has(ModEasy) {
+		multiplier *= 0.975
+	}
+
+	p.Difficulty = math.Pow(5*math.Max(1, d.StarRating/0.115)-4, 2.25) / 1150
+
+	lengthBonus := 1 + 0.1*math.Min(1, totalHits/1500)
+	p.Difficulty *= lengthBonus
+	p.Difficulty *= math.Pow(0.986, p.EffectiveMissCount)
+
+	if score.Mods.Has(ModEasy) {
+		p.Difficulty *= 0.985
+	}
+	if score.Mods.Has(ModHidden) {
+		p.Difficulty *= 1.025
+	}
+	if score.Mods.Has(ModHardRock) {
+		p.Difficulty *= 1.05
+	}
+	if score.Mods.Has(ModFlashlight) {
+		p.Difficulty *= 1.05 * lengthBonus
+	}
+	p.Difficulty *= math.Pow(accuracy, 2)
+
+	if d.GreatHitWindow > 0 {
+		p.Accuracy = math.Pow(60/d.GreatHitWindow, 1.1) * math.Pow(accuracy, 8) * math.Pow(d.StarRating, 0.4) * 27
+
+		accuracyLengthBonus := math.Min(1.15, math.Pow(totalHits/1500, 0.3))
+		p.Accuracy *= accuracyLengthBonus
+
+		// slight bonus for HDFL, clamped to prevent negative values
+		if score.Mods.Has(ModFlashlight) && score.Mods.Has(ModHidden) {
+			p.Accuracy *= math.Max(1.05, 1.075*accuracyLengthBonus)
+		}
+	}
+
+	p.Total = math.Pow(math.Pow(p.Difficulty, 1.1)+math.Pow(p.Accuracy, 1.1), 1/1.1) * multiplier
+	return p
+}
+
+// CatchPerformance is the pp of an osu!catch score.
+type CatchPerformance struct {
+	Total float64
+}
+
+// Performance calculates the pp of an osu!catch score on the beatmap the
+// difficulty was calculated for. Count300s are fruits, Count100s droplets,
+// Count50 tiny droplets and Katus missed tiny droplets.
+func (d *CatchDifficulty) Performance(score *Score) *CatchPerformance {
+	fruitsHit := float64(score.Count300s)
+	ticksHit := float64(score.Count100s)
+	tinyTicksHit := float64(score.Count50)
+	tinyTicksMissed := float64(score.Katus)
+	misses := float64(score.CountMiss)
+
+	totalHits := tinyTicksHit + ticksHit + fruitsHit + misses + tinyTicksMissed
+	accuracy := 0.0
+	if totalHits > 0 {
+		accuracy = clamp((tinyTicksHit+ticksHit+fruitsHit)/totalHits, 0, 1)
+	}
+
+	value := math.Pow(5*math.Max(1, d.StarRating/0.0049)-4, 2) / 100000
+
+	// longer maps are worth more, counting the objects that give combo
+	totalComboHits := misses + ticksHit + fruitsHit
+	lengthBonus := 0.95 + 0.3*math.Min(1, totalComboHits/2500)
+	if totalComboHits > 2500 {
+		lengthBonus += math.Log10(totalComboHits/2500) * 0.475
+	}
+	value *= lengthBonus
+
+	value *= math.Pow(0.97, misses)
+
+	if d.MaxCombo > 0 {
+		value *= math.Min(math.Pow(float64(score.MaxCombo), 0.8)/math.Pow(float64(d.MaxCombo), 0.8), 1)
+	}
+
+	approachRateFactor := 1.0
+	if d.ApproachRate > 9 {
+		// 10% for each AR above 9
+		approachRateFactor += 0.1 * (d.ApproachRate - 9)
+	}
+	if d.ApproachRate > 10 {
+		// additional 10% at AR 11, 30% total
+		approachRateFactor += 0.1 * (d.ApproachRate - 10)
+	} else if d.ApproachRate < 8 {
+		// 2.5% for each AR below 8
+		approachRateFactor += 0.025 * (8 - d.ApproachRate)
+	}
+	value *= approachRateFactor
+
+	if score.Mods.Has(ModHidden) {
+		// hidden gives almost nothing on max approach rate, and more the
+		// lower it is
+		if d.ApproachRate <= 10 {
+			value *= 1.05 + 0.075*(10-d.ApproachRate)
+		} else {
+			value *= 1.01 + 0.04*(11-math.Min(11, d.ApproachRate))
+		}
+	}
+
+	if score.Mods.Has(ModFlashlight) {
+		value *= 1.35 * lengthBonus
+	}
+
+	value *= math.Pow(accuracy, 5.5)
+
+	if score.Mods.Has(ModNoFail) {
+		value *= 0.9
+	}
+
+	return &CatchPerformance{Total: value}
+}
+
+// ManiaPerformance is the pp of an osu!mania score.
+type ManiaPerformance struct {
+	Total      float64
+	Difficulty float64
+}
+
+// Performance calculates the pp of an osu!mania score on the beatmap the
+// difficulty was calculated for. Gekis are MAX and Katus 200 judgements.
+func (d *ManiaDifficulty) Performance(score *Score) *ManiaPerformance {
+	countPerfect := float64(score.Gekis)
+	countGreat := float64(score.Count300s)
+	countGood := float64(score.Katus)
+	countOk := float64(score.Count100s)
+	countMeh := float64(score.Count50)
+	countMiss := float64(score.CountMiss)
+	totalHits := countPerfect + countGreat + countGood + countOk + countMeh + countMiss
+
+	accuracy := 0.0
+	if totalHits > 0 {
+		accuracy = (countPerfect*320 + countGreat*300 + countGood*200 + countOk*100 + countMeh*50) / (totalHits * 320)
+	}
+
+	multiplier := 8.0
+	if score.Mods.Has(ModNoFail) {
+		multiplier *= 0.75
+	}
+	if score.Mods.Has(ModEasy) {
+		multiplier *= 0.5
+	}
+
+	p := &ManiaPerformance{}
+	// from 80% accuracy on, 1/20th of the pp is awarded per 1% accuracy,
+	// with a length bonus capped at 1500 notes
+	p.Difficulty = math.Pow(math.Max(d.StarRating-0.15, 0.05), 2.2) *
+		math.Max(0, 5*accuracy-4) *
+		(1 + 0.1*math.Min(1, totalHits/1500))
+
+	p.Total = p.Difficulty * multiplier
+	return p
+}
@@ -0,0 +1,122 @@
+package osuParser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStandardPerformance(t *testing.T) {
+	// regression values, the formulas are checked by
+	// TestStandardPerformanceFormulas
+	f := parseFixture(t, "standard.osu")
+
+	tests := []struct {
+		mods      string
+		ss        float64
+		imperfect float64
+	}{
+		{"NM", 39.038666, 3.117794},
+		{"HR", 78.526873, 4.262489},
+		{"DT", 82.042059, 6.959410},
+		{"EZHT", 7.507637, 1.627308},
+		{"HDFL", 44.214867, 3.975081},
+	}
+
+	for _, test := range tests {
+		mods := mustParseMods(t, test.mods)
+		d := f.StandardDifficulty(mods)
+		objects := uint16(len(f.HitObjects))
+
+		ss := d.Performance(&Score{
+			Count300s: objects,
+			MaxCombo:  uint16(d.MaxCombo),
+			Mods:      mods,
+		})
+		if !approxEqual(ss.Total, test.ss) {
+			t.Errorf("%s: SS is worth %.6fpp, want %.6f", test.mods, ss.Total, test.ss)
+		}
+		if ss.EffectiveMissCount != 0 {
+			t.Errorf("%s: SS has %v effective misses", test.mods, ss.EffectiveMissCount)
+		}
+
+		imperfect := d.Performance(&Score{
+			Count300s: objects - 7,
+			Count100s: 5,
+			CountMiss: 2,
+			MaxCombo:  uint16(d.MaxCombo / 2),
+			Mods:      mods,
+		})
+		if !approxEqual(imperfect.Total, test.imperfect) {
+			t.Errorf("%s: imperfect score is worth %.6fpp, want %.6f", test.mods, imperfect.Total, test.imperfect)
+		}
+		if imperfect.EffectiveMissCount < 2 {
+			t.Errorf("%s: %v effective misses for 2 misses", test.mods, imperfect.EffectiveMissCount)
+		}
+	}
+}
+
+func TestStandardPerformanceFormulas(t *testing.T) {
+	// 500 circles with no sliders, so the effective misses are the misses
+	d := &StandardDifficulty{
+		AimDifficulty:     2,
+		SpeedDifficulty:   1.8,
+		OverallDifficulty: 9,
+		ApproachRate:      9.5,
+		SpeedNoteCount:    300,
+		MaxCombo:          500,
+		HitCircleCount:    480,
+		SpinnerCount:      20,
+	}
+
+	// calculated by hand from the osu! performance calculator: aim and speed
+	// are (5*difficulty/0.0675-4)^3/100000 with a length bonus of 1.05 and
+	// the overall difficulty bonus, accuracy is 1.52163^9*2.83*0.48^0.3
+	ss := d.Performance(&Score{Count300s: 500, MaxCombo: 500})
+	got := []float64{ss.Total, ss.Aim, ss.Speed, ss.Accuracy, ss.Flashlight}
+	want := []float64{163.276721, 31.839676, 24.032911, 99.297969, 0}
+	for i := range got {
+		if !approxEqual(got[i], want[i]) {
+			t.Errorf("SS: got total, aim, speed, accuracy, flashlight %.6f, want %.6f", got, want)
+			break
+		}
+	}
+
+	misses := &Score{Count300s: 497, CountMiss: 3, MaxCombo: 250}
+	noFail := *misses
+	noFail.Mods = ModNoFail
+	if ratio := d.Performance(&noFail).Total / d.Performance(misses).Total; !approxEqual(ratio, 0.94) {
+		t.Errorf("NF with 3 misses keeps %.6f of the pp, want 0.94", ratio)
+	}
+
+	misses = &Score{Count300s: 490, CountMiss: 10, MaxCombo: 250}
+	noFail = *misses
+	noFail.Mods = ModNoFail
+	if ratio := d.Performance(&noFail).Total / d.Performance(misses).Total; !approxEqual(ratio, 0.9) {
+		t.Errorf("NF with 10 misses keeps %.6f of the pp, want 0.9", ratio)
+	}
+
+	spunOut := d.Performance(&Score{Count300s: 500, MaxCombo: 500, Mods: ModSpunOut})
+	if ratio, want := spunOut.Total/ss.Total, 1-math.Pow(20.0/500, 0.85); !approxEqual(ratio, want) {
+		t.Errorf("SO keeps %.6f of the pp, want %.6f", ratio, want)
+	}
+
+	relax := d.Performance(&Score{Count300s: 500, MaxCombo: 500, Mods: ModRelax})
+	if relax.Speed != 0 || relax.Accuracy != 0 || !approxEqual(relax.Aim, ss.Aim) {
+		t.Errorf("RX: aim %.6f, speed %.6f, accuracy %.6f, want %.6f, 0 and 0", relax.Aim, relax.Speed, relax.Accuracy, ss.Aim)
+	}
+
+	// a worse score is never worth more
+	previous := ss.Total
+	for _, score := range []Score{
+		{Count300s: 495, Count100s: 5, MaxCombo: 500},
+		{Count300s: 490, Count100s: 10, MaxCombo: 500},
+		{Count300s: 490, Count100s: 9, CountMiss: 1, MaxCombo: 400},
+		{Count300s: 490, Count100s: 8, CountMiss: 2, MaxCombo: 200},
+	} {
+		p := d.Performance(&score)
+		if p.Total >= previous {
+			t.Errorf("%d 100s and %d misses are worth %.6fpp, at least as much as the better score", score.Count100s, score.CountMiss, p.Total)
+		}
+		previous = p.Total
+	}
+}
@@ -0,0 +1,53 @@
+package osuParser
+
+// legacyRandom is the xorshift random number generator osu! stable uses for
+// beatmap conversion, so converted objects come out the same as in game.
+type legacyRandom struct {
+	x, y, z, w uint32
+
+	bitBuffer uint32
+	bitIndex  int
+}
+
+func newLegacyRandom(seed int32) *legacyRandom {
+	return &legacyRandom{
+		x:        uint32(seed),
+		y:        842502087,
+		z:        3579807591,
+		w:        273326509,
+		bitIndex: 32,
+	}
+}
+
+func (r *legacyRandom) nextUint() uint32 {
+	t := r.x ^ (r.x << 11)
+	r.x, r.y, r.z = r.y, r.z, r.w
+	r.w = r.w ^ (r.w >> 19) ^ t ^ (t >> 8)
+	return r.w
+}
+
+func (r *legacyRandom) next() int {
+	return int(0x7FFFFFFF & r.nextUint())
+}
+
+func (r *legacyRandom) nextDouble() float64 {
+	const intToReal = 1.0 / (0x7FFFFFFF + 1.0)
+	return intToReal * float64(r.next())
+}
+
+// nextRange returns a number from lower up to but excluding upper.
+func (r *legacyRandom) nextRange(lower, upper float64) int {
+	return int(lower + r.nextDouble()*(upper-lower))
+}
+
+func (r *legacyRandom) nextBool() bool {
+	if r.bitIndex == 32 {
+		r.bitBuffer = r.nextUint()
+		r.bitIndex = 1
+		return r.bitBuffer&1 == 1
+	}
+
+	r.bitIndex++
+	r.bitBuffer >>= 1
+	return r.bitBuffer&1 == 1
+}
@@ -4,15 +4,181 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"io/fs"
+	"math"
+	"os"
+	"slices"
 	"time"
 )
 
-func readULEB128(r io.Reader) (uint64, error) {
+// decodeFile opens filename and decodes it with decode.
+func decodeFile[T any](filename string, decode func(r io.Reader, name string) (T, error)) (T, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer file.Close()
+
+	return decode(file, filename)
+}
+
+// decodeFS opens name in fsys and decodes it with decode.
+func decodeFS[T any](fsys fs.FS, name string, decode func(r io.Reader, name string) (T, error)) (T, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer file.Close()
+
+	return decode(file, name)
+}
+
+// decoderBufferSize is the size of the window a decoder reads a stream with.
+const decoderBufferSize = 128 * 1024
+
+// internedStrings is the number of strings a decoder remembers for
+// interning. It's bounded so streaming large databases doesn't grow memory.
+const internedStrings = 1024
+
+// maxCapacityHint bounds the capacity preallocated for entries counted in a
+// file, so a corrupt count fails while reading the entries instead of
+// allocating.
+const maxCapacityHint = 1 << 16
+
+// capacityHint returns the capacity to preallocate for count entries.
+func capacityHint(count int32) int {
+	return int(min(max(count, 0), maxCapacityHint))
+}
+
+// decoder reads the little-endian values of osu!'s binary formats from a
+// reusable byte slice it refills from r.
+type decoder struct {
+	r   io.Reader
+	buf []byte
+	pos int
+	// base is the offset of buf[0] in the stream
+	base int64
+
+	// interned caches strings by their length and a few of their bytes,
+	// which is cheaper than hashing them and catches the strings beatmaps
+	// of a set share
+	interned [internedStrings]string
+
+	// nulls marks the strings read since the last takeNulls that were null,
+	// by the order they were read in
+	nulls   nullStrings
+	strings int
+}
+
+// nullStrings marks which strings of an entry are stored as null instead of
+// empty, by their order in the entry, so writing the entry reproduces the
+// file.
+type nullStrings uint64
+
+func (n nullStrings) has(i int) bool {
+	return i < 64 && n&(1<<i) != 0
+}
+
+// newDecoder returns a decoder reading r through a reusable buffer.
+func newDecoder(r io.Reader) *decoder {
+	return newDecoderSize(r, decoderBufferSize)
+}
+
+// newDecoderSize returns a decoder with a buffer of size bytes, which grows
+// for larger values.
+func newDecoderSize(r io.Reader, size int) *decoder {
+	return &decoder{
+		r:   r,
+		buf: make([]byte, 0, size),
+	}
+}
+
+// offset returns the number of bytes read.
+func (d *decoder) offset() int64 {
+	return d.base + int64(d.pos)
+}
+
+// fill makes sure n bytes are buffered. It returns io.EOF if the stream ended
+// before the first of them and io.ErrUnexpectedEOF if it ended after.
+func (d *decoder) fill(n int) error {
+	if len(d.buf)-d.pos >= n {
+		return nil
+	}
+	// move the unread bytes to the front
+	remaining := len(d.buf) - d.pos
+	copy(d.buf[:cap(d.buf)], d.buf[d.pos:])
+	d.buf = d.buf[:remaining]
+	d.base += int64(d.pos)
+	d.pos = 0
+
+	for len(d.buf) < n {
+		// values larger than the buffer grow it as their bytes arrive, so a
+		// corrupt length fails at the end of the stream instead of
+		// allocating it up front
+		if len(d.buf) == cap(d.buf) {
+			d.buf = slices.Grow(d.buf, min(n-len(d.buf), max(cap(d.buf), 512)))
+		}
+
+		read, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+read]
+		if err != nil && len(d.buf) < n {
+			if err == io.EOF && len(d.buf) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the next n bytes. They are only valid until the next read.
+func (d *decoder) next(n int) ([]byte, error) {
+	if len(d.buf)-d.pos < n {
+		if err := d.fill(n); err != nil {
+			return nil, err
+		}
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// skip discards the next n bytes.
+func (d *decoder) skip(n int) error {
+	for n > 0 {
+		if d.pos == len(d.buf) {
+			if err := d.fill(1); err != nil {
+				return err
+			}
+		}
+		chunk := min(n, len(d.buf)-d.pos)
+		d.pos += chunk
+		n -= chunk
+	}
+	return nil
+}
+
+// Read reads the buffered bytes before the rest of the stream, for values
+// that are streamed instead of read at once.
+func (d *decoder) Read(p []byte) (int, error) {
+	if d.pos == len(d.buf) {
+		if err := d.fill(1); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf[d.pos:])
+	d.pos += n
+	return n, nil
+}
+
+func (d *decoder) readULEB128() (uint64, error) {
 	var result uint64
 	var shift uint
 	for {
-		var byteVal byte
-		if err := binary.Read(r, binary.LittleEndian, &byteVal); err != nil {
+		byteVal, err := d.readByte()
+		if err != nil {
 			return 0, err
 		}
 		result |= uint64(byteVal&0x7F) << shift
@@ -24,91 +190,157 @@ func readULEB128(r io.Reader) (uint64, error) {
 	return result, nil
 }
 
-func readBoolean(r io.Reader) (bool, error) {
-	var b byte
-	if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
+func (d *decoder) readBoolean() (bool, error) {
+	b, err := d.readByte()
+	if err != nil {
 		return false, err
 	}
 	return b != 0x00, nil
 }
 
-func readByte(r io.Reader) (byte, error) {
-	var byte byte
-	if err := binary.Read(r, binary.LittleEndian, &byte); err != nil {
+func (d *decoder) readByte() (byte, error) {
+	if d.pos < len(d.buf) {
+		b := d.buf[d.pos]
+		d.pos++
+		return b, nil
+	}
+
+	b, err := d.next(1)
+	if err != nil {
 		return 0x0b, err
 	}
+	return b[0], nil
+}
 
-	return byte, nil
+// readStringBytes reads a string and returns its bytes, which are only valid
+// until the next read.
+func (d *decoder) readStringBytes() ([]byte, error) {
+	length, err := d.readStringLength()
+	if err != nil || length == 0 {
+		return nil, err
+	}
+	return d.next(length)
 }
 
-func readString(r io.Reader) (string, error) {
-	var flag byte
-	if err := binary.Read(r, binary.LittleEndian, &flag); err != nil {
-		return "", err
+// readStringLength reads the flag and length of a string, recording null
+// strings. The bytes of the string follow.
+func (d *decoder) readStringLength() (int, error) {
+	flag, err := d.readByte()
+	if err != nil {
+		return 0, err
 	}
 
 	if flag == 0x00 {
-		return "", nil
+		if d.strings < 64 {
+			d.nulls |= 1 << d.strings
+		}
+		d.strings++
+		return 0, nil
 	} else if flag == 0x0b {
-		length, err := readULEB128(r)
+		d.strings++
+		length, err := d.readULEB128()
 		if err != nil {
-			return "", err
+			return 0, err
 		}
-		data := make([]byte, length)
-		if _, err := io.ReadFull(r, data); err != nil {
-			return "", err
+		if length > math.MaxInt32 {
+			return 0, errors.New("invalid string length")
 		}
-		return string(data), nil
+		return int(length), nil
 	}
-	return "", errors.New("invalid string flag")
+	return 0, errors.New("invalid string flag")
 }
 
-func readInt(r io.Reader) (int32, error) {
-	var num int32
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
-		return 0, err
+func (d *decoder) readString() (string, error) {
+	data, err := d.readStringBytes()
+	if err != nil {
+		return "", err
 	}
-	return num, nil
+	return string(data), nil
 }
 
-func readShort(r io.Reader) (uint16, error) {
-	var num uint16
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+// readInternedString reads a string and returns the previous string for
+// equal values, for strings many beatmaps share like creators and fonts.
+func (d *decoder) readInternedString() (string, error) {
+	data, err := d.readStringBytes()
+	if err != nil || len(data) == 0 {
+		return "", err
+	}
+
+	n := len(data)
+	slot := (n*31 + int(data[0])*7 + int(data[n/2])*3 + int(data[n-1])) % internedStrings
+	if s := d.interned[slot]; s == string(data) {
+		return s, nil
+	}
+	s := string(data)
+	d.interned[slot] = s
+	return s, nil
+}
+
+// takeNulls returns which of the strings read since the last call were null
+// and starts over.
+func (d *decoder) takeNulls() nullStrings {
+	nulls := d.nulls
+	d.nulls, d.strings = 0, 0
+	return nulls
+}
+
+// skipStrings discards the next n strings.
+func (d *decoder) skipStrings(n int) error {
+	for range n {
+		length, err := d.readStringLength()
+		if err != nil {
+			return err
+		}
+		if err := d.skip(length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) readInt() (int32, error) {
+	b, err := d.next(4)
+	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return int32(binary.LittleEndian.Uint32(b)), nil
 }
 
-func readShortSigned(r io.Reader) (int16, error) {
-	var num int16
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+func (d *decoder) readShort() (uint16, error) {
+	b, err := d.next(2)
+	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return binary.LittleEndian.Uint16(b), nil
 }
 
-func readLong(r io.Reader) (int64, error) {
-	var num int64
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+func (d *decoder) readShortSigned() (int16, error) {
+	num, err := d.readShort()
+	return int16(num), err
+}
+
+func (d *decoder) readLong() (int64, error) {
+	b, err := d.next(8)
+	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return int64(binary.LittleEndian.Uint64(b)), nil
 }
 
-func readSingle(r io.Reader) (float32, error) {
-	var num float32
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+func (d *decoder) readSingle() (float32, error) {
+	b, err := d.next(4)
+	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
 }
 
-func readDouble(r io.Reader) (float64, error) {
-	var num float64
-	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+func (d *decoder) readDouble() (float64, error) {
+	b, err := d.next(8)
+	if err != nil {
 		return 0, err
 	}
-	return num, nil
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
 }
 
 func readDateTime(ticks int64) time.Time {
@@ -122,41 +354,54 @@ func readDateTime(ticks int64) time.Time {
 	return time.Unix(seconds, nanoseconds).UTC()
 }
 
-func readIntDoublePairs(r io.Reader) (map[int]int64, error) {
-	count, err := readInt(r)
+// readStarRatings reads the star ratings of a mode, which are cached as
+// Int-Double or, with floats set, Int-Float pairs. It also returns the order
+// of the mod combinations if it isn't the one osu! writes.
+func (d *decoder) readStarRatings(floats bool) (map[Mods]float64, []Mods, error) {
+	count, err := d.readInt()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	pairs := make(map[int]int64)
-	for i := 0; i < int(count); i++ {
-		var flag byte
-		if err := binary.Read(r, binary.LittleEndian, &flag); err != nil {
-			return nil, err
-		}
-		if flag != 0x08 {
-			return nil, errors.New("invalid Int-Double pair flag")
-		}
+	valueFlag, valueSize := byte(0x0d), 8
+	if floats {
+		valueFlag, valueSize = 0x0c, 4
+	}
 
-		intVal, err := readInt(r)
+	// presized for the mod combinations osu! caches, so the map is
+	// allocated once
+	stars := make(map[Mods]float64, min(max(count, 0), int32(len(starRatingOrder))))
+	var order [16]Mods
+	keys := order[:0]
+	for i := 0; i < int(count); i++ {
+		// flag, int, flag, value
+		b, err := d.next(1 + 4 + 1 + valueSize)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		var doubleFlag byte
-		if err := binary.Read(r, binary.LittleEndian, &doubleFlag); err != nil {
-			return nil, err
+		if b[0] != 0x08 {
+			if floats {
+				return nil, nil, errors.New("invalid Int-Float pair flag")
+			}
+			return nil, nil, errors.New("invalid Int-Double pair flag")
 		}
-		if doubleFlag != 0x0d {
-			return nil, errors.New("invalid Double flag in Int-Double pair")
+		if b[5] != valueFlag {
+			if floats {
+				return nil, nil, errors.New("invalid Float flag in Int-Float pair")
+			}
+			return nil, nil, errors.New("invalid Double flag in Int-Double pair")
 		}
 
-		doubleVal, err := readDouble(r)
-		if err != nil {
-			return nil, err
+		mods := Mods(int32(binary.LittleEndian.Uint32(b[1:5])))
+		keys = append(keys, mods)
+		if floats {
+			stars[mods] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[6:])))
+		} else {
+			stars[mods] = math.Float64frombits(binary.LittleEndian.Uint64(b[6:]))
 		}
-
-		pairs[int(intVal)] = int64(doubleVal)
 	}
-	return pairs, nil
+	if len(keys) != len(stars) || !inStarRatingOrder(keys) {
+		return stars, slices.Clone(keys), nil
+	}
+	return stars, nil, nil
 }
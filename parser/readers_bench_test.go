@@ -0,0 +1,164 @@
+package osuParser
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// syntheticBeatmaps is the number of beatmaps of the generated databases,
+// about the size of a large install.
+const syntheticBeatmaps = 60000
+
+// syntheticOsuDB returns an osu!.db of n beatmaps in sets of 5 that share
+// their metadata, like the difficulties of a beatmap set do.
+func syntheticOsuDB(n int) *OsuDB {
+	db := &OsuDB{
+		Version:          20250107,
+		FolderCount:      int32(n / 5),
+		AccountUnlocked:  true,
+		PlayerName:       "player",
+		NumberOfBeatmaps: int32(n),
+		UserPermissions:  PermissionNormal,
+	}
+
+	for i := range n {
+		set := i / 5
+
+		stars := make(map[Mods]float64, len(starRatingOrder))
+		for j, mods := range starRatingOrder {
+			stars[mods] = float64(i%7) + float64(j)/8
+		}
+		timingPoints := make([]TimingPoint, 10)
+		for j := range timingPoints {
+			timingPoints[j] = TimingPoint{BPM: 300, Offset: float64(j * 1000), Inherited: j%2 == 0}
+		}
+
+		db.Beatmaps = append(db.Beatmaps, &Beatmap{
+			Artist:              fmt.Sprintf("Artist %d", set),
+			ArtistUnicode:       fmt.Sprintf("Artist %d", set),
+			SongTitle:           fmt.Sprintf("Title %d", set),
+			SongTitleUnicode:    fmt.Sprintf("Title %d", set),
+			Creator:             fmt.Sprintf("Mapper %d", set%300),
+			Difficulty:          fmt.Sprintf("Difficulty %d", i%5),
+			AudioFileName:       "audio.mp3",
+			MD5Hash:             fmt.Sprintf("%032x", i),
+			FileName:            fmt.Sprintf("Artist %d - Title %d (Mapper) [Difficulty %d].osu", set, set, i%5),
+			RankedStatus:        RankedStatusRanked,
+			StarRatingsStandard: stars,
+			StarRatingsTaiko:    stars,
+			StarRatingsCTB:      stars,
+			StarRatingsMania:    stars,
+			TimingPoints:        timingPoints,
+			DifficultyID:        int32(i),
+			BeatmapID:           int32(set),
+			GradeStandard:       GradeNone,
+			GradeTaiko:          GradeNone,
+			GradeCTB:            GradeNone,
+			GradeMania:          GradeNone,
+			SongTags:            "some tags of the beatmap set",
+			FolderName:          fmt.Sprintf("%d Artist %d - Title %d", set, set, set),
+		})
+	}
+	return db
+}
+
+// syntheticFiles returns the osu!.db, scores.db and collection.db of a
+// synthetic install, generated once.
+var syntheticFiles = sync.OnceValue(func() [3][]byte {
+	db := syntheticOsuDB(syntheticBeatmaps)
+
+	scores := &Scores{Version: db.Version}
+	collections := &Collections{Version: db.Version}
+	for i, beatmap := range db.Beatmaps {
+		if i%3 == 0 {
+			score := &Score{
+				Version:        db.Version,
+				BeatmapMD5Hash: beatmap.MD5Hash,
+				PlayerName:     db.PlayerName,
+				ReplayMD5Hash:  fmt.Sprintf("%032x", i+syntheticBeatmaps),
+				Count300s:      500,
+				Count100s:      12,
+				MaxCombo:       700,
+			}
+			scores.Beatmaps = append(scores.Beatmaps, &BeatmapScores{
+				BeatmapMD5Hash: beatmap.MD5Hash,
+				NumberOfScores: 2,
+				Scores:         []*Score{score, score},
+			})
+		}
+		if i%1000 == 0 {
+			collections.Collections = append(collections.Collections, &Collection{Name: fmt.Sprintf("Collection %d", i)})
+		}
+		c := collections.Collections[len(collections.Collections)-1]
+		c.Beatmaps = append(c.Beatmaps, &beatmap.MD5Hash)
+		c.NumberOfBeatmaps++
+	}
+	scores.NumberOfScores = int32(len(scores.Beatmaps))
+	collections.NumberOfCollections = int32(len(collections.Collections))
+
+	var osuDB, scoresDB, collectionsDB bytes.Buffer
+	if err := writeOsuDB(&osuDB, db); err != nil {
+		panic(err)
+	}
+	if err := writeScores(&scoresDB, scores); err != nil {
+		panic(err)
+	}
+	if err := writeCollections(&collectionsDB, collections); err != nil {
+		panic(err)
+	}
+	return [3][]byte{osuDB.Bytes(), scoresDB.Bytes(), collectionsDB.Bytes()}
+})
+
+func BenchmarkParseOsuDB(b *testing.B) {
+	data := syntheticFiles()[0]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := DecodeOsuDB(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeScoresDB(b *testing.B) {
+	data := syntheticFiles()[1]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := DecodeScoresDB(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeCollectionsDB(b *testing.B) {
+	data := syntheticFiles()[2]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := DecodeCollectionsDB(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIndexOsuDB(b *testing.B) {
+	data := syntheticFiles()[0]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := DecodeOsuDBIndex(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
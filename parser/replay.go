@@ -0,0 +1,464 @@
+package osuParser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReplayFile struct {
+	Gamemode                 GameMode
+	Version                  int32
+	BeatmapMD5Hash           string
+	PlayerName               string
+	ReplayMD5Hash            string
+	Count300s                uint16
+	Count100s                uint16
+	Count50s                 uint16
+	Gekis                    uint16
+	Katus                    uint16
+	CountMiss                uint16
+	Score                    int32
+	Combo                    uint16
+	PerfectCombo             bool
+	Mods                     Mods
+	HealthGraph              []Health
+	Timestamp                time.Time
+	LengthInBytes            int32
+	Replay                   []ReplayData
+	Seed                     int32
+	OnlineScoreId            int64
+	AdditionalModInformation float64
+
+	nulls nullStrings
+}
+
+// Health is a single point of the life bar graph.
+type Health struct {
+	Time int32
+	Life float32
+}
+
+// ReplayData is a single replay frame. W is the time since the previous
+// frame, Time the absolute time in milliseconds.
+type ReplayData struct {
+	W    int64
+	Time int64
+	X    float32
+	Y    float32
+	Keys ReplayKeys
+}
+
+type ReplayKeys int32
+
+const (
+	KeyM1 ReplayKeys = 1 << iota
+	KeyM2
+	KeyK1
+	KeyK2
+	KeySmoke
+)
+
+// replaySeedFrame marks the trailing frame which stores the RNG seed in its
+// key field instead of input.
+const replaySeedFrame = -12345
+
+func ParseReplayFile(filename string) (*ReplayFile, error) {
+	return decodeFile(filename, decodeReplay)
+}
+
+// DecodeReplayFile reads a .osr replay from r.
+func DecodeReplayFile(r io.Reader) (*ReplayFile, error) {
+	return decodeReplay(r, "")
+}
+
+// ParseReplayFileFS reads the .osr replay name of fsys.
+func ParseReplayFileFS(fsys fs.FS, name string) (*ReplayFile, error) {
+	return decodeFS(fsys, name, decodeReplay)
+}
+
+func decodeReplay(r io.Reader, _ string) (*ReplayFile, error) {
+	return readReplay(newDecoder(r))
+}
+
+func WriteReplayFile(filename string, replay *ReplayFile) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return writeReplay(w, replay)
+	})
+}
+
+func readReplay(d *decoder) (*ReplayFile, error) {
+	replay := &ReplayFile{}
+
+	gamemode, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	replay.Gamemode = GameMode(gamemode)
+
+	version, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	replay.Version = version
+
+	beatmapMD5Hash, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	replay.BeatmapMD5Hash = beatmapMD5Hash
+
+	playerName, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	replay.PlayerName = playerName
+
+	replayMD5Hash, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	replay.ReplayMD5Hash = replayMD5Hash
+
+	counts := []*uint16{
+		&replay.Count300s,
+		&replay.Count100s,
+		&replay.Count50s,
+		&replay.Gekis,
+		&replay.Katus,
+		&replay.CountMiss,
+	}
+	for _, count := range counts {
+		*count, err = d.readShort()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	score, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	replay.Score = score
+
+	combo, err := d.readShort()
+	if err != nil {
+		return nil, err
+	}
+	replay.Combo = combo
+
+	perfectCombo, err := d.readBoolean()
+	if err != nil {
+		return nil, err
+	}
+	replay.PerfectCombo = perfectCombo
+
+	mods, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	replay.Mods = Mods(mods)
+
+	lifeBar, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	replay.HealthGraph, err = parseHealthGraph(lifeBar)
+	if err != nil {
+		return nil, err
+	}
+
+	ticks, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	replay.Timestamp = readDateTime(ticks)
+
+	length, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	replay.LengthInBytes = length
+
+	if length > 0 {
+		payload := io.LimitReader(d, int64(length))
+		replay.Replay, replay.Seed, err = decodeReplayData(payload)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(io.Discard, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case version >= 20140721:
+		replay.OnlineScoreId, err = d.readLong()
+	case version >= 20121008:
+		var id int32
+		id, err = d.readInt()
+		replay.OnlineScoreId = int64(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//TargetPractice
+	if Mods(mods).Has(ModTargetPractice) {
+		replay.AdditionalModInformation, err = d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replay.nulls = d.takeNulls()
+	return replay, nil
+}
+
+func parseHealthGraph(lifeBar string) ([]Health, error) {
+	var graph []Health
+	for _, point := range strings.Split(lifeBar, ",") {
+		if point == "" {
+			continue
+		}
+
+		parts := strings.Split(point, "|")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid life bar point: %q", point)
+		}
+
+		time, err := strconv.ParseInt(parts[0], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		life, err := strconv.ParseFloat(parts[1], 32)
+		if err != nil {
+			return nil, err
+		}
+
+		graph = append(graph, Health{
+			Time: int32(time),
+			Life: float32(life),
+		})
+	}
+	return graph, nil
+}
+
+func decodeReplayData(r io.Reader) ([]ReplayData, int32, error) {
+	lzma, err := newLZMAReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scanner := bufio.NewScanner(lzma)
+	scanner.Split(scanReplayFrames)
+
+	var frames []ReplayData
+	var seed int32
+	var time int64
+
+	for scanner.Scan() {
+		frame, err := parseReplayFrame(scanner.Text())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if frame.W == replaySeedFrame {
+			seed = int32(frame.Keys)
+			continue
+		}
+
+		time += frame.W
+		frame.Time = time
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return frames, seed, nil
+}
+
+func scanReplayFrames(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == ',' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func parseReplayFrame(frame string) (ReplayData, error) {
+	parts := strings.Split(frame, "|")
+	if len(parts) != 4 {
+		return ReplayData{}, fmt.Errorf("invalid replay frame: %q", frame)
+	}
+
+	w, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ReplayData{}, err
+	}
+	x, err := strconv.ParseFloat(parts[1], 32)
+	if err != nil {
+		return ReplayData{}, err
+	}
+	y, err := strconv.ParseFloat(parts[2], 32)
+	if err != nil {
+		return ReplayData{}, err
+	}
+	keys, err := strconv.ParseInt(parts[3], 10, 32)
+	if err != nil {
+		return ReplayData{}, err
+	}
+
+	return ReplayData{
+		W:    w,
+		X:    float32(x),
+		Y:    float32(y),
+		Keys: ReplayKeys(keys),
+	}, nil
+}
+
+func writeReplay(w io.Writer, replay *ReplayFile) error {
+	if err := writeByte(w, byte(replay.Gamemode)); err != nil {
+		return err
+	}
+	if err := writeInt(w, replay.Version); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, replay.BeatmapMD5Hash, replay.nulls.has(0)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, replay.PlayerName, replay.nulls.has(1)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, replay.ReplayMD5Hash, replay.nulls.has(2)); err != nil {
+		return err
+	}
+
+	counts := []uint16{
+		replay.Count300s,
+		replay.Count100s,
+		replay.Count50s,
+		replay.Gekis,
+		replay.Katus,
+		replay.CountMiss,
+	}
+	for _, count := range counts {
+		if err := writeShort(w, count); err != nil {
+			return err
+		}
+	}
+
+	if err := writeInt(w, replay.Score); err != nil {
+		return err
+	}
+	if err := writeShort(w, replay.Combo); err != nil {
+		return err
+	}
+	if err := writeBoolean(w, replay.PerfectCombo); err != nil {
+		return err
+	}
+	if err := writeInt(w, int32(replay.Mods)); err != nil {
+		return err
+	}
+	if err := writeNullableString(w, formatHealthGraph(replay.HealthGraph), replay.nulls.has(3)); err != nil {
+		return err
+	}
+	if err := writeLong(w, writeDateTime(replay.Timestamp)); err != nil {
+		return err
+	}
+
+	compressed, err := encodeReplayData(replay)
+	if err != nil {
+		return err
+	}
+	if err := writeInt(w, int32(len(compressed))); err != nil {
+		return err
+	}
+	if _, err := w.Write(compressed); err != nil {
+		return err
+	}
+
+	switch {
+	case replay.Version >= 20140721:
+		err = writeLong(w, replay.OnlineScoreId)
+	case replay.Version >= 20121008:
+		err = writeInt(w, int32(replay.OnlineScoreId))
+	}
+	if err != nil {
+		return err
+	}
+
+	//TargetPractice
+	if replay.Mods.Has(ModTargetPractice) {
+		if err := writeDouble(w, replay.AdditionalModInformation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatHealthGraph(graph []Health) string {
+	var sb strings.Builder
+	for _, point := range graph {
+		sb.WriteString(strconv.FormatInt(int64(point.Time), 10))
+		sb.WriteByte('|')
+		sb.WriteString(strconv.FormatFloat(float64(point.Life), 'f', -1, 32))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+func formatReplayFrames(replay *ReplayFile) string {
+	var sb strings.Builder
+	writeFrame := func(w int64, x, y float32, keys int64) {
+		sb.WriteString(strconv.FormatInt(w, 10))
+		sb.WriteByte('|')
+		sb.WriteString(strconv.FormatFloat(float64(x), 'f', -1, 32))
+		sb.WriteByte('|')
+		sb.WriteString(strconv.FormatFloat(float64(y), 'f', -1, 32))
+		sb.WriteByte('|')
+		sb.WriteString(strconv.FormatInt(keys, 10))
+		sb.WriteByte(',')
+	}
+
+	for _, frame := range replay.Replay {
+		writeFrame(frame.W, frame.X, frame.Y, int64(frame.Keys))
+	}
+	//RNG seed, written since 20130319
+	if replay.Version >= 20130319 {
+		writeFrame(replaySeedFrame, 0, 0, int64(replay.Seed))
+	}
+	return sb.String()
+}
+
+func encodeReplayData(replay *ReplayFile) ([]byte, error) {
+	if len(replay.Replay) == 0 && replay.Seed == 0 {
+		return nil, nil
+	}
+
+	data := formatReplayFrames(replay)
+
+	var buf bytes.Buffer
+	lzma, err := newLZMAWriter(&buf, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(lzma, data); err != nil {
+		return nil, err
+	}
+	if err := lzma.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package osuParser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReplayFile(t *testing.T) {
+	tests := []struct {
+		filename      string
+		version       int32
+		mode          GameMode
+		mods          Mods
+		health        int
+		frames        int
+		seed          int32
+		onlineScoreID int64
+		modInfo       float64
+	}{
+		{"testdata/replay.osr", 20250107, ModeStandard, ModHidden | ModDoubleTime, 3, 402, 7262, 4567890123, 0},
+		{"testdata/replay_20140720.osr", 20140720, ModeCatch, ModTargetPractice, 1, 4, 42, 123456, 0.25},
+		{"testdata/replay_20121007.osr", 20121007, ModeMania, ModNone, 0, 3, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		replay, err := ParseReplayFile(test.filename)
+		if err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+
+		if replay.Version != test.version || replay.Gamemode != test.mode || replay.Mods != test.mods {
+			t.Errorf("%s: version %d in mode %v with %v", test.filename, replay.Version, replay.Gamemode, replay.Mods)
+		}
+		if replay.BeatmapMD5Hash != "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" || replay.PlayerName != "player" || replay.ReplayMD5Hash != "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" {
+			t.Errorf("%s: strings decoded as %q, %q and %q", test.filename, replay.BeatmapMD5Hash, replay.PlayerName, replay.ReplayMD5Hash)
+		}
+		if replay.Count300s != 300 || replay.Count100s != 20 || replay.Count50s != 3 || replay.Gekis != 60 || replay.Katus != 10 || replay.CountMiss != 2 {
+			t.Errorf("%s: counts %+v", test.filename, replay)
+		}
+		if replay.Score != 1234567 || replay.Combo != 456 || replay.PerfectCombo {
+			t.Errorf("%s: score %d with %d combo, perfect %v", test.filename, replay.Score, replay.Combo, replay.PerfectCombo)
+		}
+		if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !replay.Timestamp.Equal(want) {
+			t.Errorf("%s: timestamp %v, want %v", test.filename, replay.Timestamp, want)
+		}
+		if len(replay.HealthGraph) != test.health {
+			t.Errorf("%s: %d life bar points, want %d", test.filename, len(replay.HealthGraph), test.health)
+		}
+		if replay.OnlineScoreId != test.onlineScoreID || replay.AdditionalModInformation != test.modInfo {
+			t.Errorf("%s: online score ID %d and mod information %v, want %d and %v",
+				test.filename, replay.OnlineScoreId, replay.AdditionalModInformation, test.onlineScoreID, test.modInfo)
+		}
+
+		// the seed frame is not part of the input
+		if len(replay.Replay) != test.frames || replay.Seed != test.seed {
+			t.Errorf("%s: %d frames with seed %d, want %d with %d", test.filename, len(replay.Replay), replay.Seed, test.frames, test.seed)
+		}
+		if f := replay.Replay[1]; f.W != -1 || f.Time != -1 || f.X != 256 || f.Y != -500 || f.Keys != 0 {
+			t.Errorf("%s: second frame %+v", test.filename, f)
+		}
+		if f := replay.Replay[2]; f.Time != f.W-1 {
+			t.Errorf("%s: third frame at %d, want %d", test.filename, f.Time, f.W-1)
+		}
+
+		// osu! compresses the frames differently, so compare the replays
+		filename := filepath.Join(t.TempDir(), filepath.Base(test.filename))
+		if err := WriteReplayFile(filename, replay); err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+		written, err := ParseReplayFileFS(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+		if err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+		written.LengthInBytes = replay.LengthInBytes
+		if !reflect.DeepEqual(written, replay) {
+			t.Errorf("%s: written replay differs", test.filename)
+		}
+	}
+}
+
+func TestReplayFileTruncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/replay_20140720.osr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < len(data); n++ {
+		if _, err := DecodeReplayFile(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+}
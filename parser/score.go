@@ -0,0 +1,219 @@
+package osuParser
+
+import (
+	"math"
+)
+
+// TotalHits returns the number of judged hit objects of the score. Gekis and
+// katus are only counted in osu!mania, where they are MAX and 200 judgements,
+// and osu!catch, where katus are missed tiny droplets.
+func (s *Score) TotalHits() int {
+	total := int(s.Count300s) + int(s.Count100s) + int(s.Count50) + int(s.CountMiss)
+	switch s.Gamemode {
+	case ModeTaiko:
+		return total - int(s.Count50)
+	case ModeCatch:
+		return total + int(s.Katus)
+	case ModeMania:
+		return total + int(s.Gekis) + int(s.Katus)
+	default:
+		return total
+	}
+}
+
+// Accuracy returns the accuracy of the score from 0 to 1 as osu! shows it for
+// the mode of the score. Scores without any hits have an accuracy of 1.
+func (s *Score) Accuracy() float64 {
+	totalHits := float64(s.TotalHits())
+	if totalHits == 0 {
+		return 1
+	}
+
+	count300 := float64(s.Count300s)
+	count100 := float64(s.Count100s)
+	count50 := float64(s.Count50)
+
+	switch s.Gamemode {
+	case ModeTaiko:
+		return (count300 + count100/2) / totalHits
+	case ModeCatch:
+		return (count300 + count100 + count50) / totalHits
+	case ModeMania:
+		return (300*(float64(s.Gekis)+count300) + 200*float64(s.Katus) + 100*count100 + 50*count50) / (300 * totalHits)
+	default:
+		return (300*count300 + 100*count100 + 50*count50) / (300 * totalHits)
+	}
+}
+
+// Grade returns the grade osu! stable awards the score. Hidden and
+// flashlight, as well as fade in in osu!mania, turn SS and S silver. Failed
+// scores are not stored, so GradeF is never returned.
+func (s *Score) Grade() Grade {
+	var grade Grade
+	switch s.Gamemode {
+	case ModeCatch:
+		grade = accuracyGrade(s.Accuracy(), 0.98, 0.94, 0.9, 0.85)
+	case ModeMania:
+		grade = accuracyGrade(s.Accuracy(), 0.95, 0.9, 0.8, 0.7)
+	default:
+		grade = s.ratioGrade()
+	}
+
+	silver := s.Mods&(ModHidden|ModFlashlight) != 0
+	if s.Gamemode == ModeMania && s.Mods.Has(ModFadeIn) {
+		silver = true
+	}
+	if silver {
+		switch grade {
+		case GradeSS:
+			return GradeSSHidden
+		case GradeS:
+			return GradeSHidden
+		}
+	}
+	return grade
+}
+
+// ratioGrade grades osu!standard and osu!taiko scores by the ratio of 300s
+// and 50s and whether anything was missed.
+func (s *Score) ratioGrade() Grade {
+	totalHits := float64(s.TotalHits())
+	if totalHits == 0 {
+		return GradeSS
+	}
+
+	ratio300 := float64(s.Count300s) / totalHits
+	ratio50 := 0.0
+	if s.Gamemode != ModeTaiko {
+		ratio50 = float64(s.Count50) / totalHits
+	}
+	noMiss := s.CountMiss == 0
+
+	switch {
+	case ratio300 == 1:
+		return GradeSS
+	case ratio300 > 0.9 && ratio50 <= 0.01 && noMiss:
+		return GradeS
+	case ratio300 > 0.8 && noMiss || ratio300 > 0.9:
+		return GradeA
+	case ratio300 > 0.7 && noMiss || ratio300 > 0.8:
+		return GradeB
+	case ratio300 > 0.6:
+		return GradeC
+	default:
+		return GradeD
+	}
+}
+
+// accuracyGrade grades osu!catch and osu!mania scores by their accuracy
+// with the lower bounds of S, A, B and C.
+func accuracyGrade(accuracy, s, a, b, c float64) Grade {
+	switch {
+	case accuracy == 1:
+		return GradeSS
+	case accuracy > s:
+		return GradeS
+	case accuracy > a:
+		return GradeA
+	case accuracy > b:
+		return GradeB
+	case accuracy > c:
+		return GradeC
+	default:
+		return GradeD
+	}
+}
+
+// ComboConsistent reports whether PerfectCombo agrees with MaxCombo given
+// the max combo of the beatmap. osu! stable doesn't break combo on missed
+// slider ends, so perfect scores may stay below the max combo, but they never
+// have misses or exceed it.
+func (s *Score) ComboConsistent(beatmapMaxCombo int) bool {
+	if int(s.MaxCombo) > beatmapMaxCombo {
+		return false
+	}
+	if s.PerfectCombo {
+		return s.CountMiss == 0
+	}
+	return int(s.MaxCombo) < beatmapMaxCombo
+}
+
+// MaxScore estimates the highest ScoreV1 score achievable in osu!standard
+// with mods, including spinner bonus from spinning as fast as osu! allows.
+func (f *OsuFile) MaxScore(mods Mods) int {
+	timeline := NewTimeline(f.TimingPointsFile)
+	multiplier := float64(f.difficultyPeppyStars()) * mods.ScoreMultiplier()
+
+	score := 0
+	combo := 0
+	hit := func(value int, increaseCombo, comboMultiplier bool) {
+		score += value
+		if comboMultiplier {
+			// integer division like osu! stable
+			score += int(float64(max(0, combo-1)) * (float64(value/25) * multiplier))
+		}
+		if increaseCombo {
+			combo++
+		}
+	}
+
+	// spinners need more spins on higher overall difficulty
+	minimumSpinsPerSecond := difficultyRange(f.OverallDifficulty, 3, 5, 7.5)
+	const maximumSpinsPerSecond = 477.0 / 60
+
+	for i := range f.HitObjects {
+		h := &f.HitObjects[i]
+		switch params := h.ObjectParams.(type) {
+		case *Slider:
+			for _, e := range timeline.sliderTiming(f, h, params).NestedObjects() {
+				switch e.Type {
+				case SliderTick:
+					hit(10, true, false)
+				case SliderHead, SliderRepeat, SliderTail:
+					hit(30, true, false)
+				}
+			}
+			hit(300, false, true)
+		case Spinner:
+			seconds := (params.EndTime - h.Time) / 1000
+			halfSpins := int(seconds * maximumSpinsPerSecond * 2)
+			halfSpinsBeforeBonus := int(seconds*minimumSpinsPerSecond) + 3
+
+			for spin := 0; spin <= halfSpins; spin++ {
+				if spin > halfSpinsBeforeBonus && (spin-halfSpinsBeforeBonus)%2 == 0 {
+					hit(1100, false, false)
+				} else if spin > 1 && spin%2 == 0 {
+					hit(100, false, false)
+				}
+			}
+			hit(300, true, true)
+		default:
+			hit(300, true, true)
+		}
+	}
+	return score
+}
+
+// difficultyPeppyStars is the rough difficulty osu! stable scales the combo
+// bonus of ScoreV1 by.
+func (f *OsuFile) difficultyPeppyStars() int {
+	if len(f.HitObjects) == 0 {
+		return 0
+	}
+
+	breakLength := 0
+	for _, b := range f.Breaks() {
+		breakLength += int(math.RoundToEven(b.End)) - int(math.RoundToEven(b.Start))
+	}
+
+	first := int(math.RoundToEven(f.HitObjects[0].Time))
+	last := int(math.RoundToEven(f.HitObjects[len(f.HitObjects)-1].Time))
+	drainLength := (last - first - breakLength) / 1000
+
+	objectDensity := 16.0
+	if drainLength != 0 {
+		objectDensity = clamp(float64(len(f.HitObjects))/float64(drainLength)*8, 0, 16)
+	}
+
+	return int(math.RoundToEven((f.HPDrainRate + f.OverallDifficulty + f.CircleSize + objectDensity) / 38 * 5))
+}
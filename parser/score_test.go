@@ -0,0 +1,70 @@
+package osuParser
+
+import "testing"
+
+func TestScoreGrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		score    Score
+		accuracy float64
+		grade    Grade
+	}{
+		{"standard SS", Score{Count300s: 100}, 1, GradeSS},
+		{"standard SS HD", Score{Count300s: 100, Mods: ModHidden}, 1, GradeSSHidden},
+		{"standard SS FL", Score{Count300s: 100, Mods: ModFlashlight}, 1, GradeSSHidden},
+		{"standard SS FI", Score{Count300s: 100, Mods: ModFadeIn}, 1, GradeSS},
+		{"standard S", Score{Count300s: 95, Count100s: 5}, 0.966667, GradeS},
+		{"standard S HD", Score{Count300s: 95, Count100s: 5, Mods: ModHidden}, 0.966667, GradeSHidden},
+		{"standard A HD", Score{Count300s: 90, Count100s: 10, Mods: ModHidden}, 0.933333, GradeA},
+		{"standard miss", Score{Count300s: 95, Count100s: 4, CountMiss: 1}, 0.963333, GradeA},
+		{"standard 50s", Score{Count300s: 93, Count100s: 2, Count50: 5}, 0.945, GradeA},
+		{"standard D", Score{Count300s: 60, Count100s: 40}, 0.733333, GradeD},
+		{"standard empty", Score{}, 1, GradeSS},
+
+		{"taiko SS HD", Score{Gamemode: ModeTaiko, Count300s: 100, Mods: ModHidden}, 1, GradeSSHidden},
+		{"taiko S", Score{Gamemode: ModeTaiko, Count300s: 96, Count100s: 4, Count50: 5}, 0.98, GradeS},
+		{"taiko A", Score{Gamemode: ModeTaiko, Count300s: 90, Count100s: 10}, 0.95, GradeA},
+
+		{"catch SS FL", Score{Gamemode: ModeCatch, Count300s: 90, Count100s: 5, Count50: 5, Mods: ModFlashlight}, 1, GradeSSHidden},
+		{"catch S", Score{Gamemode: ModeCatch, Count300s: 90, Count100s: 5, Count50: 4, Katus: 1}, 0.99, GradeS},
+		{"catch S HD", Score{Gamemode: ModeCatch, Count300s: 90, Count100s: 5, Count50: 4, Katus: 1, Mods: ModHidden}, 0.99, GradeSHidden},
+		{"catch A", Score{Gamemode: ModeCatch, Count300s: 90, Count100s: 5, Count50: 3, Katus: 2}, 0.98, GradeA},
+
+		{"mania SS FI", Score{Gamemode: ModeMania, Gekis: 100, Mods: ModFadeIn}, 1, GradeSSHidden},
+		{"mania S", Score{Gamemode: ModeMania, Gekis: 60, Count300s: 36, Katus: 3, Count100s: 1}, 0.983333, GradeS},
+		{"mania S FI", Score{Gamemode: ModeMania, Gekis: 60, Count300s: 36, Katus: 3, Count100s: 1, Mods: ModFadeIn}, 0.983333, GradeSHidden},
+		{"mania S HD", Score{Gamemode: ModeMania, Gekis: 60, Count300s: 36, Katus: 3, Count100s: 1, Mods: ModHidden}, 0.983333, GradeSHidden},
+		{"mania A", Score{Gamemode: ModeMania, Gekis: 50, Count300s: 40, Katus: 5, Count100s: 3, Count50: 1, CountMiss: 1}, 0.945, GradeA},
+		{"mania C", Score{Gamemode: ModeMania, Gekis: 60, Katus: 20, Count50: 20}, 0.766667, GradeC},
+	}
+
+	for _, test := range tests {
+		if accuracy := test.score.Accuracy(); !approxEqual(accuracy, test.accuracy) {
+			t.Errorf("%s: accuracy %.6f, want %.6f", test.name, accuracy, test.accuracy)
+		}
+		if grade := test.score.Grade(); grade != test.grade {
+			t.Errorf("%s: grade %v, want %v", test.name, grade, test.grade)
+		}
+	}
+}
+
+func TestScoreComboConsistent(t *testing.T) {
+	tests := []struct {
+		score Score
+		want  bool
+	}{
+		{Score{MaxCombo: 500, PerfectCombo: true}, true},
+		{Score{MaxCombo: 498, PerfectCombo: true}, true},
+		{Score{MaxCombo: 498, PerfectCombo: true, CountMiss: 1}, false},
+		{Score{MaxCombo: 501, PerfectCombo: true}, false},
+		{Score{MaxCombo: 250}, true},
+		{Score{MaxCombo: 500}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.score.ComboConsistent(500); got != test.want {
+			t.Errorf("combo %d, perfect %v, %d misses: got %v, want %v",
+				test.score.MaxCombo, test.score.PerfectCombo, test.score.CountMiss, got, test.want)
+		}
+	}
+}
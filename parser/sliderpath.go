@@ -0,0 +1,431 @@
+package osuParser
+
+import (
+	"math"
+	"sort"
+)
+
+// Path approximation follows osu!'s PathApproximator so that the resulting
+// geometry matches what the game renders and judges.
+const (
+	bezierTolerance      = 0.25
+	catmullDetail        = 50
+	circularArcTolerance = 0.1
+	pathEpsilon          = 1e-3
+)
+
+// SliderPath is the evaluated curve of a slider, cut or extended to the
+// slider's declared pixel length.
+type SliderPath struct {
+	CurveType     CurveType
+	ControlPoints []Point
+
+	path             []Point
+	cumulativeLength []float64
+}
+
+// Path evaluates the slider curve. The head is the position of the hit
+// object the slider belongs to.
+func (s *Slider) Path(head Point) *SliderPath {
+	controlPoints := make([]Point, 0, len(s.Points)+1)
+	controlPoints = append(controlPoints, head)
+	controlPoints = append(controlPoints, s.Points...)
+
+	return NewSliderPath(s.CurveType, controlPoints, s.Length)
+}
+
+// SliderPath evaluates the path of a slider hit object. It returns false for
+// every other kind of hit object.
+func (h *HitObject) SliderPath() (*SliderPath, bool) {
+	slider, ok := h.ObjectParams.(*Slider)
+	if !ok {
+		return nil, false
+	}
+	return slider.Path(h.Position()), true
+}
+
+// NewSliderPath evaluates a curve through absolute control points, including
+// the head. A non-positive expectedDistance keeps the natural curve length.
+func NewSliderPath(curveType CurveType, controlPoints []Point, expectedDistance float64) *SliderPath {
+	p := &SliderPath{
+		CurveType:     curveType,
+		ControlPoints: controlPoints,
+	}
+	p.calculatePath()
+	p.calculateLength(expectedDistance)
+	return p
+}
+
+// Distance returns the length of the path in osu!pixels.
+func (p *SliderPath) Distance() float64 {
+	if len(p.cumulativeLength) == 0 {
+		return 0
+	}
+	return p.cumulativeLength[len(p.cumulativeLength)-1]
+}
+
+// Polyline returns the vertices of the evaluated path.
+func (p *SliderPath) Polyline() []Point {
+	return p.path
+}
+
+// PositionAt returns the position at progress along the path, where 0 is the
+// head and 1 the end of the path.
+func (p *SliderPath) PositionAt(progress float64) Point {
+	d := math.Max(0, math.Min(1, progress)) * p.Distance()
+	return p.interpolateVertices(p.indexOfDistance(d), d)
+}
+
+// PathBetween returns the vertices between two progress values.
+func (p *SliderPath) PathBetween(start, end float64) []Point {
+	d0 := math.Max(0, math.Min(1, start)) * p.Distance()
+	d1 := math.Max(0, math.Min(1, end)) * p.Distance()
+
+	i := p.indexOfDistance(d0)
+	var points []Point
+	points = append(points, p.interpolateVertices(i, d0))
+	for ; i < len(p.path) && p.cumulativeLength[i] <= d1; i++ {
+		points = append(points, p.path[i])
+	}
+	return append(points, p.interpolateVertices(i, d1))
+}
+
+func (p *SliderPath) indexOfDistance(d float64) int {
+	return sort.SearchFloat64s(p.cumulativeLength, d)
+}
+
+func (p *SliderPath) interpolateVertices(i int, d float64) Point {
+	if len(p.path) == 0 {
+		return Point{}
+	}
+	if i <= 0 {
+		return p.path[0]
+	}
+	if i >= len(p.path) {
+		return p.path[len(p.path)-1]
+	}
+
+	p0 := p.path[i-1]
+	p1 := p.path[i]
+	d0 := p.cumulativeLength[i-1]
+	d1 := p.cumulativeLength[i]
+
+	if math.Abs(d0-d1) <= pathEpsilon {
+		return p0
+	}
+
+	w := (d - d0) / (d1 - d0)
+	return p0.Add(p1.Sub(p0).Scale(w))
+}
+
+func (p *SliderPath) calculatePath() {
+	p.path = p.path[:0]
+
+	for _, segment := range p.segments() {
+		for _, v := range approximateSegment(p.segmentType(segment), segment) {
+			if len(p.path) > 0 && p.path[len(p.path)-1] == v {
+				continue
+			}
+			p.path = append(p.path, v)
+		}
+	}
+}
+
+// segments splits the control points at red anchors, which osu! stable
+// encodes as two consecutive identical points in bezier sliders.
+func (p *SliderPath) segments() [][]Point {
+	points := p.ControlPoints
+	if len(points) == 0 {
+		return nil
+	}
+	if p.CurveType != CurveBezier {
+		return [][]Point{points}
+	}
+
+	var segments [][]Point
+	start := 0
+	for i := 1; i < len(points); i++ {
+		if points[i] == points[i-1] {
+			segments = append(segments, points[start:i])
+			start = i
+		}
+	}
+	return append(segments, points[start:])
+}
+
+func (p *SliderPath) segmentType(segment []Point) CurveType {
+	if p.CurveType != CurvePerfect {
+		return p.CurveType
+	}
+	if len(segment) != 3 {
+		return CurveBezier
+	}
+	if isLinear(segment[0], segment[1], segment[2]) {
+		return CurveLinear
+	}
+	return CurvePerfect
+}
+
+func (p *SliderPath) calculateLength(expectedDistance float64) {
+	calculatedLength := 0.0
+	p.cumulativeLength = p.cumulativeLength[:0]
+	p.cumulativeLength = append(p.cumulativeLength, 0)
+
+	for i := 0; i < len(p.path)-1; i++ {
+		calculatedLength += p.path[i+1].Distance(p.path[i])
+		p.cumulativeLength = append(p.cumulativeLength, calculatedLength)
+	}
+
+	if len(p.path) == 0 || expectedDistance <= 0 || calculatedLength == expectedDistance {
+		return
+	}
+
+	// osu! stable does not extend a slider whose last two control points are
+	// equal, the path has them merged already
+	c := len(p.ControlPoints)
+	if c >= 2 && p.ControlPoints[c-1] == p.ControlPoints[c-2] && expectedDistance > calculatedLength {
+		return
+	}
+
+	// the last length is always incorrect
+	p.cumulativeLength = p.cumulativeLength[:len(p.cumulativeLength)-1]
+	pathEndIndex := len(p.path) - 1
+
+	if calculatedLength > expectedDistance {
+		for len(p.cumulativeLength) > 0 && p.cumulativeLength[len(p.cumulativeLength)-1] >= expectedDistance {
+			p.cumulativeLength = p.cumulativeLength[:len(p.cumulativeLength)-1]
+			p.path = p.path[:pathEndIndex]
+			pathEndIndex--
+		}
+	}
+
+	if pathEndIndex <= 0 {
+		p.cumulativeLength = append(p.cumulativeLength, 0)
+		return
+	}
+
+	// shorten or lengthen the last segment along its direction
+	dir := p.path[pathEndIndex].Sub(p.path[pathEndIndex-1]).Normalized()
+	last := p.cumulativeLength[len(p.cumulativeLength)-1]
+	p.path[pathEndIndex] = p.path[pathEndIndex-1].Add(dir.Scale(expectedDistance - last))
+	p.cumulativeLength = append(p.cumulativeLength, expectedDistance)
+}
+
+func approximateSegment(curveType CurveType, points []Point) []Point {
+	switch curveType {
+	case CurveLinear:
+		return append([]Point(nil), points...)
+	case CurvePerfect:
+		return approximateCircularArc(points)
+	case CurveCatmull:
+		return approximateCatmull(points)
+	default:
+		return approximateBezier(points)
+	}
+}
+
+func isLinear(a, b, c Point) bool {
+	return math.Abs((b.Y-a.Y)*(c.X-a.X)-(b.X-a.X)*(c.Y-a.Y)) <= pathEpsilon
+}
+
+func approximateBezier(controlPoints []Point) []Point {
+	count := len(controlPoints)
+	var output []Point
+	if count == 0 {
+		return output
+	}
+
+	subdivisionBuffer1 := make([]Point, count)
+	subdivisionBuffer2 := make([]Point, count*2-1)
+
+	toFlatten := [][]Point{append([]Point(nil), controlPoints...)}
+	var freeBuffers [][]Point
+
+	leftChild := subdivisionBuffer2
+
+	for len(toFlatten) > 0 {
+		parent := toFlatten[len(toFlatten)-1]
+		toFlatten = toFlatten[:len(toFlatten)-1]
+
+		if bezierIsFlatEnough(parent) {
+			output = bezierApproximate(parent, output, subdivisionBuffer1, subdivisionBuffer2, count)
+			freeBuffers = append(freeBuffers, parent)
+			continue
+		}
+
+		var rightChild []Point
+		if len(freeBuffers) > 0 {
+			rightChild = freeBuffers[len(freeBuffers)-1]
+			freeBuffers = freeBuffers[:len(freeBuffers)-1]
+		} else {
+			rightChild = make([]Point, count)
+		}
+		bezierSubdivide(parent, leftChild, rightChild, subdivisionBuffer1, count)
+
+		copy(parent, leftChild[:count])
+
+		toFlatten = append(toFlatten, rightChild, parent)
+	}
+
+	return append(output, controlPoints[count-1])
+}
+
+func bezierIsFlatEnough(controlPoints []Point) bool {
+	for i := 1; i < len(controlPoints)-1; i++ {
+		d := controlPoints[i-1].Sub(controlPoints[i].Scale(2)).Add(controlPoints[i+1])
+		if d.LengthSquared() > bezierTolerance*bezierTolerance*4 {
+			return false
+		}
+	}
+	return true
+}
+
+func bezierSubdivide(controlPoints, l, r, subdivisionBuffer []Point, count int) {
+	midpoints := subdivisionBuffer
+	copy(midpoints, controlPoints[:count])
+
+	for i := 0; i < count; i++ {
+		l[i] = midpoints[0]
+		r[count-i-1] = midpoints[count-i-1]
+
+		for j := 0; j < count-i-1; j++ {
+			midpoints[j] = midpoints[j].Add(midpoints[j+1]).Scale(0.5)
+		}
+	}
+}
+
+func bezierApproximate(controlPoints, output, subdivisionBuffer1, subdivisionBuffer2 []Point, count int) []Point {
+	l := subdivisionBuffer2
+	r := subdivisionBuffer1
+
+	bezierSubdivide(controlPoints, l, r, subdivisionBuffer1, count)
+
+	for i := 0; i < count-1; i++ {
+		l[count+i] = r[i+1]
+	}
+
+	output = append(output, controlPoints[0])
+	for i := 1; i < count-1; i++ {
+		index := 2 * i
+		p := l[index-1].Add(l[index].Scale(2)).Add(l[index+1]).Scale(0.25)
+		output = append(output, p)
+	}
+	return output
+}
+
+func approximateCatmull(controlPoints []Point) []Point {
+	if len(controlPoints) < 2 {
+		return append([]Point(nil), controlPoints...)
+	}
+
+	result := make([]Point, 0, (len(controlPoints)-1)*catmullDetail*2)
+
+	for i := 0; i < len(controlPoints)-1; i++ {
+		v1 := controlPoints[i]
+		if i > 0 {
+			v1 = controlPoints[i-1]
+		}
+		v2 := controlPoints[i]
+		v3 := controlPoints[i+1]
+		v4 := v3.Add(v3).Sub(v2)
+		if i < len(controlPoints)-2 {
+			v4 = controlPoints[i+2]
+		}
+
+		for c := 0; c < catmullDetail; c++ {
+			result = append(result,
+				catmullFindPoint(v1, v2, v3, v4, float64(c)/catmullDetail),
+				catmullFindPoint(v1, v2, v3, v4, float64(c+1)/catmullDetail),
+			)
+		}
+	}
+	return result
+}
+
+func catmullFindPoint(v1, v2, v3, v4 Point, t float64) Point {
+	t2 := t * t
+	t3 := t * t2
+
+	return Point{
+		X: 0.5 * (2*v2.X + (-v1.X+v3.X)*t + (2*v1.X-5*v2.X+4*v3.X-v4.X)*t2 + (-v1.X+3*v2.X-3*v3.X+v4.X)*t3),
+		Y: 0.5 * (2*v2.Y + (-v1.Y+v3.Y)*t + (2*v1.Y-5*v2.Y+4*v3.Y-v4.Y)*t2 + (-v1.Y+3*v2.Y-3*v3.Y+v4.Y)*t3),
+	}
+}
+
+type circularArc struct {
+	valid      bool
+	thetaStart float64
+	thetaRange float64
+	direction  float64
+	radius     float64
+	centre     Point
+}
+
+func newCircularArc(a, b, c Point) circularArc {
+	// degenerate triangles fall back to a bezier curve
+	if isLinear(a, b, c) {
+		return circularArc{}
+	}
+
+	d := 2 * (a.X*(b.Y-c.Y) + b.X*(c.Y-a.Y) + c.X*(a.Y-b.Y))
+	aSq := a.LengthSquared()
+	bSq := b.LengthSquared()
+	cSq := c.LengthSquared()
+
+	centre := Point{
+		X: (aSq*(b.Y-c.Y) + bSq*(c.Y-a.Y) + cSq*(a.Y-b.Y)) / d,
+		Y: (aSq*(c.X-b.X) + bSq*(a.X-c.X) + cSq*(b.X-a.X)) / d,
+	}
+
+	dA := a.Sub(centre)
+	dC := c.Sub(centre)
+
+	arc := circularArc{
+		valid:      true,
+		centre:     centre,
+		radius:     dA.Length(),
+		thetaStart: math.Atan2(dA.Y, dA.X),
+		direction:  1,
+	}
+
+	thetaEnd := math.Atan2(dC.Y, dC.X)
+	for thetaEnd < arc.thetaStart {
+		thetaEnd += 2 * math.Pi
+	}
+	arc.thetaRange = thetaEnd - arc.thetaStart
+
+	// draw the circle in the direction of the side of AC that B lies on
+	orthoAtoC := Point{X: c.Y - a.Y, Y: -(c.X - a.X)}
+	if orthoAtoC.Dot(b.Sub(a)) < 0 {
+		arc.direction = -arc.direction
+		arc.thetaRange = 2*math.Pi - arc.thetaRange
+	}
+
+	return arc
+}
+
+func approximateCircularArc(controlPoints []Point) []Point {
+	if len(controlPoints) != 3 {
+		return approximateBezier(controlPoints)
+	}
+
+	arc := newCircularArc(controlPoints[0], controlPoints[1], controlPoints[2])
+	if !arc.valid {
+		return approximateBezier(controlPoints)
+	}
+
+	// the number of points keeps the discrete curvature below the tolerance
+	amountPoints := 2
+	if 2*arc.radius > circularArcTolerance {
+		amountPoints = max(2, int(math.Ceil(arc.thetaRange/(2*math.Acos(1-circularArcTolerance/arc.radius)))))
+	}
+
+	output := make([]Point, 0, amountPoints)
+	for i := 0; i < amountPoints; i++ {
+		fract := float64(i) / float64(amountPoints-1)
+		theta := arc.thetaStart + arc.direction*fract*arc.thetaRange
+		o := Point{X: math.Cos(theta), Y: math.Sin(theta)}.Scale(arc.radius)
+		output = append(output, arc.centre.Add(o))
+	}
+	return output
+}
@@ -0,0 +1,70 @@
+package osuParser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSliderPathEqualLastPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		curve    CurveType
+		points   []Point
+		expected float64
+		distance float64
+	}{
+		{"linear extended", CurveLinear, []Point{{0, 0}, {100, 0}}, 150, 150},
+		{"linear shortened", CurveLinear, []Point{{0, 0}, {100, 0}}, 50, 50},
+		{"linear equal last points", CurveLinear, []Point{{0, 0}, {100, 0}, {100, 0}}, 150, 100},
+		{"linear equal last points shortened", CurveLinear, []Point{{0, 0}, {100, 0}, {100, 0}}, 50, 50},
+		{"bezier equal last points", CurveBezier, []Point{{0, 0}, {50, 0}, {100, 0}, {100, 0}}, 150, 100},
+	}
+
+	for _, test := range tests {
+		p := NewSliderPath(test.curve, test.points, test.expected)
+		if !approxEqual(p.Distance(), test.distance) {
+			t.Errorf("%s: distance %v, want %v", test.name, p.Distance(), test.distance)
+		}
+		if end := p.PositionAt(1); !approxEqual(end.X, test.distance) || end.Y != 0 {
+			t.Errorf("%s: ends at %v, want (%v, 0)", test.name, end, test.distance)
+		}
+	}
+}
+
+func TestSliderPathLength(t *testing.T) {
+	// the lengths of the curves themselves, the polylines osu! approximates
+	// them with are a little shorter
+	tests := []struct {
+		name   string
+		curve  CurveType
+		points []Point
+		length float64
+		middle Point
+	}{
+		{"linear", CurveLinear, []Point{{0, 0}, {100, 0}, {100, 100}}, 200, Point{100, 0}},
+		{"perfect", CurvePerfect, []Point{{0, 0}, {50, 50}, {100, 0}}, 50 * math.Pi, Point{50, 50}},
+		{"perfect on a line", CurvePerfect, []Point{{0, 0}, {50, 0}, {100, 0}}, 100, Point{50, 0}},
+		{"perfect of four points", CurvePerfect, []Point{{0, 0}, {50, 100}, {100, 0}, {150, 100}}, 194.0422, Point{75, 50}},
+		{"bezier", CurveBezier, []Point{{0, 0}, {50, 100}, {100, 0}}, 147.8942, Point{50, 50}},
+		{"bezier red anchor", CurveBezier, []Point{{0, 0}, {50, 100}, {100, 0}, {100, 0}, {200, 0}}, 247.8942, Point{88.1965, 20.8205}},
+		{"catmull on a line", CurveCatmull, []Point{{0, 0}, {100, 0}, {200, 0}}, 200, Point{100, 0}},
+		{"catmull", CurveCatmull, []Point{{0, 0}, {100, 100}, {200, 0}}, 288.7787, Point{100.0698, 99.9972}},
+	}
+
+	for _, test := range tests {
+		p := NewSliderPath(test.curve, test.points, 0)
+		if math.Abs(p.Distance()-test.length) > 0.15 {
+			t.Errorf("%s: length %.4f, want %.4f", test.name, p.Distance(), test.length)
+		}
+		if middle := p.PositionAt(0.5); middle.Distance(test.middle) > 0.05 {
+			t.Errorf("%s: middle at %v, want %v", test.name, middle, test.middle)
+		}
+
+		// the declared length cuts the path short
+		cut := NewSliderPath(test.curve, test.points, test.length/2)
+		if !approxEqual(cut.Distance(), test.length/2) || cut.PositionAt(1).Distance(test.middle) > 0.1 {
+			t.Errorf("%s: cut to %.4f ending at %v, want %.4f ending at %v",
+				test.name, cut.Distance(), cut.PositionAt(1), test.length/2, test.middle)
+		}
+	}
+}
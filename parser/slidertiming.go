@@ -0,0 +1,242 @@
+package osuParser
+
+import (
+	"math"
+)
+
+const (
+	// sliderBaseScoringDistance is the distance in osu!pixels a slider
+	// travels per beat at a slider multiplier and velocity of 1.
+	sliderBaseScoringDistance = 100
+
+	// sliderTailLeniency moves the legacy last tick this many milliseconds
+	// before the end of the slider.
+	sliderTailLeniency = 36
+
+	// sliderMaxTickLength caps the path length ticks are generated for.
+	sliderMaxTickLength = 100000
+)
+
+type SliderEventType int
+
+const (
+	SliderHead SliderEventType = iota
+	SliderTick
+	SliderRepeat
+	SliderLegacyLastTick
+	SliderTail
+)
+
+// SliderEvent is a nested object of a slider.
+type SliderEvent struct {
+	Type          SliderEventType
+	SpanIndex     int
+	SpanStartTime float64
+	Time          float64
+	PathProgress  float64
+	Position      Point
+}
+
+// SliderTiming is a slider resolved against the timing points and
+// difficulty of its beatmap.
+type SliderTiming struct {
+	StartTime    float64
+	Slides       int
+	Velocity     float64
+	TickDistance float64
+	SpanDuration float64
+	Duration     float64
+	EndTime      float64
+	Path         *SliderPath
+}
+
+// SliderTiming resolves the timing of a slider hit object. It returns false
+// for every other kind of hit object. It indexes the timing points on every
+// call, use Timeline.SliderTiming for many objects.
+func (f *OsuFile) SliderTiming(h *HitObject) (*SliderTiming, bool) {
+	return NewTimeline(f.TimingPointsFile).SliderTiming(f, h)
+}
+
+// SliderTiming resolves the timing of a slider hit object of f, which the
+// timeline was built from. It returns false for every other kind of hit
+// object.
+func (t *Timeline) SliderTiming(f *OsuFile, h *HitObject) (*SliderTiming, bool) {
+	slider, ok := h.ObjectParams.(*Slider)
+	if !ok {
+		return nil, false
+	}
+	return t.sliderTiming(f, h, slider), true
+}
+
+func (t *Timeline) sliderTiming(f *OsuFile, h *HitObject, slider *Slider) *SliderTiming {
+	beatLength := t.BeatLengthAt(h.Time)
+	velocityMultiplier := t.DifficultyAt(h.Time)
+
+	scoringDistance := sliderBaseScoringDistance * f.SliderMultiplier * velocityMultiplier
+
+	tickDistanceMultiplier := 1.0
+	if f.Version > 0 && f.Version < 8 {
+		tickDistanceMultiplier = 1 / velocityMultiplier
+	}
+
+	timing := &SliderTiming{
+		StartTime: h.Time,
+		Slides:    max(slider.Slides, 1),
+		Velocity:  scoringDistance / beatLength,
+		Path:      slider.Path(h.Position()),
+	}
+	if f.SliderTickRate > 0 {
+		timing.TickDistance = scoringDistance / f.SliderTickRate * tickDistanceMultiplier
+	}
+	if timing.Velocity > 0 {
+		timing.SpanDuration = timing.Path.Distance() / timing.Velocity
+	}
+	timing.Duration = float64(timing.Slides) * timing.SpanDuration
+	timing.EndTime = timing.StartTime + timing.Duration
+
+	return timing
+}
+
+// NestedObjects generates the head, ticks, repeats, legacy last tick and
+// tail of the slider in chronological order.
+func (s *SliderTiming) NestedObjects() []SliderEvent {
+	length := math.Min(sliderMaxTickLength, s.Path.Distance())
+	tickDistance := math.Max(0, math.Min(s.TickDistance, length))
+	minDistanceFromEnd := s.Velocity * 10
+
+	events := []SliderEvent{{
+		Type:          SliderHead,
+		SpanStartTime: s.StartTime,
+		Time:          s.StartTime,
+		PathProgress:  0,
+	}}
+
+	if tickDistance != 0 {
+		for span := 0; span < s.Slides; span++ {
+			spanStartTime := s.StartTime + float64(span)*s.SpanDuration
+			reversed := span%2 == 1
+
+			var ticks []SliderEvent
+			for d := tickDistance; d <= length; d += tickDistance {
+				if d >= length-minDistanceFromEnd {
+					break
+				}
+
+				// ticks are always placed from the start of the path so that
+				// ticks of repeat spans line up with the first span
+				pathProgress := d / length
+				timeProgress := pathProgress
+				if reversed {
+					timeProgress = 1 - pathProgress
+				}
+
+				ticks = append(ticks, SliderEvent{
+					Type:          SliderTick,
+					SpanIndex:     span,
+					SpanStartTime: spanStartTime,
+					Time:          spanStartTime + timeProgress*s.SpanDuration,
+					PathProgress:  pathProgress,
+				})
+			}
+			if reversed {
+				for i, j := 0, len(ticks)-1; i < j; i, j = i+1, j-1 {
+					ticks[i], ticks[j] = ticks[j], ticks[i]
+				}
+			}
+			events = append(events, ticks...)
+
+			if span < s.Slides-1 {
+				events = append(events, SliderEvent{
+					Type:          SliderRepeat,
+					SpanIndex:     span,
+					SpanStartTime: spanStartTime,
+					Time:          spanStartTime + s.SpanDuration,
+					PathProgress:  float64((span + 1) % 2),
+				})
+			}
+		}
+	}
+
+	finalSpanIndex := s.Slides - 1
+	finalSpanStartTime := s.StartTime + float64(finalSpanIndex)*s.SpanDuration
+
+	legacyLastTickTime := math.Max(s.StartTime+s.Duration/2, finalSpanStartTime+s.SpanDuration-sliderTailLeniency)
+	legacyLastTickProgress := 0.0
+	if s.SpanDuration > 0 {
+		legacyLastTickProgress = (legacyLastTickTime - finalSpanStartTime) / s.SpanDuration
+	}
+	if s.Slides%2 == 0 {
+		legacyLastTickProgress = 1 - legacyLastTickProgress
+	}
+
+	events = append(events,
+		SliderEvent{
+			Type:          SliderLegacyLastTick,
+			SpanIndex:     finalSpanIndex,
+			SpanStartTime: finalSpanStartTime,
+			Time:          legacyLastTickTime,
+			PathProgress:  legacyLastTickProgress,
+		},
+		SliderEvent{
+			Type:          SliderTail,
+			SpanIndex:     finalSpanIndex,
+			SpanStartTime: finalSpanStartTime,
+			Time:          s.StartTime + s.Duration,
+			PathProgress:  float64(s.Slides % 2),
+		},
+	)
+
+	for i := range events {
+		events[i].Position = s.Path.PositionAt(events[i].PathProgress)
+	}
+	return events
+}
+
+// Combo returns the combo the slider awards: head, ticks, repeats and end.
+func (s *SliderTiming) Combo() int {
+	combo := 0
+	for _, e := range s.NestedObjects() {
+		if e.Type != SliderLegacyLastTick {
+			combo++
+		}
+	}
+	return combo
+}
+
+// EndTime returns the time a hit object ends at, resolving slider durations
+// against the timing points of the beatmap. It indexes the timing points on
+// every call, use Timeline.HitObjectEndTime for many objects.
+func (f *OsuFile) EndTime(h *HitObject) float64 {
+	return NewTimeline(f.TimingPointsFile).HitObjectEndTime(f, h)
+}
+
+// HitObjectEndTime returns the time a hit object of f, which the timeline was
+// built from, ends at.
+func (t *Timeline) HitObjectEndTime(f *OsuFile, h *HitObject) float64 {
+	switch params := h.ObjectParams.(type) {
+	case *Slider:
+		return t.sliderTiming(f, h, params).EndTime
+	case Spinner:
+		return params.EndTime
+	case Hold:
+		return params.EndTime
+	default:
+		return h.Time
+	}
+}
+
+// MaxCombo returns the maximum combo achievable in osu!standard.
+func (f *OsuFile) MaxCombo() int {
+	timeline := NewTimeline(f.TimingPointsFile)
+
+	combo := 0
+	for i := range f.HitObjects {
+		h := &f.HitObjects[i]
+		if slider, ok := h.ObjectParams.(*Slider); ok {
+			combo += timeline.sliderTiming(f, h, slider).Combo()
+		} else {
+			combo++
+		}
+	}
+	return combo
+}
@@ -0,0 +1,81 @@
+package osuParser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSliderNestedObjects(t *testing.T) {
+	// 120 BPM at a slider multiplier of 1.4 and tick rate of 2: a span of
+	// 280 osu!pixels lasts a second with ticks every 70 osu!pixels, and half
+	// as long at 0.5x from the inherited point
+	const osu = "osu file format v14\r\n\r\n" +
+		"[Difficulty]\r\nSliderMultiplier:1.4\r\nSliderTickRate:2\r\n\r\n" +
+		"[TimingPoints]\r\n0,500,4,1,0,100,1,0\r\n10000,-200,4,1,0,100,0,0\r\n\r\n" +
+		"[HitObjects]\r\n" +
+		"0,0,1000,2,0,L|280:0,3,280\r\n" +
+		"0,0,10000,2,0,L|140:0,2,140\r\n"
+
+	f, err := DecodeOsuFileWithOptions(strings.NewReader(osu), ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		Type     SliderEventType
+		Time     float64
+		Progress float64
+	}
+	tests := []struct {
+		endTime float64
+		combo   int
+		events  []event
+	}{
+		{4000, 13, []event{
+			{SliderHead, 1000, 0},
+			{SliderTick, 1250, 0.25}, {SliderTick, 1500, 0.5}, {SliderTick, 1750, 0.75},
+			{SliderRepeat, 2000, 1},
+			{SliderTick, 2250, 0.75}, {SliderTick, 2500, 0.5}, {SliderTick, 2750, 0.25},
+			{SliderRepeat, 3000, 0},
+			{SliderTick, 3250, 0.25}, {SliderTick, 3500, 0.5}, {SliderTick, 3750, 0.75},
+			{SliderLegacyLastTick, 3964, 0.964},
+			{SliderTail, 4000, 1},
+		}},
+		{12000, 9, []event{
+			{SliderHead, 10000, 0},
+			{SliderTick, 10250, 0.25}, {SliderTick, 10500, 0.5}, {SliderTick, 10750, 0.75},
+			{SliderRepeat, 11000, 1},
+			{SliderTick, 11250, 0.75}, {SliderTick, 11500, 0.5}, {SliderTick, 11750, 0.25},
+			{SliderLegacyLastTick, 11964, 0.036},
+			{SliderTail, 12000, 0},
+		}},
+	}
+
+	timeline := NewTimeline(f.TimingPointsFile)
+	for i, test := range tests {
+		h := &f.HitObjects[i]
+		timing, ok := timeline.SliderTiming(f, h)
+		if !ok {
+			t.Fatalf("object %d is not a slider", i)
+		}
+		if !approxEqual(timing.EndTime, test.endTime) || timing.Combo() != test.combo {
+			t.Errorf("slider %d: ends at %v with %d combo, want %v with %d", i, timing.EndTime, timing.Combo(), test.endTime, test.combo)
+		}
+
+		events := timing.NestedObjects()
+		if len(events) != len(test.events) {
+			t.Errorf("slider %d: got %d nested objects %+v, want %d", i, len(events), events, len(test.events))
+			continue
+		}
+		for j, e := range events {
+			want := test.events[j]
+			if e.Type != want.Type || !approxEqual(e.Time, want.Time) || !approxEqual(e.PathProgress, want.Progress) {
+				t.Errorf("slider %d: nested object %d is %v at %v and %v, want %v at %v and %v",
+					i, j, e.Type, e.Time, e.PathProgress, want.Type, want.Time, want.Progress)
+			}
+			if x := h.X + want.Progress*h.ObjectParams.(*Slider).Points[0].X; !approxEqual(e.Position.X, x) || e.Position.Y != 0 {
+				t.Errorf("slider %d: nested object %d at %v, want (%v, 0)", i, j, e.Position, x)
+			}
+		}
+	}
+}
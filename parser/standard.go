@@ -0,0 +1,1044 @@
+package osuParser
+
+import (
+	"math"
+	"slices"
+)
+
+// StandardDifficulty are the difficulty attributes of a beatmap in
+// osu!standard, as calculated by the osu! difficulty algorithm.
+type StandardDifficulty struct {
+	Mods                 Mods
+	StarRating           float64
+	AimDifficulty        float64
+	SpeedDifficulty      float64
+	SpeedNoteCount       float64
+	FlashlightDifficulty float64
+	SliderFactor         float64
+	ApproachRate         float64
+	OverallDifficulty    float64
+	DrainRate            float64
+	MaxCombo             int
+	HitCircleCount       int
+	SliderCount          int
+	SpinnerCount         int
+}
+
+const (
+	standardDifficultyMultiplier = 0.0675
+
+	// standardPerformanceMultiplier keeps star ratings in line with pp.
+	standardPerformanceMultiplier = 1.14
+
+	normalisedRadius    = 50.0
+	minDeltaTime        = 25.0
+	maximumSliderRadius = normalisedRadius * 2.4
+	assumedSliderRadius = normalisedRadius * 1.8
+
+	stackDistance = 3
+)
+
+// standardObject is a hit object prepared for the difficulty calculation.
+type standardObject struct {
+	isSlider  bool
+	isSpinner bool
+
+	startTime   float64
+	endTime     float64
+	position    Point
+	endPosition Point
+	stackHeight int
+	scale       float64
+	radius      float64
+	preempt     float64
+	fadeIn      float64
+	hitWindow   float64
+
+	slider *SliderTiming
+	nested []SliderEvent
+
+	lazyComputed       bool
+	lazyEndPosition    Point
+	lazyTravelDistance float64
+	lazyTravelTime     float64
+}
+
+func (o *standardObject) stackOffset() Point {
+	offset := float64(o.stackHeight) * o.scale * -6.4
+	return Point{X: offset, Y: offset}
+}
+
+func (o *standardObject) stackedPosition() Point {
+	return o.position.Add(o.stackOffset())
+}
+
+func (o *standardObject) stackedEndPosition() Point {
+	return o.endPosition.Add(o.stackOffset())
+}
+
+// computeSliderCursorPosition follows the lazy cursor through the slider,
+// which only moves once a nested object leaves the follow circle.
+func (o *standardObject) computeSliderCursorPosition() {
+	if o.lazyComputed {
+		return
+	}
+	o.lazyComputed = true
+
+	s := o.slider
+	trackingEndTime := math.Max(s.StartTime+s.Duration-sliderTailLeniency, s.StartTime+s.Duration/2)
+
+	nested := o.nested
+	lastTick := -1
+	for i, e := range nested {
+		if e.Type == SliderTick {
+			lastTick = i
+		}
+	}
+	if lastTick >= 0 && nested[lastTick].Time > trackingEndTime {
+		trackingEndTime = nested[lastTick].Time
+
+		// osu! moves the last tick to the end in this case, which is kept to
+		// give the same results
+		reordered := slices.Clone(nested)
+		reordered = append(slices.Delete(reordered, lastTick, lastTick+1), nested[lastTick])
+		nested = reordered
+	}
+
+	o.lazyTravelTime = trackingEndTime - s.StartTime
+
+	endTimeMin := o.lazyTravelTime / s.SpanDuration
+	if math.Mod(endTimeMin, 2) >= 1 {
+		endTimeMin = 1 - math.Mod(endTimeMin, 1)
+	} else {
+		endTimeMin = math.Mod(endTimeMin, 1)
+	}
+	o.lazyEndPosition = s.Path.PositionAt(endTimeMin).Add(o.stackOffset())
+
+	cursor := o.stackedPosition()
+	scalingFactor := normalisedRadius / o.radius
+
+	for i := 1; i < len(nested); i++ {
+		movement := nested[i].Position.Add(o.stackOffset()).Sub(cursor)
+		movementLength := scalingFactor * movement.Length()
+
+		// amount of movement required so that the cursor position needs to
+		// be updated
+		requiredMovement := assumedSliderRadius
+
+		if i == len(nested)-1 {
+			// the player takes the shorter movement of the lazy end and the
+			// actual end of the slider
+			lazyMovement := o.lazyEndPosition.Sub(cursor)
+			if lazyMovement.Length() < movement.Length() {
+				movement = lazyMovement
+			}
+			movementLength = scalingFactor * movement.Length()
+		} else if nested[i].Type == SliderRepeat {
+			requiredMovement = normalisedRadius
+		}
+
+		if movementLength > requiredMovement {
+			cursor = cursor.Add(movement.Scale((movementLength - requiredMovement) / movementLength))
+			movementLength *= (movementLength - requiredMovement) / movementLength
+			o.lazyTravelDistance += movementLength
+		}
+
+		if i == len(nested)-1 {
+			o.lazyEndPosition = cursor
+		}
+	}
+}
+
+func (o *standardObject) endCursorPosition() Point {
+	if o.isSlider {
+		o.computeSliderCursorPosition()
+		return o.lazyEndPosition
+	}
+	return o.stackedPosition()
+}
+
+// opacityAt returns how visible the object is at time.
+func (o *standardObject) opacityAt(time float64, hidden bool) float64 {
+	if time > o.startTime {
+		return 0
+	}
+
+	fadeInStartTime := o.startTime - o.preempt
+	fadeIn := clamp((time-fadeInStartTime)/o.fadeIn, 0, 1)
+
+	if hidden {
+		fadeOutStartTime := o.startTime - o.preempt + o.fadeIn
+		fadeOutDuration := o.preempt * 0.3
+		return math.Min(fadeIn, 1-clamp((time-fadeOutStartTime)/fadeOutDuration, 0, 1))
+	}
+	return fadeIn
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+// standardObjects prepares the hit objects with mods applied and stacking
+// calculated.
+func (f *OsuFile) standardObjects(mods Mods) []*standardObject {
+	attributes := f.Difficulty.Attributes(ModeStandard, mods)
+	timeline := NewTimeline(f.TimingPointsFile)
+
+	scale := (1 - 0.7*(attributes.CircleSize-5)/5) / 2
+	// attributes are in real time, objects in beatmap time
+	preempt := attributes.Preempt * mods.ClockRate()
+	fadeIn := 400 * math.Min(1, preempt/450)
+	if mods.Has(ModHidden) {
+		fadeIn = preempt * 0.4
+	}
+	hitWindow := attributes.HitWindows.Great * mods.ClockRate()
+
+	objects := make([]*standardObject, 0, len(f.HitObjects))
+	for i := range f.HitObjects {
+		h := &f.HitObjects[i]
+		if mods.Has(ModHardRock) {
+			h = flipVertically(h)
+		}
+		o := &standardObject{
+			startTime:   h.Time,
+			endTime:     h.Time,
+			position:    h.Position(),
+			endPosition: h.Position(),
+			scale:       scale,
+			radius:      64 * scale,
+			preempt:     preempt,
+			fadeIn:      fadeIn,
+			hitWindow:   hitWindow,
+		}
+
+		switch params := h.ObjectParams.(type) {
+		case *Slider:
+			o.isSlider = true
+			o.slider = timeline.sliderTiming(f, h, params)
+			o.endTime = o.slider.EndTime
+			o.endPosition = o.slider.Path.PositionAt(float64(o.slider.Slides % 2))
+			for _, e := range o.slider.NestedObjects() {
+				if e.Type != SliderLegacyLastTick {
+					o.nested = append(o.nested, e)
+				}
+			}
+		case Spinner:
+			o.isSpinner = true
+			o.endTime = params.EndTime
+			o.hitWindow = 0
+		}
+
+		objects = append(objects, o)
+	}
+
+	if f.Version >= 6 || f.Version == 0 {
+		applyStacking(objects, f.StackLeniency)
+	} else {
+		applyStackingOld(objects, f.StackLeniency)
+	}
+
+	return objects
+}
+
+// flipVertically returns a copy of the hit object reflected along the
+// playfield like hard rock does, before the stacking is calculated.
+func flipVertically(h *HitObject) *HitObject {
+	flipped := *h
+	flipped.Y = 384 - h.Y
+	if slider, ok := h.ObjectParams.(*Slider); ok {
+		s := *slider
+		s.Points = make([]Point, len(slider.Points))
+		for i, p := range slider.Points {
+			s.Points[i] = Point{X: p.X, Y: 384 - p.Y}
+		}
+		flipped.ObjectParams = &s
+	}
+	return &flipped
+}
+
+func applyStacking(objects []*standardObject, stackLeniency float64) {
+	if len(objects) == 0 {
+		return
+	}
+
+	startIndex := 0
+	endIndex := len(objects) - 1
+
+	// reverse pass for stack calculation
+	for i := endIndex; i > startIndex; i-- {
+		n := i
+
+		// every object which has not yet got a stack is checked, so two
+		// interwound stacks are handled one after the other
+		objectI := objects[i]
+		if objectI.stackHeight != 0 || objectI.isSpinner {
+			continue
+		}
+
+		stackThreshold := objectI.preempt * stackLeniency
+
+		if !objectI.isSlider {
+			// either a stack of hit circles only, or hit circles that are
+			// underneath a slider
+			for n--; n >= 0; n-- {
+				objectN := objects[n]
+				if objectN.isSpinner {
+					continue
+				}
+
+				if objectI.startTime-objectN.endTime > stackThreshold {
+					break
+				}
+
+				// hit circles under the last slider of a stack are moved down
+				// and right
+				if objectN.isSlider && objectN.endPosition.Distance(objectI.position) < stackDistance {
+					offset := objectI.stackHeight - objectN.stackHeight + 1
+					for j := n + 1; j <= i; j++ {
+						if objectN.endPosition.Distance(objects[j].position) < stackDistance {
+							objects[j].stackHeight -= offset
+						}
+					}
+					// the slider is handled as the base of a new stack by the
+					// outer loop
+					break
+				}
+
+				if objectN.position.Distance(objectI.position) < stackDistance {
+					objectN.stackHeight = objectI.stackHeight + 1
+					objectI = objectN
+				}
+			}
+		} else {
+			// from the first slider of a stack on, stacks are always positive
+			for n--; n >= startIndex; n-- {
+				objectN := objects[n]
+				if objectN.isSpinner {
+					continue
+				}
+
+				if objectI.startTime-objectN.startTime > stackThreshold {
+					break
+				}
+
+				if objectN.endPosition.Distance(objectI.position) < stackDistance {
+					objectN.stackHeight = objectI.stackHeight + 1
+					objectI = objectN
+				}
+			}
+		}
+	}
+}
+
+// applyStackingOld is the stacking used by beatmaps before file format v6.
+func applyStackingOld(objects []*standardObject, stackLeniency float64) {
+	for i, current := range objects {
+		if current.stackHeight != 0 && !current.isSlider {
+			continue
+		}
+
+		startTime := current.endTime
+		sliderStack := 0
+
+		for j := i + 1; j < len(objects); j++ {
+			stackThreshold := objects[i].preempt * stackLeniency
+
+			if objects[j].startTime-stackThreshold > startTime {
+				break
+			}
+
+			position2 := current.position
+			if current.isSlider {
+				position2 = current.slider.Path.PositionAt(1)
+			}
+
+			if objects[j].position.Distance(current.position) < stackDistance {
+				current.stackHeight++
+				startTime = objects[j].startTime
+			} else if objects[j].position.Distance(position2) < stackDistance {
+				// sliders bump notes down and right, rather than up and left
+				sliderStack++
+				objects[j].stackHeight -= sliderStack
+				startTime = objects[j].startTime
+			}
+		}
+	}
+}
+
+// standardDifficultyObject describes the movement from the previous hit
+// object to the current one.
+type standardDifficultyObject struct {
+	index   int
+	objects []*standardDifficultyObject
+
+	object   *standardObject
+	last     *standardObject
+	lastLast *standardObject
+
+	startTime  float64
+	deltaTime  float64
+	strainTime float64
+
+	lazyJumpDistance    float64
+	minimumJumpDistance float64
+	minimumJumpTime     float64
+	travelDistance      float64
+	travelTime          float64
+	angle               float64
+	hasAngle            bool
+	hitWindowGreat      float64
+}
+
+func (d *standardDifficultyObject) previous(backwardsIndex int) *standardDifficultyObject {
+	i := d.index - (backwardsIndex + 1)
+	if i < 0 || i >= len(d.objects) {
+		return nil
+	}
+	return d.objects[i]
+}
+
+func (d *standardDifficultyObject) next(forwardsIndex int) *standardDifficultyObject {
+	i := d.index + forwardsIndex + 1
+	if i < 0 || i >= len(d.objects) {
+		return nil
+	}
+	return d.objects[i]
+}
+
+func newStandardDifficultyObjects(objects []*standardObject, clockRate float64) []*standardDifficultyObject {
+	var difficultyObjects []*standardDifficultyObject
+
+	// the first jump is formed by the first two hit objects of the map
+	for i := 1; i < len(objects); i++ {
+		var lastLast *standardObject
+		if i > 1 {
+			lastLast = objects[i-2]
+		}
+
+		d := &standardDifficultyObject{
+			index:    len(difficultyObjects),
+			object:   objects[i],
+			last:     objects[i-1],
+			lastLast: lastLast,
+		}
+		d.startTime = d.object.startTime / clockRate
+		d.deltaTime = (d.object.startTime - d.last.startTime) / clockRate
+
+		// capped to prevent simultaneous objects from breaking the calculation
+		d.strainTime = math.Max(d.deltaTime, minDeltaTime)
+		d.hitWindowGreat = 2 * d.object.hitWindow / clockRate
+
+		d.setDistances(clockRate)
+
+		difficultyObjects = append(difficultyObjects, d)
+	}
+
+	for _, d := range difficultyObjects {
+		d.objects = difficultyObjects
+	}
+	return difficultyObjects
+}
+
+func (d *standardDifficultyObject) setDistances(clockRate float64) {
+	o := d.object
+
+	if o.isSlider {
+		o.computeSliderCursorPosition()
+		// bonus for repeat sliders until a better per nested object strain
+		// system can be achieved
+		d.travelDistance = o.lazyTravelDistance * math.Pow(1+float64(o.slider.Slides-1)/2.5, 1/2.5)
+		d.travelTime = math.Max(o.lazyTravelTime/clockRate, minDeltaTime)
+	}
+
+	// angle and distance are not needed when a spinner is involved
+	if o.isSpinner || d.last.isSpinner {
+		return
+	}
+
+	// distances are scaled to a uniform circle size
+	scalingFactor := normalisedRadius / o.radius
+	if o.radius < 30 {
+		smallCircleBonus := math.Min(30-o.radius, 5) / 50
+		scalingFactor *= 1 + smallCircleBonus
+	}
+
+	lastCursorPosition := d.last.endCursorPosition()
+
+	d.lazyJumpDistance = o.stackedPosition().Scale(scalingFactor).Sub(lastCursorPosition.Scale(scalingFactor)).Length()
+	d.minimumJumpTime = d.strainTime
+	d.minimumJumpDistance = d.lazyJumpDistance
+
+	if d.last.isSlider {
+		lastTravelTime := math.Max(d.last.lazyTravelTime/clockRate, minDeltaTime)
+		d.minimumJumpTime = math.Max(d.strainTime-lastTravelTime, minDeltaTime)
+
+		// the player either follows the slider to its lazy end and jumps
+		// from there, or jumps straight from the tail, whichever is shorter
+		tailJumpDistance := d.last.stackedEndPosition().Sub(o.stackedPosition()).Length() * scalingFactor
+		d.minimumJumpDistance = math.Max(0, math.Min(d.lazyJumpDistance-(maximumSliderRadius-assumedSliderRadius), tailJumpDistance-maximumSliderRadius))
+	}
+
+	if d.lastLast != nil && !d.lastLast.isSpinner {
+		lastLastCursorPosition := d.lastLast.endCursorPosition()
+
+		v1 := lastLastCursorPosition.Sub(d.last.stackedPosition())
+		v2 := o.stackedPosition().Sub(lastCursorPosition)
+
+		dot := v1.Dot(v2)
+		det := v1.X*v2.Y - v1.Y*v2.X
+
+		d.angle = math.Abs(math.Atan2(det, dot))
+		d.hasAngle = true
+	}
+}
+
+// standardSkill is a skill whose strain peaks decide part of the star
+// rating.
+type standardSkill interface {
+	process(d *standardDifficultyObject)
+	difficultyValue() float64
+}
+
+// standardStrainDifficulty weighs the strain peaks, reducing the highest
+// ones first to account for extreme difficulty spikes.
+func standardStrainDifficulty(peaks []float64, reducedSectionCount int, difficultyMultiplier float64) float64 {
+	const reducedStrainBaseline = 0.75
+
+	strains := make([]float64, 0, len(peaks))
+	for _, peak := range peaks {
+		if peak > 0 {
+			strains = append(strains, peak)
+		}
+	}
+	slices.Sort(strains)
+	slices.Reverse(strains)
+
+	for i := 0; i < min(len(strains), reducedSectionCount); i++ {
+		scale := math.Log10(lerp(1, 10, clamp(float64(i)/float64(reducedSectionCount), 0, 1)))
+		strains[i] *= lerp(reducedStrainBaseline, 1, scale)
+	}
+
+	return weightedSum(strains, 0.9) * difficultyMultiplier
+}
+
+type aimSkill struct {
+	strainPeaks
+	withSliders   bool
+	currentStrain float64
+}
+
+func (s *aimSkill) process(d *standardDifficultyObject) {
+	const skillMultiplier = 23.55
+	const strainDecayBase = 0.15
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += evaluateAim(d, s.withSliders) * skillMultiplier
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-d.previous(0).startTime)
+	})
+}
+
+func (s *aimSkill) difficultyValue() float64 {
+	return standardStrainDifficulty(s.all(), 10, 1.06)
+}
+
+func calcWideAngleBonus(angle float64) float64 {
+	return math.Pow(math.Sin(3.0/4*(math.Min(5.0/6*math.Pi, math.Max(math.Pi/6, angle))-math.Pi/6)), 2)
+}
+
+func calcAcuteAngleBonus(angle float64) float64 {
+	return 1 - calcWideAngleBonus(angle)
+}
+
+func evaluateAim(d *standardDifficultyObject, withSliderTravelDistance bool) float64 {
+	const wideAngleMultiplier = 1.5
+	const acuteAngleMultiplier = 1.95
+	const sliderMultiplier = 1.35
+	const velocityChangeMultiplier = 0.75
+
+	if d.object.isSpinner || d.index <= 1 || d.previous(0).object.isSpinner {
+		return 0
+	}
+
+	last := d.previous(0)
+	lastLast := d.previous(1)
+
+	// the velocity to the current object, extended through the previous
+	// object if it is a slider
+	currVelocity := d.lazyJumpDistance / d.strainTime
+	if last.object.isSlider && withSliderTravelDistance {
+		travelVelocity := last.travelDistance / last.travelTime
+		movementVelocity := d.minimumJumpDistance / d.minimumJumpTime
+		currVelocity = math.Max(currVelocity, movementVelocity+travelVelocity)
+	}
+
+	prevVelocity := last.lazyJumpDistance / last.strainTime
+	if lastLast.object.isSlider && withSliderTravelDistance {
+		travelVelocity := lastLast.travelDistance / lastLast.travelTime
+		movementVelocity := last.minimumJumpDistance / last.minimumJumpTime
+		prevVelocity = math.Max(prevVelocity, movementVelocity+travelVelocity)
+	}
+
+	wideAngleBonus := 0.0
+	acuteAngleBonus := 0.0
+	sliderBonus := 0.0
+	velocityChangeBonus := 0.0
+
+	aimStrain := currVelocity
+
+	// angles are only rewarded if the rhythm stays the same
+	if math.Max(d.strainTime, last.strainTime) < 1.25*math.Min(d.strainTime, last.strainTime) {
+		if d.hasAngle && last.hasAngle && lastLast.hasAngle {
+			currAngle := d.angle
+			lastAngle := last.angle
+			lastLastAngle := lastLast.angle
+
+			// rewarding angles, take the smaller velocity as base
+			angleBonus := math.Min(currVelocity, prevVelocity)
+
+			wideAngleBonus = calcWideAngleBonus(currAngle)
+			acuteAngleBonus = calcAcuteAngleBonus(currAngle)
+
+			// only buff delta times exceeding 300 bpm 1/2
+			if d.strainTime > 100 {
+				acuteAngleBonus = 0
+			} else {
+				acuteAngleBonus *= calcAcuteAngleBonus(lastAngle) *
+					math.Min(angleBonus, 125/d.strainTime) *
+					math.Pow(math.Sin(math.Pi/2*math.Min(1, (100-d.strainTime)/25)), 2) *
+					math.Pow(math.Sin(math.Pi/2*(clamp(d.lazyJumpDistance, 50, 100)-50)/50), 2)
+			}
+
+			// penalize repeated wide angles, less so as the last angle gets
+			// more acute
+			wideAngleBonus *= angleBonus * (1 - math.Min(wideAngleBonus, math.Pow(calcWideAngleBonus(lastAngle), 3)))
+			// penalize repeated acute angles, less so as the angle before
+			// the last gets more obtuse
+			acuteAngleBonus *= 0.5 + 0.5*(1-math.Min(acuteAngleBonus, math.Pow(calcAcuteAngleBonus(lastLastAngle), 3)))
+		}
+	}
+
+	if math.Max(prevVelocity, currVelocity) != 0 {
+		// use the average velocity over the whole object when awarding
+		// differences, not the individual jump and slider path velocities
+		prevVelocity = (last.lazyJumpDistance + lastLast.travelDistance) / last.strainTime
+		currVelocity = (d.lazyJumpDistance + last.travelDistance) / d.strainTime
+
+		distRatio := math.Pow(math.Sin(math.Pi/2*math.Abs(prevVelocity-currVelocity)/math.Max(prevVelocity, currVelocity)), 2)
+
+		// reward for % distance up to 125 / strainTime for overlaps where
+		// velocity is still changing
+		overlapVelocityBuff := math.Min(125/math.Min(d.strainTime, last.strainTime), math.Abs(prevVelocity-currVelocity))
+
+		velocityChangeBonus = overlapVelocityBuff * distRatio
+
+		// penalize for rhythm changes
+		velocityChangeBonus *= math.Pow(math.Min(d.strainTime, last.strainTime)/math.Max(d.strainTime, last.strainTime), 2)
+	}
+
+	if last.object.isSlider {
+		sliderBonus = last.travelDistance / last.travelTime
+	}
+
+	aimStrain += math.Max(acuteAngleBonus*acuteAngleMultiplier, wideAngleBonus*wideAngleMultiplier+velocityChangeBonus*velocityChangeMultiplier)
+
+	if withSliderTravelDistance {
+		aimStrain += sliderBonus * sliderMultiplier
+	}
+
+	return aimStrain
+}
+
+type speedSkill struct {
+	strainPeaks
+	currentStrain float64
+	currentRhythm float64
+	objectStrains []float64
+}
+
+func (s *speedSkill) process(d *standardDifficultyObject) {
+	const skillMultiplier = 1375
+	const strainDecayBase = 0.3
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.strainTime)
+		s.currentStrain += evaluateSpeed(d) * skillMultiplier
+
+		s.currentRhythm = evaluateRhythm(d)
+
+		totalStrain := s.currentStrain * s.currentRhythm
+		s.objectStrains = append(s.objectStrains, totalStrain)
+		return totalStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * s.currentRhythm * strainDecay(strainDecayBase, time-d.previous(0).startTime)
+	})
+}
+
+func (s *speedSkill) difficultyValue() float64 {
+	return standardStrainDifficulty(s.all(), 5, 1.04)
+}
+
+// relevantNoteCount returns the number of notes weighted by how much they
+// contribute to the speed difficulty.
+func (s *speedSkill) relevantNoteCount() float64 {
+	if len(s.objectStrains) == 0 {
+		return 0
+	}
+
+	maxStrain := slices.Max(s.objectStrains)
+	if maxStrain == 0 {
+		return 0
+	}
+
+	count := 0.0
+	for _, strain := range s.objectStrains {
+		count += 1 / (1 + math.Exp(-(strain/maxStrain*12 - 6)))
+	}
+	return count
+}
+
+func evaluateSpeed(d *standardDifficultyObject) float64 {
+	const singleSpacingThreshold = 125
+	const minSpeedBonus = 75 // ~200BPM
+	const speedBalancingFactor = 40
+
+	if d.object.isSpinner {
+		return 0
+	}
+
+	prev := d.previous(0)
+	next := d.next(0)
+
+	strainTime := d.strainTime
+	doubletapness := 1.0
+
+	// nerf doubletappable doubles
+	if next != nil {
+		currDeltaTime := math.Max(1, d.deltaTime)
+		nextDeltaTime := math.Max(1, next.deltaTime)
+		deltaDifference := math.Abs(nextDeltaTime - currDeltaTime)
+		speedRatio := currDeltaTime / math.Max(currDeltaTime, deltaDifference)
+		windowRatio := math.Pow(math.Min(1, currDeltaTime/d.hitWindowGreat), 2)
+		doubletapness = math.Pow(speedRatio, 1-windowRatio)
+	}
+
+	// cap delta time to the OD 300 hit window
+	strainTime /= clamp((strainTime/d.hitWindowGreat)/0.93, 0.92, 1)
+
+	speedBonus := 1.0
+	if strainTime < minSpeedBonus {
+		speedBonus = 1 + 0.75*math.Pow((minSpeedBonus-strainTime)/speedBalancingFactor, 2)
+	}
+
+	travelDistance := 0.0
+	if prev != nil {
+		travelDistance = prev.travelDistance
+	}
+	distance := math.Min(singleSpacingThreshold, travelDistance+d.minimumJumpDistance)
+
+	return (speedBonus + speedBonus*math.Pow(distance/singleSpacingThreshold, 3.5)) * doubletapness / strainTime
+}
+
+func evaluateRhythm(d *standardDifficultyObject) float64 {
+	const historyTimeMax = 5000 // 5 seconds of calculating rhythm bonus max
+	const rhythmMultiplier = 0.75
+
+	if d.object.isSpinner {
+		return 0
+	}
+
+	previousIslandSize := 0
+	rhythmComplexitySum := 0.0
+	islandSize := 1
+	startRatio := 0.0 // ratio of the current start of an island to buff for tighter rhythms
+	firstDeltaSwitch := false
+
+	historicalNoteCount := min(d.index, 32)
+
+	rhythmStart := 0
+	for rhythmStart < historicalNoteCount-2 && d.startTime-d.previous(rhythmStart).startTime < historyTimeMax {
+		rhythmStart++
+	}
+
+	for i := rhythmStart; i > 0; i-- {
+		currObj := d.previous(i - 1)
+		prevObj := d.previous(i)
+		lastObj := d.previous(i + 1)
+
+		// scales note 0 to 1 from history to now, limited by either time or
+		// object count
+		currHistoricalDecay := (historyTimeMax - (d.startTime - currObj.startTime)) / historyTimeMax
+		currHistoricalDecay = math.Min(float64(historicalNoteCount-i)/float64(historicalNoteCount), currHistoricalDecay)
+
+		currDelta := currObj.strainTime
+		prevDelta := prevObj.strainTime
+		lastDelta := lastObj.strainTime
+
+		currRatio := 1 + 6*math.Min(0.5, math.Pow(math.Sin(math.Pi/(math.Min(prevDelta, currDelta)/math.Max(prevDelta, currDelta))), 2))
+
+		windowPenalty := 0.0
+		if currObj.hitWindowGreat > 0 {
+			windowPenalty = math.Min(1, math.Max(0, math.Abs(prevDelta-currDelta)-currObj.hitWindowGreat*0.3)/(currObj.hitWindowGreat*0.3))
+		}
+
+		effectiveRatio := windowPenalty * currRatio
+
+		if firstDeltaSwitch {
+			if !(prevDelta > 1.25*currDelta || prevDelta*1.25 < currDelta) {
+				// island is still progressing, count size
+				if islandSize < 7 {
+					islandSize++
+				}
+			} else {
+				// bpm change is into slider, this is easy acc window
+				if currObj.object.isSlider {
+					effectiveRatio *= 0.125
+				}
+				// bpm change was from a slider, this is easier typically than
+				// circle -> circle
+				if prevObj.object.isSlider {
+					effectiveRatio *= 0.25
+				}
+				// repeated island size (ex: triplet -> triplet)
+				if previousIslandSize == islandSize {
+					effectiveRatio *= 0.25
+				}
+				// repeated island polarity (2 -> 4, 3 -> 5)
+				if previousIslandSize%2 == islandSize%2 {
+					effectiveRatio *= 0.5
+				}
+				// previous increase happened a note ago, 1/1->1/2-1/4, don't
+				// want to buff this
+				if lastDelta > prevDelta+10 && prevDelta > currDelta+10 {
+					effectiveRatio *= 0.125
+				}
+
+				rhythmComplexitySum += math.Sqrt(effectiveRatio*startRatio) * currHistoricalDecay * math.Sqrt(float64(4+islandSize)) / 2 * math.Sqrt(float64(4+previousIslandSize)) / 2
+
+				startRatio = effectiveRatio
+				previousIslandSize = islandSize
+
+				// slowing down stops counting, speeding up keeps counting
+				// the island size
+				if prevDelta*1.25 < currDelta {
+					firstDeltaSwitch = false
+				}
+
+				islandSize = 1
+			}
+		} else if prevDelta > 1.25*currDelta {
+			// begin counting the island until the speed changes again
+			firstDeltaSwitch = true
+			startRatio = effectiveRatio
+			islandSize = 1
+		}
+	}
+
+	return math.Sqrt(4+rhythmComplexitySum*rhythmMultiplier) / 2
+}
+
+type flashlightSkill struct {
+	strainPeaks
+	hidden        bool
+	currentStrain float64
+}
+
+func (s *flashlightSkill) process(d *standardDifficultyObject) {
+	const skillMultiplier = 0.052
+	const strainDecayBase = 0.15
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += evaluateFlashlight(d, s.hidden) * skillMultiplier
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-d.previous(0).startTime)
+	})
+}
+
+func (s *flashlightSkill) difficultyValue() float64 {
+	sum := 0.0
+	for _, peak := range s.all() {
+		sum += peak
+	}
+	return sum * 1.06
+}
+
+func evaluateFlashlight(d *standardDifficultyObject, hidden bool) float64 {
+	const maxOpacityBonus = 0.4
+	const hiddenBonus = 0.2
+	const minVelocity = 0.5
+	const sliderMultiplier = 1.3
+	const minAngleMultiplier = 0.2
+
+	if d.object.isSpinner {
+		return 0
+	}
+
+	o := d.object
+	scalingFactor := 52.0 / o.radius
+	smallDistNerf := 1.0
+	cumulativeStrainTime := 0.0
+	result := 0.0
+	last := d
+	angleRepeatCount := 0.0
+
+	// iterate backwards in time from the current object
+	for i := 0; i < min(d.index, 10); i++ {
+		current := d.previous(i)
+
+		if !current.object.isSpinner {
+			jumpDistance := o.stackedPosition().Sub(current.object.stackedEndPosition()).Length()
+
+			cumulativeStrainTime += last.strainTime
+
+			// nerf objects that can be easily seen within the flashlight
+			// circle radius
+			if i == 0 {
+				smallDistNerf = math.Min(1, jumpDistance/75)
+			}
+
+			// nerf stacks so that only the first object of the stack is
+			// accounted for
+			stackNerf := math.Min(1, (current.lazyJumpDistance/scalingFactor)/25)
+
+			// bonus based on how visible the object is
+			opacityBonus := 1 + maxOpacityBonus*(1-o.opacityAt(current.object.startTime, hidden))
+
+			result += stackNerf * opacityBonus * scalingFactor * jumpDistance / cumulativeStrainTime
+
+			if current.hasAngle && d.hasAngle {
+				// objects further back in time count less for the nerf
+				if math.Abs(current.angle-d.angle) < 0.02 {
+					angleRepeatCount += math.Max(1-0.1*float64(i), 0)
+				}
+			}
+		}
+
+		last = current
+	}
+
+	result = math.Pow(smallDistNerf*result, 2)
+
+	// additional bonus for hidden due to there being no approach circles
+	if hidden {
+		result *= 1 + hiddenBonus
+	}
+
+	// nerf patterns with repeated angles
+	result *= minAngleMultiplier + (1-minAngleMultiplier)/(angleRepeatCount+1)
+
+	sliderBonus := 0.0
+	if o.isSlider {
+		// the travel distance independent of circle size
+		pixelTravelDistance := o.lazyTravelDistance / scalingFactor
+
+		// reward sliders based on velocity, longer sliders require more
+		// memorisation
+		sliderBonus = math.Pow(math.Max(0, pixelTravelDistance/d.travelTime-minVelocity), 0.5)
+		sliderBonus *= pixelTravelDistance
+
+		// nerf sliders with repeats, as less memorisation is required
+		if o.slider.Slides > 1 {
+			sliderBonus /= float64(o.slider.Slides)
+		}
+	}
+
+	result += sliderBonus * sliderMultiplier
+
+	return result
+}
+
+// StandardDifficulty calculates the osu!standard difficulty attributes of the
+// beatmap with mods applied.
+func (f *OsuFile) StandardDifficulty(mods Mods) *StandardDifficulty {
+	attributes := f.Difficulty.Attributes(ModeStandard, mods)
+
+	difficulty := &StandardDifficulty{
+		Mods:              mods,
+		ApproachRate:      attributes.ApproachRate,
+		OverallDifficulty: attributes.OverallDifficulty,
+		DrainRate:         attributes.HPDrainRate,
+		SliderFactor:      1,
+	}
+	if len(f.HitObjects) == 0 {
+		return difficulty
+	}
+
+	objects := f.standardObjects(mods)
+	for _, o := range objects {
+		switch {
+		case o.isSlider:
+			difficulty.SliderCount++
+			difficulty.MaxCombo += len(o.nested)
+		case o.isSpinner:
+			difficulty.SpinnerCount++
+			difficulty.MaxCombo++
+		default:
+			difficulty.HitCircleCount++
+			difficulty.MaxCombo++
+		}
+	}
+
+	aim := &aimSkill{withSliders: true}
+	aimNoSliders := &aimSkill{}
+	speed := &speedSkill{}
+	flashlight := &flashlightSkill{hidden: mods.Has(ModHidden)}
+	skills := []standardSkill{aim, aimNoSliders, speed, flashlight}
+
+	for _, d := range newStandardDifficultyObjects(objects, mods.ClockRate()) {
+		for _, skill := range skills {
+			skill.process(d)
+		}
+	}
+
+	aimRating := math.Sqrt(aim.difficultyValue()) * standardDifficultyMultiplier
+	aimRatingNoSliders := math.Sqrt(aimNoSliders.difficultyValue()) * standardDifficultyMultiplier
+	speedRating := math.Sqrt(speed.difficultyValue()) * standardDifficultyMultiplier
+	flashlightRating := 0.0
+	if mods.Has(ModFlashlight) {
+		flashlightRating = math.Sqrt(flashlight.difficultyValue()) * standardDifficultyMultiplier
+	}
+
+	if aimRating > 0 {
+		difficulty.SliderFactor = aimRatingNoSliders / aimRating
+	}
+
+	if mods.Has(ModTouchDevice) {
+		aimRating = math.Pow(aimRating, 0.8)
+		flashlightRating = math.Pow(flashlightRating, 0.8)
+	}
+
+	if mods.Has(ModRelax) {
+		aimRating *= 0.9
+		speedRating = 0
+		flashlightRating *= 0.7
+	}
+
+	baseAimPerformance := math.Pow(5*math.Max(1, aimRating/0.0675)-4, 3) / 100000
+	baseSpeedPerformance := math.Pow(5*math.Max(1, speedRating/0.0675)-4, 3) / 100000
+	baseFlashlightPerformance := 0.0
+	if mods.Has(ModFlashlight) {
+		baseFlashlightPerformance = math.Pow(flashlightRating, 2) * 25
+	}
+
+	basePerformance := math.Pow(
+		math.Pow(baseAimPerformance, 1.1)+
+			math.Pow(baseSpeedPerformance, 1.1)+
+			math.Pow(baseFlashlightPerformance, 1.1),
+		1/1.1,
+	)
+
+	if basePerformance > 0.00001 {
+		difficulty.StarRating = math.Cbrt(standardPerformanceMultiplier) * 0.027 * (math.Cbrt(100000/math.Pow(2, 1/1.1)*basePerformance) + 4)
+	}
+
+	difficulty.AimDifficulty = aimRating
+	difficulty.SpeedDifficulty = speedRating
+	difficulty.SpeedNoteCount = speed.relevantNoteCount()
+	difficulty.FlashlightDifficulty = flashlightRating
+
+	return difficulty
+}
@@ -0,0 +1,183 @@
+package osuParser
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// the difficulty and pp values in the tables were calculated by this package
+// for the fixtures in testdata and guard the calculators against regressions.
+// the fixtures aren't ranked and the star ratings cached in the osu!.db
+// fixtures are generated, so there are no values from osu! for them; the mod
+// tests compare against beatmaps osu! rates the same instead, a rate change
+// being the beatmap with its times and windows scaled and hard rock the
+// flipped beatmap with the raised difficulty settings.
+
+func parseFixture(t *testing.T, name string) *OsuFile {
+	t.Helper()
+
+	f, err := ParseOsuFileWithOptions("testdata/"+name, ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func mustParseMods(t *testing.T, s string) Mods {
+	t.Helper()
+
+	mods, err := ParseMods(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mods
+}
+
+func approxEqual(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-6*math.Max(1, math.Abs(want))
+}
+
+// withoutSliders returns a copy of the beatmap with the sliders removed.
+func withoutSliders(f *OsuFile) *OsuFile {
+	g := *f
+	g.HitObjects = nil
+	for _, h := range f.HitObjects {
+		if _, ok := h.ObjectParams.(*Slider); !ok {
+			g.HitObjects = append(g.HitObjects, h)
+		}
+	}
+	return &g
+}
+
+// stretched returns a copy of the beatmap with all times multiplied by
+// factor and the approach rate and overall difficulty changed to keep the
+// approach and the hit windows in proportion, so playing it with a clock
+// rate of factor is the same as playing the beatmap itself.
+func stretched(f *OsuFile, factor float64) *OsuFile {
+	g := *f
+	g.HitObjects = slices.Clone(f.HitObjects)
+	for i := range g.HitObjects {
+		h := &g.HitObjects[i]
+		h.Time *= factor
+		switch params := h.ObjectParams.(type) {
+		case Spinner:
+			h.ObjectParams = Spinner{EndTime: params.EndTime * factor}
+		case Hold:
+			h.ObjectParams = Hold{EndTime: params.EndTime * factor}
+		}
+	}
+	g.TimingPointsFile = slices.Clone(f.TimingPointsFile)
+	for i := range g.TimingPointsFile {
+		tp := &g.TimingPointsFile[i]
+		tp.Time = int(float64(tp.Time) * factor)
+		if tp.IsUninherited() {
+			tp.BeatLength *= factor
+		}
+	}
+	g.ApproachRate = inverseDifficultyRange(difficultyRange(f.ApproachRate, 1800, 1200, 450)*factor, 1800, 1200, 450)
+	g.OverallDifficulty = inverseDifficultyRange(difficultyRange(f.OverallDifficulty, 80, 50, 20)*factor, 80, 50, 20)
+	return &g
+}
+
+// hardRocked returns a copy of the beatmap flipped vertically with the
+// difficulty settings hard rock gives it.
+func hardRocked(f *OsuFile) *OsuFile {
+	g := *f
+	g.HitObjects = slices.Clone(f.HitObjects)
+	for i := range g.HitObjects {
+		h := &g.HitObjects[i]
+		h.Y = 384 - h.Y
+		if slider, ok := h.ObjectParams.(*Slider); ok {
+			s := *slider
+			s.Points = nil
+			for _, p := range slider.Points {
+				s.Points = append(s.Points, Point{X: p.X, Y: 384 - p.Y})
+			}
+			h.ObjectParams = &s
+		}
+	}
+	g.CircleSize = math.Min(f.CircleSize*1.3, 10)
+	g.ApproachRate = math.Min(f.ApproachRate*1.4, 10)
+	g.OverallDifficulty = math.Min(f.OverallDifficulty*1.4, 10)
+	g.HPDrainRate = math.Min(f.HPDrainRate*1.4, 10)
+	return &g
+}
+
+func TestStandardDifficulty(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+
+	tests := []struct {
+		mods       string
+		stars      float64
+		aim        float64
+		speed      float64
+		flashlight float64
+	}{
+		{"NM", 2.528910, 1.347266, 1.020703, 0},
+		{"HR", 2.719624, 1.475958, 1.040448, 0},
+		{"DT", 3.174597, 1.663683, 1.332215, 0},
+		{"EZHT", 1.923067, 0.987998, 0.837962, 0},
+		{"HDFL", 2.620648, 1.347266, 1.020703, 0.272636},
+	}
+
+	for _, test := range tests {
+		d := f.StandardDifficulty(mustParseMods(t, test.mods))
+
+		got := []float64{d.StarRating, d.AimDifficulty, d.SpeedDifficulty, d.FlashlightDifficulty}
+		want := []float64{test.stars, test.aim, test.speed, test.flashlight}
+		for i := range got {
+			if !approxEqual(got[i], want[i]) {
+				t.Errorf("%s: got stars, aim, speed, flashlight %.6f, want %.6f", test.mods, got, want)
+				break
+			}
+		}
+
+		if d.MaxCombo != 71 || d.MaxCombo != f.MaxCombo() {
+			t.Errorf("%s: max combo %d, beatmap max combo %d, want 71", test.mods, d.MaxCombo, f.MaxCombo())
+		}
+		if n := d.HitCircleCount + d.SliderCount + d.SpinnerCount; n != len(f.HitObjects) {
+			t.Errorf("%s: counted %d objects, want %d", test.mods, n, len(f.HitObjects))
+		}
+	}
+}
+
+func TestStandardDifficultyModEquivalents(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+	// slider ends are cut short by a fixed time of the beatmap, so the rate
+	// changes only match without sliders
+	circles := withoutSliders(f)
+
+	tests := []struct {
+		name     string
+		got      *StandardDifficulty
+		expected *StandardDifficulty
+	}{
+		{"HR", f.StandardDifficulty(ModHardRock), hardRocked(f).StandardDifficulty(ModNone)},
+		{"HDHR", f.StandardDifficulty(ModHidden | ModHardRock), hardRocked(f).StandardDifficulty(ModHidden)},
+		{"DT", stretched(circles, 1.5).StandardDifficulty(ModDoubleTime), circles.StandardDifficulty(ModNone)},
+		{"HT", stretched(circles, 0.75).StandardDifficulty(ModHalfTime), circles.StandardDifficulty(ModNone)},
+		{"HDFLDT", stretched(circles, 1.5).StandardDifficulty(ModHidden | ModFlashlight | ModDoubleTime), circles.StandardDifficulty(ModHidden | ModFlashlight)},
+	}
+
+	for _, test := range tests {
+		got := []float64{test.got.StarRating, test.got.AimDifficulty, test.got.SpeedDifficulty, test.got.FlashlightDifficulty, test.got.SliderFactor}
+		want := []float64{test.expected.StarRating, test.expected.AimDifficulty, test.expected.SpeedDifficulty, test.expected.FlashlightDifficulty, test.expected.SliderFactor}
+		for i := range got {
+			if !approxEqual(got[i], want[i]) {
+				t.Errorf("%s: got stars, aim, speed, flashlight, slider factor %.6f, want %.6f", test.name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestStandardDifficultyEmpty(t *testing.T) {
+	f := parseFixture(t, "standard.osu")
+	f.HitObjects = nil
+
+	d := f.StandardDifficulty(ModNone)
+	if d.StarRating != 0 || d.MaxCombo != 0 {
+		t.Errorf("empty beatmap has %v stars and max combo %d", d.StarRating, d.MaxCombo)
+	}
+}
@@ -0,0 +1,76 @@
+package osuParser
+
+import (
+	"math"
+	"slices"
+)
+
+// strainSectionLength is the length of the sections strain peaks are taken
+// from, in milliseconds.
+const strainSectionLength = 400
+
+// strainPeaks collects the highest strain of every section of a beatmap.
+// Sections are strainSectionLength long unless sectionLength is set.
+type strainPeaks struct {
+	sectionLength float64
+
+	sectionEnd  float64
+	sectionPeak float64
+	peaks       []float64
+}
+
+// process records the strain of the object at index. initial returns the
+// decayed strain at the start of a new section.
+func (s *strainPeaks) process(index int, startTime float64, strain func() float64, initial func(time float64) float64) {
+	// the first object doesn't generate a strain, so we begin with an
+	// incremented section end
+	if s.sectionLength == 0 {
+		s.sectionLength = strainSectionLength
+	}
+
+	if index == 0 {
+		s.sectionEnd = math.Ceil(startTime/s.sectionLength) * s.sectionLength
+	}
+
+	for startTime > s.sectionEnd {
+		s.peaks = append(s.peaks, s.sectionPeak)
+		s.sectionPeak = initial(s.sectionEnd)
+		s.sectionEnd += s.sectionLength
+	}
+
+	s.sectionPeak = math.Max(strain(), s.sectionPeak)
+}
+
+func (s *strainPeaks) all() []float64 {
+	return append(slices.Clone(s.peaks), s.sectionPeak)
+}
+
+// weightedSum sums the peaks from highest to lowest, each weighted by
+// decayWeight times the one before.
+func weightedSum(peaks []float64, decayWeight float64) float64 {
+	sorted := make([]float64, 0, len(peaks))
+	for _, peak := range peaks {
+		// sections with 0 strain are excluded as they don't contribute
+		if peak > 0 {
+			sorted = append(sorted, peak)
+		}
+	}
+	slices.Sort(sorted)
+	slices.Reverse(sorted)
+
+	difficulty := 0.0
+	weight := 1.0
+	for _, strain := range sorted {
+		difficulty += strain * weight
+		weight *= decayWeight
+	}
+	return difficulty
+}
+
+func strainDecay(base, ms float64) float64 {
+	return math.Pow(base, ms/1000)
+}
+
+func lerp(start, end, amount float64) float64 {
+	return start + (end-start)*amount
+}
@@ -0,0 +1,598 @@
+package osuParser
+
+import "math"
+
+// TaikoDifficulty are the difficulty attributes of a beatmap in osu!taiko,
+// as calculated by the osu! difficulty algorithm.
+type TaikoDifficulty struct {
+	Mods              Mods
+	StarRating        float64
+	StaminaDifficulty float64
+	RhythmDifficulty  float64
+	ColourDifficulty  float64
+	PeakDifficulty    float64
+	GreatHitWindow    float64
+	MaxCombo          int
+	Converted         bool
+}
+
+const (
+	taikoDifficultyMultiplier = 1.35
+
+	taikoFinalMultiplier   = 0.0625
+	taikoRhythmMultiplier  = 0.2 * taikoFinalMultiplier
+	taikoColourMultiplier  = 0.375 * taikoFinalMultiplier
+	taikoStaminaMultiplier = 0.375 * taikoFinalMultiplier
+
+	taikoRhythmHistoryLength   = 8
+	taikoMaxRepetitionInterval = 16
+)
+
+// TaikoObjectType is the kind of a TaikoObject.
+type TaikoObjectType int
+
+const (
+	TaikoHit TaikoObjectType = iota
+	TaikoDrumRoll
+	TaikoSwell
+)
+
+// TaikoObject is a hit, drum roll or swell of an osu!taiko beatmap. Hits are
+// rims when they have a whistle or clap and strong when they have a finish.
+type TaikoObject struct {
+	Type      TaikoObjectType
+	StartTime float64
+	EndTime   float64
+	Rim       bool
+	Strong    bool
+}
+
+// taikoRhythm is a ratio between the current and previous delta time, with
+// the difficulty of changing to it.
+type taikoRhythm struct {
+	ratio      float64
+	difficulty float64
+}
+
+var taikoCommonRhythms = []taikoRhythm{
+	{1.0 / 1, 0.0},
+	{2.0 / 1, 0.3},
+	{1.0 / 2, 0.5},
+	{3.0 / 1, 0.3},
+	{1.0 / 3, 0.35},
+	// purposefully higher as it requires a hand switch when alternating
+	{3.0 / 2, 0.6},
+	{2.0 / 3, 0.4},
+	{5.0 / 4, 0.5},
+	{4.0 / 5, 0.7},
+}
+
+// taikoDifficultyObject is a taiko object with its relation to the objects
+// before it.
+type taikoDifficultyObject struct {
+	index     int
+	object    *TaikoObject
+	startTime float64
+	deltaTime float64
+
+	// rhythm is an index into taikoCommonRhythms
+	rhythm int
+
+	monoIndex int
+	noteIndex int
+	lists     *taikoObjectLists
+
+	monoStreak             *monoStreak
+	alternatingMonoPattern *alternatingMonoPattern
+	repeatingHitPatterns   *repeatingHitPatterns
+}
+
+// taikoObjectLists are the difficulty objects split by colour, and all
+// hits together.
+type taikoObjectLists struct {
+	centre []*taikoDifficultyObject
+	rim    []*taikoDifficultyObject
+	notes  []*taikoDifficultyObject
+}
+
+func (d *taikoDifficultyObject) isHit() bool {
+	return d.object.Type == TaikoHit
+}
+
+// hitType returns 0 for centre and 1 for rim hits and -1 for everything
+// else.
+func (d *taikoDifficultyObject) hitType() int {
+	switch {
+	case !d.isHit():
+		return -1
+	case d.object.Rim:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// previousMono returns the hit of the same colour backwardsIndex hits
+// before this one.
+func (d *taikoDifficultyObject) previousMono(backwardsIndex int) *taikoDifficultyObject {
+	if !d.isHit() {
+		return nil
+	}
+
+	mono := d.lists.centre
+	if d.object.Rim {
+		mono = d.lists.rim
+	}
+
+	index := d.monoIndex - (backwardsIndex + 1)
+	if index < 0 || index >= len(mono) {
+		return nil
+	}
+	return mono[index]
+}
+
+// previousNote returns the hit backwardsIndex hits before this one. It is
+// nil for drum rolls and swells, like in osu!.
+func (d *taikoDifficultyObject) previousNote(backwardsIndex int) *taikoDifficultyObject {
+	index := d.noteIndex - (backwardsIndex + 1)
+	if !d.isHit() || index < 0 || index >= len(d.lists.notes) {
+		return nil
+	}
+	return d.lists.notes[index]
+}
+
+func newTaikoDifficultyObjects(objects []TaikoObject, clockRate float64) []*taikoDifficultyObject {
+	lists := &taikoObjectLists{}
+	var difficultyObjects []*taikoDifficultyObject
+
+	for i := 2; i < len(objects); i++ {
+		d := &taikoDifficultyObject{
+			index:     len(difficultyObjects),
+			object:    &objects[i],
+			startTime: objects[i].StartTime / clockRate,
+			deltaTime: (objects[i].StartTime - objects[i-1].StartTime) / clockRate,
+			lists:     lists,
+		}
+
+		previousLength := (objects[i-1].StartTime - objects[i-2].StartTime) / clockRate
+		ratio := d.deltaTime / previousLength
+		for j, rhythm := range taikoCommonRhythms {
+			if math.Abs(rhythm.ratio-ratio) < math.Abs(taikoCommonRhythms[d.rhythm].ratio-ratio) {
+				d.rhythm = j
+			}
+		}
+
+		if d.isHit() {
+			if d.object.Rim {
+				d.monoIndex = len(lists.rim)
+				lists.rim = append(lists.rim, d)
+			} else {
+				d.monoIndex = len(lists.centre)
+				lists.centre = append(lists.centre, d)
+			}
+			d.noteIndex = len(lists.notes)
+			lists.notes = append(lists.notes, d)
+		}
+
+		difficultyObjects = append(difficultyObjects, d)
+	}
+
+	encodeTaikoColours(difficultyObjects)
+	return difficultyObjects
+}
+
+// monoStreak is a run of hits of the same colour.
+type monoStreak struct {
+	objects []*taikoDifficultyObject
+	parent  *alternatingMonoPattern
+	index   int
+}
+
+func (m *monoStreak) hitType() int {
+	return m.objects[0].hitType()
+}
+
+// alternatingMonoPattern is a run of mono streaks of the same length,
+// alternating in colour.
+type alternatingMonoPattern struct {
+	monoStreaks []*monoStreak
+	parent      *repeatingHitPatterns
+	index       int
+}
+
+func (a *alternatingMonoPattern) firstObject() *taikoDifficultyObject {
+	return a.monoStreaks[0].objects[0]
+}
+
+func (a *alternatingMonoPattern) hasIdenticalMonoLength(other *alternatingMonoPattern) bool {
+	return len(other.monoStreaks[0].objects) == len(a.monoStreaks[0].objects)
+}
+
+func (a *alternatingMonoPattern) isRepetitionOf(other *alternatingMonoPattern) bool {
+	return a.hasIdenticalMonoLength(other) &&
+		len(other.monoStreaks) == len(a.monoStreaks) &&
+		other.monoStreaks[0].hitType() == a.monoStreaks[0].hitType()
+}
+
+// repeatingHitPatterns are alternating mono patterns that repeat each other.
+type repeatingHitPatterns struct {
+	alternatingMonoPatterns []*alternatingMonoPattern
+	previous                *repeatingHitPatterns
+	repetitionInterval      int
+}
+
+func (r *repeatingHitPatterns) firstObject() *taikoDifficultyObject {
+	return r.alternatingMonoPatterns[0].firstObject()
+}
+
+func (r *repeatingHitPatterns) isRepetitionOf(other *repeatingHitPatterns) bool {
+	if len(r.alternatingMonoPatterns) != len(other.alternatingMonoPatterns) {
+		return false
+	}
+
+	for i := 0; i < min(len(r.alternatingMonoPatterns), 2); i++ {
+		if !r.alternatingMonoPatterns[i].hasIdenticalMonoLength(other.alternatingMonoPatterns[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// findRepetitionInterval finds how many patterns ago this one last occurred,
+// up to taikoMaxRepetitionInterval.
+func (r *repeatingHitPatterns) findRepetitionInterval() {
+	r.repetitionInterval = taikoMaxRepetitionInterval + 1
+
+	other := r.previous
+	for interval := 1; other != nil && interval < taikoMaxRepetitionInterval; interval++ {
+		if r.isRepetitionOf(other) {
+			r.repetitionInterval = interval
+			return
+		}
+		other = other.previous
+	}
+}
+
+// encodeTaikoColours groups the objects into mono streaks, alternating mono
+// patterns and repeating hit patterns and assigns them to the objects.
+func encodeTaikoColours(objects []*taikoDifficultyObject) {
+	var monoStreaks []*monoStreak
+	var current *monoStreak
+	for _, d := range objects {
+		// drum rolls and swells have no previous note and always start a new
+		// streak
+		previous := d.previousNote(0)
+		if current == nil || previous == nil || d.hitType() != previous.hitType() {
+			current = &monoStreak{}
+			monoStreaks = append(monoStreaks, current)
+		}
+		current.objects = append(current.objects, d)
+	}
+
+	var monoPatterns []*alternatingMonoPattern
+	currentPattern := &alternatingMonoPattern{}
+	for i, streak := range monoStreaks {
+		currentPattern.monoStreaks = append(currentPattern.monoStreaks, streak)
+
+		if i == len(monoStreaks)-1 || len(streak.objects) != len(monoStreaks[i+1].objects) {
+			monoPatterns = append(monoPatterns, currentPattern)
+			currentPattern = &alternatingMonoPattern{}
+		}
+	}
+
+	var hitPatterns []*repeatingHitPatterns
+	var currentHitPatterns *repeatingHitPatterns
+	for i := 0; i < len(monoPatterns); i++ {
+		currentHitPatterns = &repeatingHitPatterns{previous: currentHitPatterns}
+
+		isCoupled := i < len(monoPatterns)-2 && monoPatterns[i].isRepetitionOf(monoPatterns[i+2])
+		if !isCoupled {
+			currentHitPatterns.alternatingMonoPatterns = append(currentHitPatterns.alternatingMonoPatterns, monoPatterns[i])
+		} else {
+			for isCoupled {
+				currentHitPatterns.alternatingMonoPatterns = append(currentHitPatterns.alternatingMonoPatterns, monoPatterns[i])
+				i++
+				isCoupled = i < len(monoPatterns)-2 && monoPatterns[i].isRepetitionOf(monoPatterns[i+2])
+			}
+
+			// add the two patterns the last one was coupled with
+			currentHitPatterns.alternatingMonoPatterns = append(currentHitPatterns.alternatingMonoPatterns, monoPatterns[i], monoPatterns[i+1])
+			i++
+		}
+
+		hitPatterns = append(hitPatterns, currentHitPatterns)
+	}
+
+	for _, hitPattern := range hitPatterns {
+		hitPattern.findRepetitionInterval()
+
+		for i, monoPattern := range hitPattern.alternatingMonoPatterns {
+			monoPattern.parent = hitPattern
+			monoPattern.index = i
+
+			for j, streak := range monoPattern.monoStreaks {
+				streak.parent = monoPattern
+				streak.index = j
+
+				for _, d := range streak.objects {
+					d.repeatingHitPatterns = hitPattern
+					d.alternatingMonoPattern = monoPattern
+					d.monoStreak = streak
+				}
+			}
+		}
+	}
+}
+
+func taikoSigmoid(value, center, width, middle, height float64) float64 {
+	sigmoid := math.Tanh(math.E * -(value - center) / width)
+	return sigmoid*(height/2) + middle
+}
+
+func evaluateRepeatingHitPatterns(r *repeatingHitPatterns) float64 {
+	return 2 * (1 - taikoSigmoid(float64(r.repetitionInterval), 2, 2, 0.5, 1))
+}
+
+func evaluateAlternatingMonoPattern(a *alternatingMonoPattern) float64 {
+	return taikoSigmoid(float64(a.index), 2, 2, 0.5, 1) * evaluateRepeatingHitPatterns(a.parent)
+}
+
+func evaluateMonoStreak(m *monoStreak) float64 {
+	return taikoSigmoid(float64(m.index), 2, 2, 0.5, 1) * evaluateAlternatingMonoPattern(m.parent) * 0.5
+}
+
+// evaluateColour rewards the first object of every colour pattern.
+func evaluateColour(d *taikoDifficultyObject) float64 {
+	difficulty := 0.0
+	if d.monoStreak != nil && d.monoStreak.objects[0] == d {
+		difficulty += evaluateMonoStreak(d.monoStreak)
+	}
+	if d.alternatingMonoPattern != nil && d.alternatingMonoPattern.firstObject() == d {
+		difficulty += evaluateAlternatingMonoPattern(d.alternatingMonoPattern)
+	}
+	if d.repeatingHitPatterns != nil && d.repeatingHitPatterns.firstObject() == d {
+		difficulty += evaluateRepeatingHitPatterns(d.repeatingHitPatterns)
+	}
+	return difficulty
+}
+
+// evaluateStamina rewards hitting the same key quickly, which is every
+// second hit of the same colour.
+func evaluateStamina(d *taikoDifficultyObject) float64 {
+	if !d.isHit() {
+		return 0
+	}
+
+	keyPrevious := d.previousMono(1)
+	if keyPrevious == nil {
+		return 0
+	}
+
+	// the interval is capped to prevent infinite values
+	interval := math.Max(1, d.startTime-keyPrevious.startTime)
+	return 0.5 + 30/interval
+}
+
+type colourSkill struct {
+	strainPeaks
+	currentStrain float64
+}
+
+func (s *colourSkill) process(d *taikoDifficultyObject, previous *taikoDifficultyObject) {
+	const skillMultiplier = 0.12
+	const strainDecayBase = 0.8
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += evaluateColour(d) * skillMultiplier
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-previous.startTime)
+	})
+}
+
+type staminaSkill struct {
+	strainPeaks
+	currentStrain float64
+}
+
+func (s *staminaSkill) process(d *taikoDifficultyObject, previous *taikoDifficultyObject) {
+	const skillMultiplier = 1.1
+	const strainDecayBase = 0.4
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += evaluateStamina(d) * skillMultiplier
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-previous.startTime)
+	})
+}
+
+// rhythmSkill rewards changes in rhythm, penalising repeated and short
+// patterns.
+type rhythmSkill struct {
+	strainPeaks
+	currentStrain float64
+
+	rhythmStrain           float64
+	history                []*taikoDifficultyObject
+	notesSinceRhythmChange int
+}
+
+func (s *rhythmSkill) process(d *taikoDifficultyObject, previous *taikoDifficultyObject) {
+	const skillMultiplier = 10
+	const strainDecayBase = 0
+
+	s.strainPeaks.process(d.index, d.startTime, func() float64 {
+		s.currentStrain *= strainDecay(strainDecayBase, d.deltaTime)
+		s.currentStrain += s.strainValueOf(d) * skillMultiplier
+		return s.currentStrain
+	}, func(time float64) float64 {
+		return s.currentStrain * strainDecay(strainDecayBase, time-previous.startTime)
+	})
+}
+
+func (s *rhythmSkill) strainValueOf(d *taikoDifficultyObject) float64 {
+	// drum rolls and swells are exempt
+	if !d.isHit() {
+		s.reset()
+		return 0
+	}
+
+	s.rhythmStrain *= 0.96
+	s.notesSinceRhythmChange++
+
+	// no strain when the rhythm doesn't change
+	difficulty := taikoCommonRhythms[d.rhythm].difficulty
+	if difficulty == 0 {
+		return 0
+	}
+
+	objectStrain := difficulty
+	objectStrain *= s.repetitionPenalties(d)
+	objectStrain *= taikoPatternLengthPenalty(s.notesSinceRhythmChange)
+	objectStrain *= s.speedPenalty(d.deltaTime)
+
+	s.notesSinceRhythmChange = 0
+
+	s.rhythmStrain += objectStrain
+	return s.rhythmStrain
+}
+
+// repetitionPenalties penalises rhythm patterns that were played recently.
+func (s *rhythmSkill) repetitionPenalties(d *taikoDifficultyObject) float64 {
+	penalty := 1.0
+
+	s.history = append(s.history, d)
+	if len(s.history) > taikoRhythmHistoryLength {
+		s.history = s.history[1:]
+	}
+
+	for patternLength := 2; patternLength <= taikoRhythmHistoryLength/2; patternLength++ {
+		for start := len(s.history) - patternLength - 1; start >= 0; start-- {
+			if !s.samePattern(start, patternLength) {
+				continue
+			}
+
+			notesSince := d.index - s.history[start].index
+			penalty *= math.Min(1, 0.032*float64(notesSince))
+			break
+		}
+	}
+
+	return penalty
+}
+
+func (s *rhythmSkill) samePattern(start, patternLength int) bool {
+	for i := 0; i < patternLength; i++ {
+		if s.history[start+i].rhythm != s.history[len(s.history)-patternLength+i].rhythm {
+			return false
+		}
+	}
+	return true
+}
+
+func taikoPatternLengthPenalty(patternLength int) float64 {
+	shortPatternPenalty := math.Min(0.15*float64(patternLength), 1)
+	longPatternPenalty := clamp(2.5-0.15*float64(patternLength), 0, 1)
+	return math.Min(shortPatternPenalty, longPatternPenalty)
+}
+
+func (s *rhythmSkill) speedPenalty(deltaTime float64) float64 {
+	if deltaTime < 80 {
+		return 1
+	}
+	if deltaTime < 210 {
+		return math.Max(0, 1.4-0.005*deltaTime)
+	}
+
+	s.reset()
+	return 0
+}
+
+func (s *rhythmSkill) reset() {
+	s.rhythmStrain = 0
+	s.notesSinceRhythmChange = 0
+}
+
+func taikoNorm(p float64, values ...float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += math.Pow(v, p)
+	}
+	return math.Pow(sum, 1/p)
+}
+
+// TaikoDifficulty calculates the osu!taiko difficulty attributes of the
+// beatmap with mods applied.
+func (f *OsuFile) TaikoDifficulty(mods Mods) *TaikoDifficulty {
+	attributes := f.DifficultyAttributes(ModeTaiko, mods)
+
+	difficulty := &TaikoDifficulty{
+		Mods:           mods,
+		GreatHitWindow: attributes.HitWindows.Great,
+		Converted:      f.Mode != ModeTaiko,
+	}
+
+	objects := f.TaikoObjects()
+	if len(objects) == 0 {
+		return difficulty
+	}
+
+	for _, o := range objects {
+		if o.Type == TaikoHit {
+			difficulty.MaxCombo++
+		}
+	}
+
+	colour := &colourSkill{}
+	rhythm := &rhythmSkill{}
+	stamina := &staminaSkill{}
+
+	difficultyObjects := newTaikoDifficultyObjects(objects, mods.ClockRate())
+	for i, d := range difficultyObjects {
+		var previous *taikoDifficultyObject
+		if i > 0 {
+			previous = difficultyObjects[i-1]
+		}
+
+		rhythm.process(d, previous)
+		colour.process(d, previous)
+		stamina.process(d, previous)
+	}
+
+	colourPeaks := colour.all()
+	rhythmPeaks := rhythm.all()
+	staminaPeaks := stamina.all()
+
+	peaks := make([]float64, len(colourPeaks))
+	for i := range colourPeaks {
+		peak := taikoNorm(1.5, colourPeaks[i]*taikoColourMultiplier, staminaPeaks[i]*taikoStaminaMultiplier)
+		peaks[i] = taikoNorm(2, peak, rhythmPeaks[i]*taikoRhythmMultiplier)
+	}
+
+	difficulty.ColourDifficulty = weightedSum(colourPeaks, 0.9) * taikoColourMultiplier * taikoDifficultyMultiplier
+	difficulty.RhythmDifficulty = weightedSum(rhythmPeaks, 0.9) * taikoRhythmMultiplier * taikoDifficultyMultiplier
+	difficulty.StaminaDifficulty = weightedSum(staminaPeaks, 0.9) * taikoStaminaMultiplier * taikoDifficultyMultiplier
+	difficulty.PeakDifficulty = weightedSum(peaks, 0.9) * taikoDifficultyMultiplier
+
+	starRating := difficulty.PeakDifficulty * 1.4
+	if starRating >= 0 {
+		starRating = 10.43 * math.Log(starRating/8+1)
+	}
+
+	// converted beatmaps can be abused with multiple input playstyles, which
+	// isn't detected yet
+	if f.Mode == ModeStandard {
+		starRating *= 0.925
+
+		// more likely for low colour variance and high stamina requirement
+		if difficulty.ColourDifficulty < 2 && difficulty.StaminaDifficulty > 8 {
+			starRating *= 0.8
+		}
+	}
+
+	difficulty.StarRating = starRating
+	return difficulty
+}
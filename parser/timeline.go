@@ -0,0 +1,289 @@
+package osuParser
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	EffectKiai             = 1 << 0
+	EffectOmitFirstBarLine = 1 << 3
+)
+
+const (
+	defaultBeatLength = 1000
+	minBeatLength     = 6
+	maxBeatLength     = 60000
+)
+
+func (tp TimingPointFile) IsUninherited() bool {
+	return tp.Uninherited != 0
+}
+
+func (tp TimingPointFile) Kiai() bool {
+	return tp.Effects&EffectKiai != 0
+}
+
+func (tp TimingPointFile) OmitFirstBarLine() bool {
+	return tp.Effects&EffectOmitFirstBarLine != 0
+}
+
+// BPM returns the tempo of an uninherited timing point.
+func (tp TimingPointFile) BPM() float64 {
+	return 60000 / tp.BeatLength
+}
+
+// SliderVelocity returns the slider velocity multiplier set by the timing
+// point. Uninherited points reset it to 1.
+func (tp TimingPointFile) SliderVelocity() float64 {
+	if tp.IsUninherited() || tp.BeatLength >= 0 || math.IsNaN(tp.BeatLength) {
+		return 1
+	}
+	return math.Max(0.1, math.Min(10, 100/-tp.BeatLength))
+}
+
+// SampleState is the hitsound state a timing point applies.
+type SampleState struct {
+	SampleSet   int
+	SampleIndex int
+	Volume      int
+}
+
+type KiaiSection struct {
+	Start float64
+	End   float64
+}
+
+// BreakPeriod is a break between hit objects.
+type BreakPeriod struct {
+	Start float64
+	End   float64
+}
+
+// Timeline indexes the timing points of a beatmap for queries by time.
+type Timeline struct {
+	// points holds every timing point by time. Between points at the same
+	// time inherited points sort last, so they override uninherited ones
+	// just like in osu!.
+	points  []TimingPointFile
+	timing  []TimingPointFile
+	endTime float64
+}
+
+// Timeline indexes the timing points of the beatmap. The last timing point is
+// considered to last until the end of the last hit object.
+func (f *OsuFile) Timeline() *Timeline {
+	t := NewTimeline(f.TimingPointsFile)
+
+	for i := range f.HitObjects {
+		t.endTime = math.Max(t.endTime, t.HitObjectEndTime(f, &f.HitObjects[i]))
+	}
+
+	return t
+}
+
+// NewTimeline indexes timing points without a beatmap. The last timing point
+// ends at its own start time.
+func NewTimeline(points []TimingPointFile) *Timeline {
+	t := &Timeline{
+		points: append([]TimingPointFile(nil), points...),
+	}
+
+	sort.SliceStable(t.points, func(i, j int) bool {
+		if t.points[i].Time != t.points[j].Time {
+			return t.points[i].Time < t.points[j].Time
+		}
+		return t.points[i].IsUninherited() && !t.points[j].IsUninherited()
+	})
+
+	for _, tp := range t.points {
+		if tp.IsUninherited() {
+			t.timing = append(t.timing, tp)
+		}
+		t.endTime = math.Max(t.endTime, float64(tp.Time))
+	}
+
+	return t
+}
+
+// EndTime returns the time the last timing point is considered to end at.
+func (t *Timeline) EndTime() float64 {
+	return t.endTime
+}
+
+func lastAt(points []TimingPointFile, time float64) int {
+	return sort.Search(len(points), func(i int) bool {
+		return float64(points[i].Time) > time
+	}) - 1
+}
+
+// TimingAt returns the uninherited timing point active at time. Times before
+// the first timing point use the first one.
+func (t *Timeline) TimingAt(time float64) TimingPointFile {
+	if len(t.timing) == 0 {
+		return TimingPointFile{BeatLength: defaultBeatLength, Meter: 4, Volume: 100, Uninherited: 1}
+	}
+	return t.timing[max(lastAt(t.timing, time), 0)]
+}
+
+// BeatLengthAt returns the beat length active at time, clamped to the range
+// osu! accepts.
+func (t *Timeline) BeatLengthAt(time float64) float64 {
+	beatLength := t.TimingAt(time).BeatLength
+	if math.IsNaN(beatLength) {
+		return defaultBeatLength
+	}
+	return math.Max(minBeatLength, math.Min(maxBeatLength, beatLength))
+}
+
+// DifficultyAt returns the slider velocity multiplier active at time.
+func (t *Timeline) DifficultyAt(time float64) float64 {
+	i := lastAt(t.points, time)
+	if i < 0 {
+		return 1
+	}
+	return t.points[i].SliderVelocity()
+}
+
+// bpmMultiplierAt returns the factor osu! stable scales beat lengths by for
+// inherited points when converting sliders. Unlike DifficultyAt it allows
+// multipliers down to 0.1x.
+func (t *Timeline) bpmMultiplierAt(time float64) float64 {
+	i := lastAt(t.points, time)
+	if i < 0 || t.points[i].IsUninherited() || !(t.points[i].BeatLength < 0) {
+		return 1
+	}
+	return math.Max(10, math.Min(10000, -t.points[i].BeatLength)) / 100
+}
+
+// SampleAt returns the sample set, index and volume active at time.
+func (t *Timeline) SampleAt(time float64) SampleState {
+	i := lastAt(t.points, time)
+	if i < 0 {
+		if len(t.points) == 0 {
+			return SampleState{Volume: 100}
+		}
+		i = 0
+	}
+	tp := t.points[i]
+	return SampleState{
+		SampleSet:   tp.SampleSet,
+		SampleIndex: tp.SampleIndex,
+		Volume:      tp.Volume,
+	}
+}
+
+// KiaiAt reports whether kiai time is active at time.
+func (t *Timeline) KiaiAt(time float64) bool {
+	i := lastAt(t.points, time)
+	return i >= 0 && t.points[i].Kiai()
+}
+
+// KiaiSections returns the kiai sections in chronological order. A section
+// still active at the last timing point lasts until EndTime.
+func (t *Timeline) KiaiSections() []KiaiSection {
+	var sections []KiaiSection
+	kiai := false
+	var start float64
+
+	for i, tp := range t.points {
+		// only the last point at any given time is in effect
+		if i+1 < len(t.points) && t.points[i+1].Time == tp.Time {
+			continue
+		}
+
+		switch {
+		case tp.Kiai() && !kiai:
+			kiai = true
+			start = float64(tp.Time)
+		case !tp.Kiai() && kiai:
+			kiai = false
+			sections = append(sections, KiaiSection{Start: start, End: float64(tp.Time)})
+		}
+	}
+	if kiai {
+		sections = append(sections, KiaiSection{Start: start, End: math.Max(start, t.endTime)})
+	}
+	return sections
+}
+
+// Breaks returns the break periods of the [Events] section.
+func (f *OsuFile) Breaks() []BreakPeriod {
+	var breaks []BreakPeriod
+	for _, e := range f.Events {
+		if (e.EventType != "2" && e.EventType != "Break") || len(e.EventParams) == 0 {
+			continue
+		}
+		end, err := strconv.ParseFloat(e.EventParams[0], 64)
+		if err != nil {
+			continue
+		}
+		breaks = append(breaks, BreakPeriod{Start: float64(e.StartTime), End: end})
+	}
+	return breaks
+}
+
+// BPMRange returns the lowest and highest BPM of the uninherited timing
+// points up to EndTime.
+func (t *Timeline) BPMRange() (float64, float64) {
+	if len(t.timing) == 0 {
+		bpm := 60000.0 / defaultBeatLength
+		return bpm, bpm
+	}
+
+	minBPM, maxBPM := math.Inf(1), math.Inf(-1)
+	for i, tp := range t.timing {
+		if i > 0 && float64(tp.Time) > t.endTime {
+			break
+		}
+		bpm := tp.BPM()
+		minBPM = math.Min(minBPM, bpm)
+		maxBPM = math.Max(maxBPM, bpm)
+	}
+	return minBPM, maxBPM
+}
+
+// DominantBPM returns the BPM that lasts the longest, measured from the
+// start of the beatmap to EndTime.
+func (t *Timeline) DominantBPM() float64 {
+	if len(t.timing) == 0 {
+		return 60000.0 / defaultBeatLength
+	}
+
+	// beat lengths are grouped by their value rounded to three decimals
+	durations := make(map[float64]float64)
+	var order []float64
+	beatLengths := make(map[float64]float64)
+
+	for i, tp := range t.timing {
+		beatLength := math.Round(tp.BeatLength*1000) / 1000
+		if _, ok := durations[beatLength]; !ok {
+			order = append(order, beatLength)
+			durations[beatLength] = 0
+			beatLengths[beatLength] = tp.BeatLength
+		}
+		if float64(tp.Time) > t.endTime {
+			continue
+		}
+
+		// osu! stable considers the first timing point to start at 0
+		start := float64(tp.Time)
+		if i == 0 {
+			start = 0
+		}
+		end := t.endTime
+		if i < len(t.timing)-1 {
+			end = float64(t.timing[i+1].Time)
+		}
+		durations[beatLength] += end - start
+	}
+
+	dominant := order[0]
+	for _, beatLength := range order[1:] {
+		if durations[beatLength] > durations[dominant] {
+			dominant = beatLength
+		}
+	}
+	return 60000 / beatLengths[dominant]
+}
@@ -0,0 +1,51 @@
+package osuParser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimelineDifficultyAt(t *testing.T) {
+	timeline := NewTimeline([]TimingPointFile{
+		{Time: 0, BeatLength: 500, Uninherited: 1},
+		{Time: 1000, BeatLength: -50},
+		{Time: 2000, BeatLength: -5},
+		{Time: 3000, BeatLength: -2000},
+		// the inherited point wins over the uninherited one at the same time
+		{Time: 4000, BeatLength: -200},
+		{Time: 4000, BeatLength: 400, Uninherited: 1},
+		{Time: 5000, BeatLength: 2, Uninherited: 1},
+		{Time: 6000, BeatLength: math.NaN()},
+		{Time: 7000, BeatLength: 100},
+		{Time: 8000, BeatLength: -25},
+		{Time: 9000, BeatLength: 100000, Uninherited: 1},
+	})
+
+	tests := []struct {
+		time       float64
+		velocity   float64
+		beatLength float64
+	}{
+		{-100, 1, 500},
+		{0, 1, 500},
+		{999, 1, 500},
+		{1000, 2, 500},
+		{2500, 10, 500},
+		{3500, 0.1, 500},
+		{4000, 0.5, 400},
+		{5000, 1, 6},
+		{6000, 1, 6},
+		{7000, 1, 6},
+		{8000, 4, 6},
+		{9000, 1, 60000},
+	}
+
+	for _, test := range tests {
+		if v := timeline.DifficultyAt(test.time); !approxEqual(v, test.velocity) {
+			t.Errorf("slider velocity at %v is %v, want %v", test.time, v, test.velocity)
+		}
+		if b := timeline.BeatLengthAt(test.time); b != test.beatLength {
+			t.Errorf("beat length at %v is %v, want %v", test.time, b, test.beatLength)
+		}
+	}
+}
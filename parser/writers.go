@@ -0,0 +1,164 @@
+package osuParser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+func writeULEB128(w io.Writer, value uint64) error {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value != 0 {
+			b |= 0x80
+		}
+		if err := binary.Write(w, binary.LittleEndian, b); err != nil {
+			return err
+		}
+		if value == 0 {
+			return nil
+		}
+	}
+}
+
+func writeBoolean(w io.Writer, b bool) error {
+	var v byte
+	if b {
+		v = 0x01
+	}
+	return binary.Write(w, binary.LittleEndian, v)
+}
+
+func writeByte(w io.Writer, b byte) error {
+	return binary.Write(w, binary.LittleEndian, b)
+}
+
+// writeString writes s as a present string, which osu! does for empty
+// strings too.
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, byte(0x0b)); err != nil {
+		return err
+	}
+	if err := writeULEB128(w, uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// writeNullableString writes a null string if s is empty and was null in the
+// file it was read from.
+func writeNullableString(w io.Writer, s string, null bool) error {
+	if null && s == "" {
+		return writeByte(w, 0x00)
+	}
+	return writeString(w, s)
+}
+
+func writeInt(w io.Writer, num int32) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+func writeShort(w io.Writer, num uint16) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+func writeShortSigned(w io.Writer, num int16) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+func writeLong(w io.Writer, num int64) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+func writeSingle(w io.Writer, num float32) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+func writeDouble(w io.Writer, num float64) error {
+	return binary.Write(w, binary.LittleEndian, num)
+}
+
+// writeDateTime is the inverse of readDateTime and returns .NET ticks.
+func writeDateTime(t time.Time) int64 {
+	const ticksPerSecond = 10000000
+	const ticksOffset = 621355968000000000 // Ticks between 0001 and Unix epoch
+
+	return t.Unix()*ticksPerSecond + int64(t.Nanosecond()/100) + ticksOffset
+}
+
+func writeIntDoublePair(w io.Writer, key int32, value float64) error {
+	if err := writeByte(w, 0x08); err != nil {
+		return err
+	}
+	if err := writeInt(w, key); err != nil {
+		return err
+	}
+	if err := writeByte(w, 0x0d); err != nil {
+		return err
+	}
+	return writeDouble(w, value)
+}
+
+func writeIntFloatPair(w io.Writer, key int32, value float32) error {
+	if err := writeByte(w, 0x08); err != nil {
+		return err
+	}
+	if err := writeInt(w, key); err != nil {
+		return err
+	}
+	if err := writeByte(w, 0x0c); err != nil {
+		return err
+	}
+	return writeSingle(w, value)
+}
+
+// writeIntDoublePairs writes the count and the Int-Double pairs of the keys
+// of pairs in order, the inverse of the pairs readStarRatings reads.
+func writeIntDoublePairs(w io.Writer, keys []Mods, pairs map[Mods]float64) error {
+	if err := writeInt(w, int32(len(keys))); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := writeIntDoublePair(w, int32(key), pairs[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIntFloatPairs writes pairs like writeIntDoublePairs as Int-Float
+// pairs.
+func writeIntFloatPairs(w io.Writer, keys []Mods, pairs map[Mods]float64) error {
+	if err := writeInt(w, int32(len(keys))); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := writeIntFloatPair(w, int32(key), float32(pairs[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(filename string, encode func(w io.Writer) error) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriterSize(file, 128*1024)
+
+	if err := encode(writer); err != nil {
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
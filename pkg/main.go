@@ -1,74 +1,67 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	osuParser "github.com/juli0n21/go-osu-parser/parser"
 )
 
 func main() {
-	filename := "G://Anwendungen/osu!/osu!.db"
-	collname := "G://Anwendungen/osu!/collection.db"
-	scoresname := "G://Anwendungen/osu!/scores.db"
+	root := "G://Anwendungen/osu!"
 
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		log.Fatalf("osu!.db file does not exist at path: %s", filename)
+	if _, err := os.Stat(filepath.Join(root, "osu!.db")); os.IsNotExist(err) {
+		log.Fatalf("osu!.db file does not exist in: %s", root)
 	}
 
 	var start = time.Now()
-	db, err := osuParser.ParseOsuDB(filename)
+	install, err := osuParser.OpenInstall(root)
 	if err != nil {
-		log.Fatalf("Failed to parse osu!.db: %v", err)
+		log.Fatalf("Failed to open osu! install: %v", err)
 	}
 	fmt.Println("Parsed in: ", time.Since(start))
 
-	start = time.Now()
-	collection, err := osuParser.ParseCollectionsDB(collname)
-	if err != nil {
-		log.Fatalf("Failed to parse collections!.db: %v", err)
-	}
-	fmt.Println("Parsed in: ", time.Since(start))
-
-	start = time.Now()
-	scores, err := osuParser.ParseScoresDB(scoresname)
-	if err != nil {
-		log.Fatalf("Failed to parse scores!.db: %v", err)
-	}
-
-	fmt.Println("Parsed in: ", time.Since(start))
-
-	fmt.Println("Collections", collection.NumberOfCollections)
-	fmt.Println("Scores", scores.NumberOfScores)
+	db := install.OsuDB
+	fmt.Println("Collections", install.CollectionsDB.NumberOfCollections)
+	fmt.Println("Scores", install.ScoresDB.NumberOfScores)
+	fmt.Println("Missing beatmaps", len(install.Dangling))
 
 	fmt.Printf("Osu! Version: %d\n", db.Version)
 	fmt.Printf("Player Name: %s\n", db.PlayerName)
 	fmt.Printf("Number of Beatmaps: %d\n", db.NumberOfBeatmaps)
-	fmt.Printf("User Permissions: %d\n", db.UserPermissions)
+	fmt.Printf("User Permissions: %s\n", db.UserPermissions)
 
-	fmt.Print("Parsing all .osu files. this will take sometime...\n\n")
+	fmt.Print("Parsing all .osu files...\n\n")
 
 	var SotarksCount int
 	var TotalSotarksCircels int
 
 	start = time.Now()
-	for i, beatmap := range db.Beatmaps {
+	results, err := install.ParseOsuFiles(context.Background(), osuParser.BulkOptions{
+		Progress: func(done, total int) {
+			fmt.Printf("\033[F\r")
+			fmt.Printf("\033[K")
+			fmt.Printf("%d/%d\n", done, total)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to parse .osu files: %v", err)
+	}
 
-		b, err := osuParser.ParseOsuFile(fmt.Sprintf("G://Anwendungen/osu!/Songs/%s/%s", beatmap.FolderName, beatmap.FileName))
-		if err != nil {
-			log.Printf("Failed to parse osuFile: %v", err)
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("Failed to parse osuFile: %v", result.Err)
 			continue
 		}
 
-		if b.Creator == "Sotarks" {
+		if result.File.Creator == "Sotarks" {
 			SotarksCount++
-			TotalSotarksCircels += len(b.HitObjects)
+			TotalSotarksCircels += len(result.File.HitObjects)
 		}
-		fmt.Printf("\033[F\r")
-		fmt.Printf("\033[K")
-		fmt.Printf("%d/%d\n", i, db.NumberOfBeatmaps)
 	}
 
 	fmt.Println("All .osu files parsed in: ", time.Since(start))